@@ -2,15 +2,17 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
-	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"go_report/auth"
 	"go_report/domain"
 	"go_report/failure"
-	"go_report/gh"
-	"log"
+	"go_report/ingest"
+	"go_report/logging"
+	"go_report/tracing"
 	"time"
 	"net/http"
+
+	"github.com/go-chi/chi/middleware"
 )
 
 
@@ -22,23 +24,63 @@ func PingHandler() http.HandlerFunc {
 	})
 }
 
-// Gets all reports with content
-func GetAllHandler(s domain.Storer) http.HandlerFunc {
+// reportBrowser finds reports by tag and group status as well as listing them
+type reportBrowser interface {
+	domain.ReportStorer
+	domain.TagStorer
+	domain.GroupStorer
+}
+
+// Gets all reports with content, optionally only those carrying every ?tag=name=value,
+// and whose group has the ?status=... given
+func GetAllHandler(s reportBrowser) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		reports, err := s.SelectAll()
+		format, err := negotiateFormat(w, r)
 		if err != nil {
-			failure.Fail(w, err)
+			failure.Fail(w, r, err)
+			return
+		}
+		tags, err := tagFilters(r)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		var reports []domain.Report
+		if len(tags) == 0 {
+			reports, err = s.SelectAll(r.Context())
+		} else {
+			reports, err = selectTagged(r.Context(), s, tags)
+		}
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		if st := r.URL.Query().Get("status"); st != "" {
+			status, ok := domain.ConvertGroupStatusString(st)
+			if !ok {
+				failure.Fail(w, r, failure.New(errors.Errorf("unknown group status %v", st), http.StatusBadRequest, "status must be one of open, resolved, ignored, regressed"))
+				return
+			}
+			if reports, err = filterByGroupStatus(r.Context(), s, reports, status); err != nil {
+				failure.Fail(w, r, err)
+				return
+			}
+		}
+		if format != formatJSON {
+			if err := writeReports(w, format, "reports", reports); err != nil {
+				failure.Fail(w, r, err)
+			}
 			return
 		}
 		if err := json.NewEncoder(w).Encode(reports); err != nil {
-			failure.Fail(w, err)
+			failure.Fail(w, r, err)
 			return
 		}
 	})
 }
 
 // return content of files
-func GetGroupHandler(s domain.Storer) http.HandlerFunc {
+func GetGroupHandler(s reportBrowser) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Get the stored reports in json corresponding to an GID (i.e. {<id>:[...files]})
 		gid := r.Context().Value(string(ReportGIDVar)).(string)
@@ -46,75 +88,106 @@ func GetGroupHandler(s domain.Storer) http.HandlerFunc {
 			GetAllHandler(s)(w, r)
 			return
 		}
-		reports, err := s.SelectGroup(gid)
+		format, err := negotiateFormat(w, r)
 		if err != nil {
-			failure.Fail(w, err)
+			failure.Fail(w, r, err)
+			return
+		}
+		reports, err := s.SelectGroup(r.Context(), gid)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		if format != formatJSON {
+			if err := writeReports(w, format, gid, reports); err != nil {
+				failure.Fail(w, r, err)
+			}
 			return
 		}
 		if err = json.NewEncoder(w).Encode(reports); err != nil {
-			failure.Fail(w, err)
+			failure.Fail(w, r, err)
 			return
 		}
 	})
 }
 
 // return content of file
-func GetReportHandler(s domain.Storer) http.HandlerFunc {
+func GetReportHandler(s domain.ReportStorer) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		g, k := r.Context().Value(string(ReportGIDVar)).(string), r.Context().Value(string(ReportKeyVar)).(string)
-		rpt, err := s.Select(domain.Receipt{
+		rpt, err := s.Select(r.Context(), domain.Receipt{
 			Key: k,
 			GID: g,
 		})
 		if err != nil {
-			failure.Fail(w, err)
+			failure.Fail(w, r, err)
 			return
 		}
 		if err := json.NewEncoder(w).Encode(rpt); err != nil {
-			failure.Fail(w, errors.Wrap(err, "failed to encode report json to http writer response stream"))
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode report json to http writer response stream"))
 			return
 		}
 	})
 }
 
-func PostHandler(issThreshold int, s domain.Storer, ghs *gh.Service, logger *log.Logger) http.HandlerFunc {
+// PostHandler accepts the report for storage, responding 202 with its receipt. The report is stored, and its side
+// effects run, by the ingestion queue (see ingest.go), which also completes its idempotency key.
+func PostHandler(idempotencyWindow time.Duration, s domain.IdempotencyStorer, q *ingest.Queue) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context())
 		// read rpt from context
 		rpt := r.Context().Value(string(ReportCtxVar)).(domain.Report)
+		idemKey, handled := claimIdempotencyKey(w, r, s, idempotencyWindow, rpt)
+		if handled {
+			return // a retry
+		}
+		release := func() {
+			if idemKey == "" {
+				return
+			}
+			if err := s.ReleaseIdempotencyKey(r.Context(), idemKey); err != nil {
+				logger.Warn("failed to release idempotency key", "idempotencyKey", idemKey, "err", err)
+			}
+		}
 		rpt.ReceivedOn = time.Now()
-		// add to s
-		rr, err := s.NewEntry(rpt)
+		if rel := auth.ReleaseFromContext(r.Context()); rel != "" {
+			rpt.Release = rel // the certificate's release is trusted over the payload's
+		}
+		if err := rpt.ValidateSize(); err != nil {
+			release()
+			failure.Fail(w, r, failure.New(err, http.StatusRequestEntityTooLarge, err.Error()))
+			return
+		}
+		key, err := rpt.ContentKey()
 		if err != nil {
-			failure.Fail(w, failure.New(errors.Wrap(err, "failed to create store entry"), http.StatusInternalServerError, ""))
+			release()
+			failure.Fail(w, r, failure.New(errors.Wrap(err, "failed to key report"), http.StatusInternalServerError, ""))
 			return
 		}
+		rpt.Key = key
+		rr := domain.Receipt{GID: rpt.GID, Key: rpt.Key}
+		job := ingest.Job{Report: rpt, RequestID: middleware.GetReqID(r.Context()), TraceParent: tracing.TraceParentFromContext(r.Context()), IdempotencyKey: idemKey}
+		if err := q.Enqueue(job); err != nil {
+			release()
+			w.Header().Set("Retry-After", "30")
+			failure.Fail(w, r, failure.New(errors.Wrap(err, "failed to queue report"), http.StatusServiceUnavailable, "Too many reports are waiting to be stored, retry later"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
 		if err := json.NewEncoder(w).Encode(&rr); err != nil {
-			failure.Fail(w, failure.New(errors.Wrap(err, "failed to encode reciept"), http.StatusInternalServerError, ""))
-			return
-		}
-		if issThreshold > 0 && int(rpt.Severity) >= issThreshold {
-			logger.Println("Creating github issue for crash report")
-			err = ghs.CreateGitHubIssue(github.IssueRequest{
-				Title:  github.String(rr.GID + " " + rr.Key),
-				Body:   github.String(fmt.Sprintf("---- Automated Crash Report ----\n\nKey: %v", rr.Key)),
-				Labels: &[]string{"Critical"},
-			})
-			if err != nil {
-				logger.Println("failed to create github issue (key=%v): %v", rpt.Key, err.Error())
-			} else {
-				logger.Println("Successfully created github issue")
-			}
+			logger.Warn("failed to encode receipt", "gid", rr.GID, "err", err)
 		}
-		w.WriteHeader(http.StatusCreated)
 	})
 }
 
 // remove a single file by its key
-func DeleteReportHandler(s domain.Storer) http.HandlerFunc {
+func DeleteReportHandler(s domain.ReportStorer) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		g, k := r.Context().Value(string(ReportGIDVar)).(string), r.Context().Value(string(ReportKeyVar)).(string) // if we fail to convert to string, we have a big problem -> let recoverer middleware deal
-		if err := s.RemoveEntry(domain.Receipt{Key: k, GID:g}); err != nil {
-			failure.Fail(w, err)
+		if err := s.RemoveEntry(r.Context(), domain.Receipt{Key: k, GID:g}); err != nil {
+			failure.Fail(w, r, err)
+			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 	})
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"go_report/auth/msscerts"
+	"go_report/logging"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/jwtauth"
+)
+
+// how long a certificate's registration is remembered, so each report sent with a client certificate (or sentry key)
+// does not read the store
+const clientCertCacheFor = time.Minute
+
+// how many certificates' registrations are remembered at most
+const clientCertCacheSize = 1024
+
+// Fingerprint identifies a client certificate: the hex sha256 of its der encoding. Applications register it as
+// their mss certificate (POST /certificate/{fingerprint}), to authenticate with it instead of a jwt.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+type cachedCert struct {
+	fp      string
+	cert    *msscerts.Certificate
+	expires time.Time
+}
+
+// clientCerts is a least recently used cache of registered certificates. Unregistered ones are never cached, as
+// any string sent as a sentry key or ?key= reaches it.
+type clientCerts struct {
+	lock  sync.Mutex
+	order *list.List // of cachedCert, most recently used first
+	certs map[string]*list.Element
+}
+
+func (cc *clientCerts) get(fp string) *msscerts.Certificate {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	e, ok := cc.certs[fp]
+	if !ok {
+		return nil
+	}
+	if c := e.Value.(cachedCert); time.Now().Before(c.expires) {
+		cc.order.MoveToFront(e)
+		return c.cert
+	}
+	cc.order.Remove(e)
+	delete(cc.certs, fp)
+	return nil
+}
+
+func (cc *clientCerts) put(fp string, cert *msscerts.Certificate) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	if cc.certs == nil {
+		cc.order, cc.certs = list.New(), map[string]*list.Element{}
+	}
+	c := cachedCert{fp: fp, cert: cert, expires: time.Now().Add(clientCertCacheFor)}
+	if e, ok := cc.certs[fp]; ok {
+		e.Value = c
+		cc.order.MoveToFront(e)
+	} else {
+		cc.certs[fp] = cc.order.PushFront(c)
+	}
+	// drop the expired & least recently used
+	now := time.Now()
+	for e := cc.order.Back(); e != nil; e = cc.order.Back() {
+		if c := e.Value.(cachedCert); cc.order.Len() <= clientCertCacheSize && now.Before(c.expires) {
+			break
+		}
+		cc.order.Remove(e)
+		delete(cc.certs, e.Value.(cachedCert).fp)
+	}
+}
+
+func (cc *clientCerts) forget(fp string) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	if e, ok := cc.certs[fp]; ok {
+		cc.order.Remove(e)
+		delete(cc.certs, fp)
+	}
+}
+
+// ClientCertificate authenticates requests without a jwt whose client certificate the tls server verified (see
+// tls.go): a registered certificate is given the claims of an app jwt minted with its fingerprint, so Authenticate
+// and the handlers treat it as that application. It must follow Verifier.
+func (a *Service) ClientCertificate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || hasJWT(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, a.asApplication(r, Fingerprint(r.TLS.VerifiedChains[0][0]), "client certificate"))
+	})
+}
+
+// hasJWT is true if the request sent a jwt, valid or not, which takes precedence over other credentials
+func hasJWT(r *http.Request) bool {
+	_, _, err := jwtauth.FromContext(r.Context())
+	return err != jwtauth.ErrNoTokenFound
+}
+
+// asApplication returns the request with the claims of the app jwt of cert, a registered mss certificate sent as
+// another credential (the kind), or the request as is (unauthenticated) if cert is not registered
+func (a *Service) asApplication(r *http.Request, cert, kind string) *http.Request {
+	c, err := a.registeredClientCert(r.Context(), cert)
+	digest := fmt.Sprintf("mss:%x", md5.Sum([]byte(cert))) // as ClientFromContext, never the certificate itself
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("could not look up "+kind, "certificate", digest, "err", err)
+	} else if c == nil {
+		logging.FromContext(r.Context()).Info(kind+" is not a registered certificate", "certificate", digest)
+	}
+	if c == nil {
+		return r
+	}
+	return withApplication(r, c)
+}
+
+// withApplication returns the request with the claims of the app jwt of a registered certificate
+func withApplication(r *http.Request, c *msscerts.Certificate) *http.Request {
+	tkn := jwt.NewWithClaims(jwt.SigningMethodHS512, appClaims(c))
+	tkn.Valid = true
+	return r.WithContext(jwtauth.NewContext(r.Context(), tkn, nil))
+}
+
+// forgetClientCert drops the cached registration of a certificate which was just added or removed
+func (a *Service) forgetClientCert(cert string) {
+	a.clientCerts.forget(strings.TrimSpace(cert))
+}
+
+func (a *Service) registeredClientCert(ctx context.Context, fp string) (*msscerts.Certificate, error) {
+	if c := a.clientCerts.get(fp); c != nil {
+		return c, nil
+	}
+	cert, err := a.cm.Get(ctx, fp)
+	if err != nil || cert == nil {
+		return nil, err
+	}
+	a.clientCerts.put(fp, cert)
+	return cert, nil
+}
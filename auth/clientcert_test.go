@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"fmt"
+	"go_report/auth/msscerts"
+	"testing"
+	"time"
+)
+
+func TestClientCertsCache(t *testing.T) {
+	cc := clientCerts{}
+	a, b := &msscerts.Certificate{Value: "a"}, &msscerts.Certificate{Value: "b"}
+	if c := cc.get("a"); c != nil {
+		t.Fatalf("empty cache returned %v", c)
+	}
+	cc.put("a", a)
+	cc.put("b", b)
+	if c := cc.get("a"); c != a {
+		t.Errorf("got %v, want %v", c, a)
+	}
+	cc.forget("a")
+	if c := cc.get("a"); c != nil {
+		t.Errorf("forgotten certificate returned %v", c)
+	}
+
+	// expired entries are dropped when read
+	cc.certs["b"].Value = cachedCert{fp: "b", cert: b, expires: time.Now().Add(-time.Second)}
+	if c := cc.get("b"); c != nil {
+		t.Errorf("expired certificate returned %v", c)
+	}
+	if _, ok := cc.certs["b"]; ok || cc.order.Len() != 0 {
+		t.Errorf("expired certificate was kept: %d cached", cc.order.Len())
+	}
+
+	// the least recently used are dropped beyond clientCertCacheSize
+	for i := 0; i <= clientCertCacheSize; i++ {
+		cc.put(fmt.Sprint(i), a)
+		if i == 0 {
+			cc.put("b", b)
+		}
+		cc.get("0")
+	}
+	if n := cc.order.Len(); n != clientCertCacheSize {
+		t.Errorf("%d cached, want %d", n, clientCertCacheSize)
+	}
+	if c := cc.get("0"); c != a {
+		t.Errorf("recently used certificate was dropped")
+	}
+	if c := cc.get("b"); c != nil {
+		t.Errorf("least recently used certificate was kept")
+	}
+}
@@ -2,11 +2,14 @@ package auth
 
 import (
 	"context"
+	"crypto/md5"
 	"encoding/json"
+	"fmt"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/jwtauth"
 	"github.com/pkg/errors"
 	"go_report/failure"
+	"go_report/logging"
 	"net/http"
 )
 
@@ -48,6 +51,7 @@ func (a *Service) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 		// Token is authenticated, pass it through
+		logging.AddFields(r.Context(), "subject", ClientFromContext(r.Context()))
 		next.ServeHTTP(w, r.WithContext(r.Context()))
 	})
 }
@@ -69,6 +73,64 @@ func (a *Service) OnlyDevsAuthenticate(next http.Handler) http.Handler {
 	})
 }
 
+// ErrUnauthenticated is returned by AuthenticateBearer for a missing, invalid or unacceptable jwt
+var ErrUnauthenticated = errors.New("missing or invalid jwt")
+
+// AuthenticateBearer authenticates the "Bearer <jwt>" authorization of a call which is not an http request (i.e. gRPC
+// metadata) as Verifier, then Authenticate (or OnlyDevsAuthenticate, if onlyDevs) would, returning a context which
+// carries the jwt
+func (a *Service) AuthenticateBearer(ctx context.Context, authorization string, onlyDevs bool) (context.Context, error) {
+	r := &http.Request{Header: http.Header{"Authorization": {authorization}}}
+	tkn, err := jwtauth.VerifyRequest(a.jwt, r, jwtauth.TokenFromHeader)
+	if err != nil || tkn == nil || !tkn.Valid {
+		return ctx, ErrUnauthenticated
+	}
+	ctx = jwtauth.NewContext(ctx, tkn, nil)
+	_, claims, _ := jwtauth.FromContext(ctx)
+	dev := claims.VerifyAudience(string(GHAudience), true) && claims[string(GHUser)] != ""
+	if onlyDevs && !dev || !dev && !claims.VerifyAudience(string(MSSAudience), true) {
+		return ctx, ErrUnauthenticated
+	}
+	logging.AddFields(ctx, "subject", ClientFromContext(ctx))
+	return ctx, nil
+}
+
+// GHUserFromContext returns the github username of the request's developer jwt, or "" if it is not a developer jwt
+func GHUserFromContext(ctx context.Context) string {
+	_, claims, err := jwtauth.FromContext(ctx)
+	if err != nil {
+		return ""
+	}
+	user, _ := claims[string(GHUser)].(string)
+	return user
+}
+
+// ReleaseFromContext returns the release of the certificate which minted the request's app jwt, or "" if unknown
+func ReleaseFromContext(ctx context.Context) string {
+	_, claims, err := jwtauth.FromContext(ctx)
+	if err != nil {
+		return ""
+	}
+	release, _ := claims[string(MSSRelease)].(string)
+	return release
+}
+
+// ClientFromContext identifies who sent the request: a developer by github username, or an application by the
+// digest of its certificate. It is "" if the request has no jwt.
+func ClientFromContext(ctx context.Context) string {
+	_, claims, err := jwtauth.FromContext(ctx)
+	if err != nil {
+		return ""
+	}
+	if user, _ := claims[string(GHUser)].(string); user != "" {
+		return "gh:" + user
+	}
+	if cert, _ := claims[string(MSSCertificate)].(string); cert != "" {
+		return fmt.Sprintf("mss:%x", md5.Sum([]byte(cert)))
+	}
+	return ""
+}
+
 // Endpoints
 
 type TokenRequest struct {
@@ -81,12 +143,15 @@ func (a *Service) TokenExchangeHandler() http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tr := TokenRequest{}
 		if err := json.NewDecoder(r.Body).Decode(&tr); err != nil {
-			failure.Fail(w, ErrCreatingToken(errors.Wrap(err, "failed to decode token request body"), http.StatusBadRequest))
+			rf := ErrCreatingToken(errors.Wrap(err, "failed to decode token request body"), http.StatusBadRequest)
+			a.exchanged(r, tr, rf)
+			failure.Fail(w, r, rf)
 			return
 		}
-		tkn, err := a.maybeCreateJWT(tr) // we may need to do more processing here
+		tkn, err := a.maybeCreateJWT(r.Context(), tr) // we may need to do more processing here
+		a.exchanged(r, tr, err)
 		if err != nil {
-			failure.Fail(w, err)
+			failure.Fail(w, r, err)
 			return
 		}
 		// access granted.
@@ -96,18 +161,62 @@ func (a *Service) TokenExchangeHandler() http.HandlerFunc {
 	})
 }
 
+// exchanged logs a token exchange's outcome, and reports it to OnExchange
+func (a *Service) exchanged(r *http.Request, tr TokenRequest, err error) {
+	audience := JwtAudience("unknown")
+	if tr.MSSCert != "" {
+		audience = MSSAudience
+	} else if tr.User != "" || tr.GitHubToken != "" {
+		audience = GHAudience
+	}
+	outcome := "issued"
+	if rf, ok := err.(*failure.RequestFailure); ok {
+		switch {
+		case rf.Code == http.StatusUnauthorized:
+			outcome = "denied"
+		case rf.Code < http.StatusInternalServerError:
+			outcome = "invalid"
+		default:
+			outcome = "error"
+		}
+	} else if err != nil {
+		outcome = "error"
+	}
+	kv := []interface{}{"audience", audience, "outcome", outcome}
+	if tr.User != "" {
+		kv = append(kv, "ghUser", tr.User)
+	}
+	logging.FromContext(r.Context()).Info("token exchange", kv...)
+	if a.OnExchange != nil {
+		a.OnExchange(audience, outcome)
+	}
+}
+
+// CertificateResponse is the response to adding a certificate
+type CertificateResponse struct {
+	UploadToken string `json:"uploadToken"` // the ?key= of uploads made with the certificate, see URLKey
+	DSNKey      string `json:"dsnKey"`      // the public key of sentry DSNs of the certificate, see SentryKey
+}
+
+// AddCertificateHandler stores the certificate in context, minted for the application release given by ?release=...
 func (a *Service) AddCertificateHandler() http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cert := r.Context().Value(string(CertCtxVar)).(string)
 		if cert == "" {
-			failure.Fail(w, failure.New(errors.New("No certificate found in context"), http.StatusBadRequest, "no certificate provided"))
+			failure.Fail(w, r, failure.New(errors.New("No certificate found in context"), http.StatusBadRequest, "no certificate provided"))
 			return
 		}
-		if err := a.cm.AddCertificate(cert); err != nil {
-			failure.Fail(w, failure.New(err, http.StatusInternalServerError, "could not add certificate"))
+		if err := a.cm.AddCertificate(r.Context(), cert, r.URL.Query().Get("release")); err != nil {
+			failure.Fail(w, r, failure.New(err, http.StatusInternalServerError, "could not add certificate"))
 			return
 		}
+		a.forgetClientCert(cert)
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(CertificateResponse{UploadToken: a.UploadToken(cert), DSNKey: a.DSNKey(cert)}); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode certificate json to http writer response stream"))
+			return
+		}
 	})
 }
 
@@ -116,13 +225,14 @@ func (a *Service) RemoveCertificateHandler() http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cert := r.Context().Value(string(CertCtxVar)).(string)
 		if cert == "" {
-			failure.Fail(w, failure.New(errors.New("No certificate found in context"), http.StatusBadRequest, "no certificate provided"))
+			failure.Fail(w, r, failure.New(errors.New("No certificate found in context"), http.StatusBadRequest, "no certificate provided"))
 			return
 		}
-		if err := a.cm.RemoveCertificate(cert); err != nil {
-			failure.Fail(w, failure.New(err, http.StatusInternalServerError, "could not remove certificate"))
+		if err := a.cm.RemoveCertificate(r.Context(), cert); err != nil {
+			failure.Fail(w, r, failure.New(err, http.StatusInternalServerError, "could not remove certificate"))
 			return
 		}
+		a.forgetClientCert(cert)
 		w.WriteHeader(http.StatusNoContent)
 	})
 }
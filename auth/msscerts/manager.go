@@ -1,12 +1,13 @@
 package msscerts
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"go_report/domain"
 	"go_report/failure"
+	"go_report/logging"
 	"go_report/store/dynamo"
-	"log"
 	"net/http"
 	"strings"
 	"sync"
@@ -24,15 +25,15 @@ var (
 	)
 )
 
-const MssCertificateGid = "MSS_CERTIFICATE"
+const MssCertificateGid = domain.CertificateGID
 
 type Manager struct {
-	*log.Logger
+	*logging.Logger
 	*dynamo.Store
 	lock sync.RWMutex
 }
 
-func Init(store *dynamo.Store, logger *log.Logger) {
+func Init(store *dynamo.Store, logger *logging.Logger) {
 	initOnce.Do(func() {
 		man = &Manager{
 			lock:   sync.RWMutex{},
@@ -46,25 +47,57 @@ func GetManager() *Manager {
 	return man
 }
 
-func (man *Manager) Verify(cert string) (bool, error) {
+// Certificate is an application certificate, minted for one release of the application
+type Certificate struct {
+	Value   string
+	Release string // empty if the certificate was not minted for a particular release
+}
+
+func (man *Manager) Verify(ctx context.Context, cert string) (bool, error) {
+	c, err := man.Get(ctx, cert)
+	return c != nil, err
+}
+
+// Get looks up a stored certificate, nil if the certificate is unknown
+func (man *Manager) Get(ctx context.Context, cert string) (*Certificate, error) {
+	man.lock.RLock()
+	defer man.lock.RUnlock()
+	return man.get(ctx, Digest(cert))
+}
+
+// GetByDigest looks up a stored certificate by the md5 of its value, nil if the certificate is unknown
+func (man *Manager) GetByDigest(ctx context.Context, digest string) (*Certificate, error) {
 	man.lock.RLock()
 	defer man.lock.RUnlock()
-	cert = strings.TrimSpace(cert)
+	return man.get(ctx, digest)
+}
+
+func (man *Manager) get(ctx context.Context, key string) (*Certificate, error) {
 	// The query for the store
-	r, err := man.Store.Select(domain.Receipt{GID: MssCertificateGid, Key: getMD5HashString([]byte(cert))})
+	r, err := man.Store.Select(ctx, domain.Receipt{GID: MssCertificateGid, Key: key})
 	if err != nil {
-		return false, errors.Wrap(err, "Could not retrieve entry from database")
+		return nil, errors.Wrap(err, "Could not retrieve entry from database")
+	} else if r == nil || r.Key == "" { // no item found
+		return nil, nil
 	}
-	return r != nil, nil
+	value, _ := r.Content["value"].(string)
+	release, _ := r.Content["release"].(string)
+	return &Certificate{Value: value, Release: release}, nil
 }
 
-func (man *Manager) AddCertificate(cert string) error {
+// AddCertificate stores cert, minted for the given release (may be empty)
+func (man *Manager) AddCertificate(ctx context.Context, cert string, release string) error {
 	man.lock.Lock()
 	defer man.lock.Unlock()
-	_, err := man.NewEntry(domain.Report{
-		GID: MssCertificateGid,
-		Key: getMD5HashString([]byte(cert)),
-		Content: map[string]interface{}{"value":cert},
+	content := map[string]interface{}{"value": strings.TrimSpace(cert)}
+	if release != "" {
+		content["release"] = release
+	}
+	_, err := man.PutRecord(ctx, domain.Report{
+		GID:     MssCertificateGid,
+		Key:     Digest(cert),
+		Content: content,
+		Release: release,
 	})
 	if err != nil {
 		return errors.Wrap(err, "Could not write certificate to database")
@@ -72,18 +105,53 @@ func (man *Manager) AddCertificate(cert string) error {
 	return nil
 }
 
-func (man Manager) RemoveCertificate(needle string) error {
+// Migrate re-keys certificates stored before they were keyed by the Digest of their value, which Get looks up.
+// Certificates were previously stored under the md5 of the whole record, so Get would not find them. It is run once
+// by the migrate command (see migrate.go), never while serving.
+func (man *Manager) Migrate(ctx context.Context) error {
+	man.lock.Lock()
+	defer man.lock.Unlock()
+	records, err := man.Store.SelectGroup(ctx, MssCertificateGid)
+	if err != nil {
+		return errors.Wrap(err, "Could not list certificates to migrate")
+	}
+	for _, r := range records {
+		cert, _ := r.Content["value"].(string)
+		key := Digest(cert)
+		if cert == "" || r.Key == key {
+			continue
+		}
+		if !r.ReceivedOn.IsZero() { // certificates were never stamped; this was filed as a report
+			man.Warn("not migrating a report filed as a certificate", "key", r.Key)
+			continue
+		}
+		old := r.Key
+		r.Key = key
+		r.Content["value"] = strings.TrimSpace(cert)
+		if _, err := man.PutRecord(ctx, r); err != nil {
+			return errors.Wrap(err, "Could not write migrated certificate to database")
+		}
+		if err := man.Store.RemoveEntry(ctx, domain.Receipt{GID: MssCertificateGid, Key: old}); err != nil {
+			return errors.Wrap(err, "Could not remove migrated certificate")
+		}
+		man.Info("migrated certificate key", "from", old, "to", key)
+	}
+	return nil
+}
+
+func (man *Manager) RemoveCertificate(ctx context.Context, needle string) error {
 	man.lock.Lock()
 	defer man.lock.Unlock()
-	err := man.Store.RemoveEntry(domain.Receipt{GID: MssCertificateGid, Key: getMD5HashString([]byte(needle))})
+	err := man.Store.RemoveEntry(ctx, domain.Receipt{GID: MssCertificateGid, Key: Digest(needle)})
 	if err != nil {
 		return errors.Wrap(err, "Could not remove certificate due to error")
 	}
 	return nil
 }
 
-func getMD5HashString(text []byte) string {
+// Digest is the md5 of the certificate, without surrounding whitespace, which it is stored under
+func Digest(cert string) string {
 	hasher := md5.New()
-	hasher.Write(text)
+	hasher.Write([]byte(strings.TrimSpace(cert)))
 	return hex.EncodeToString(hasher.Sum(nil))
 }
\ No newline at end of file
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"go_report/logging"
+	"go_report/sentry"
+	"net/http"
+)
+
+// SentryKey authenticates requests of sentry SDKs without a jwt: the public key of their DSN (see
+// sentry.KeyFromRequest) is the DSNKey of an mss certificate, so a registered certificate's key is treated as that
+// application's jwt. It must follow Verifier.
+func (a *Service) SentryKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := sentry.KeyFromRequest(r)
+		if key == "" || hasJWT(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		digest := a.dsnDigest(key)
+		if digest == "" {
+			logging.FromContext(r.Context()).Info("sentry key is not a valid dsn key")
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, a.asDigestApplication(r, digest, "sentry key"))
+	})
+}
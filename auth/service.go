@@ -1,13 +1,14 @@
 package auth
 
 import (
+	"context"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/go-chi/jwtauth"
 	"github.com/pkg/errors"
 	"go_report/auth/msscerts"
 	"go_report/gh"
+	"go_report/logging"
 	"go_report/store/dynamo"
-	"log"
 	"net/http"
 	"time"
 )
@@ -27,24 +28,32 @@ const (
 	GHUser         JwtClaimKey = "ghuname"
 	GHToken        JwtClaimKey = "ghtkn"
 	MSSCertificate JwtClaimKey = "mssCert"
+	MSSRelease     JwtClaimKey = "mssRelease" // release the mss certificate was minted for
 )
 
 type Service struct {
 	cm  *msscerts.Manager
 	ghs *gh.Service
 	jwt *jwtauth.JWTAuth
+	// uploadKey signs upload tokens, see UploadToken
+	uploadKey []byte
+	// clientCerts caches which client certificate fingerprints are registered, see ClientCertificate
+	clientCerts clientCerts
+	// OnExchange, if set, is called with the outcome of every token exchange: issued, denied, invalid or error
+	OnExchange func(audience JwtAudience, outcome string)
 }
 
-func New(certsDB *dynamo.Store, shh Secrets, ghs *gh.Service, logger *log.Logger) (s *Service) {
+func New(certsDB *dynamo.Store, shh Secrets, ghs *gh.Service, logger *logging.Logger) (s *Service) {
 	msscerts.Init(certsDB, logger)
 	return &Service{
-		cm:  msscerts.GetManager(),
-		ghs: ghs,
-		jwt: jwtauth.New(jwt.SigningMethodHS512.Name, []byte(shh.JWTKey), nil),
+		cm:        msscerts.GetManager(),
+		ghs:       ghs,
+		jwt:       jwtauth.New(jwt.SigningMethodHS512.Name, []byte(shh.JWTKey), nil),
+		uploadKey: []byte(shh.JWTKey),
 	}
 }
 
-func (a *Service) maybeCreateJWT(tr TokenRequest) (tkn string, err error) {
+func (a *Service) maybeCreateJWT(ctx context.Context, tr TokenRequest) (tkn string, err error) {
 	// Enforce token request is either cert based, or github based.
 	if tr.MSSCert != "" && (tr.GitHubToken != "" || tr.User != "") {
 		return "", ErrMSSGHTokenRequest
@@ -53,9 +62,9 @@ func (a *Service) maybeCreateJWT(tr TokenRequest) (tkn string, err error) {
 	}
 	// select token request branch
 	if tr.MSSCert != "" {
-		tkn, err = a.newSignedAppJWT(tr.MSSCert)
+		tkn, err = a.newSignedAppJWT(ctx, tr.MSSCert)
 	} else {
-		tkn, err = a.newSignedDevJWT(tr.User, tr.GitHubToken)
+		tkn, err = a.newSignedDevJWT(ctx, tr.User, tr.GitHubToken)
 	}
 	// report any failures
 	if err == jwtauth.ErrUnauthorized {
@@ -69,8 +78,8 @@ func (a *Service) maybeCreateJWT(tr TokenRequest) (tkn string, err error) {
 	return tkn, nil
 }
 
-func (a *Service) newSignedDevJWT(user string, ghTkn string) (tkn string, err error) {
-	ok, err := a.ghs.VerifyDeveloperToken(user, ghTkn)
+func (a *Service) newSignedDevJWT(ctx context.Context, user string, ghTkn string) (tkn string, err error) {
+	ok, err := a.ghs.WithContext(ctx).VerifyDeveloperToken(user, ghTkn)
 	if err != nil {
 		return "", err
 	} else if !ok {
@@ -88,19 +97,29 @@ func (a *Service) newSignedDevJWT(user string, ghTkn string) (tkn string, err er
 	return tkn, nil
 }
 
-func (a *Service) newSignedAppJWT(mssCert string) (tkn string, err error) {
-	if ok, err := a.cm.Verify(mssCert); err != nil {
+func (a *Service) newSignedAppJWT(ctx context.Context, mssCert string) (tkn string, err error) {
+	cert, err := a.cm.Get(ctx, mssCert)
+	if err != nil {
 		return "", err
-	} else if !ok {
+	} else if cert == nil {
 		return "", jwtauth.ErrUnauthorized
 	}
-	_, tkn, err = a.jwt.Encode(jwt.MapClaims{
+	_, tkn, err = a.jwt.Encode(appClaims(cert))
+	return tkn, nil
+}
+
+// appClaims are the claims of an application's jwt, which identify it by its certificate
+func appClaims(cert *msscerts.Certificate) jwt.MapClaims {
+	claims := jwt.MapClaims{
 		"aud":                  string(MSSAudience),
-		string(MSSCertificate): mssCert,
+		string(MSSCertificate): cert.Value,
 		"iss":                  "mss_go_report",
 		"iat":                  time.Now().Unix(),
 		"exp":                  time.Now().Add(ExpiresOneYear).Unix(),
 		"nbf":                  time.Now().Unix(),
-	})
-	return tkn, nil
+	}
+	if cert.Release != "" {
+		claims[string(MSSRelease)] = cert.Release
+	}
+	return claims
 }
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"go_report/auth/msscerts"
+	"go_report/logging"
+	"net/http"
+	"strings"
+)
+
+// URLKeyParam is the query parameter of URLKey
+const URLKeyParam = "key"
+
+// UploadToken is the ?key= of an application's certificate: "<md5 of the certificate>.<hmac of the md5>". Unlike
+// the certificate, it only authenticates uploads at URLKey routes, so leaking it (i.e. in a proxy's logs) does not
+// let anyone exchange the certificate for a jwt.
+func (a *Service) UploadToken(cert string) string {
+	digest := msscerts.Digest(cert)
+	return digest + "." + a.uploadMAC(digest)
+}
+
+func (a *Service) uploadMAC(digest string) string {
+	mac := hmac.New(sha256.New, a.uploadKey)
+	mac.Write([]byte("upload:" + digest))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// uploadDigest returns the certificate digest of a valid upload token, or "" if the token is not valid
+func (a *Service) uploadDigest(token string) string {
+	i := strings.Index(token, ".")
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(a.uploadMAC(token[:i]))) {
+		return ""
+	}
+	return token[:i]
+}
+
+// DSNKey is the public key of a sentry DSN for an application's certificate: its UploadToken without the ".", as
+// sentry SDKs only accept word characters in a DSN's key
+func (a *Service) DSNKey(cert string) string {
+	return strings.Replace(a.UploadToken(cert), ".", "", 1)
+}
+
+// dsnDigest returns the certificate digest of a valid DSN key, or "" if the key is not valid
+func (a *Service) dsnDigest(key string) string {
+	if len(key) <= md5.Size*2 {
+		return ""
+	}
+	return a.uploadDigest(key[:md5.Size*2] + "." + key[md5.Size*2:])
+}
+
+// URLKey authenticates clients which are configured with only an url, without headers (i.e. Crashpad's upload url):
+// its ?key= is the UploadToken of an mss certificate, so a registered certificate's token is treated as that
+// application's jwt. It must follow Verifier.
+func (a *Service) URLKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get(URLKeyParam)
+		if key == "" || hasJWT(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		digest := a.uploadDigest(key)
+		if digest == "" {
+			logging.FromContext(r.Context()).Info("url key is not a valid upload token")
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, a.asDigestApplication(r, digest, "url key"))
+	})
+}
+
+// asDigestApplication returns the request with the claims of the app jwt of the certificate of digest, taken from a
+// valid token (the kind), or the request as is (unauthenticated) if the certificate is not registered
+func (a *Service) asDigestApplication(r *http.Request, digest, kind string) *http.Request {
+	c, err := a.cm.GetByDigest(r.Context(), digest)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("could not look up "+kind, "certificate", "mss:"+digest, "err", err)
+	} else if c == nil {
+		logging.FromContext(r.Context()).Info(kind+" is not of a registered certificate", "certificate", "mss:"+digest)
+	}
+	if c == nil {
+		return r
+	}
+	return withApplication(r, c)
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUploadToken(t *testing.T) {
+	a := &Service{uploadKey: []byte("secret")}
+	token := a.UploadToken("cert")
+	digest := "b6ba9fa6ea18201bf39ea635ecca9f13" // md5 of "cert"
+	other := (&Service{uploadKey: []byte("other")}).UploadToken("cert")
+	tests := []struct {
+		name, token, digest string
+	}{
+		{"valid", token, digest},
+		{"certificate", "cert", ""},
+		{"digest only", digest, ""},
+		{"other key", other, ""},
+		{"tampered digest", strings.Replace(token, "b6ba", "b6bb", 1), ""},
+		{"truncated mac", token[:len(token)-1], ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := a.uploadDigest(tt.token); got != tt.digest {
+			t.Errorf("%v: got digest %q, want %q", tt.name, got, tt.digest)
+		}
+	}
+}
+
+func TestDSNKey(t *testing.T) {
+	a := &Service{uploadKey: []byte("secret")}
+	key := a.DSNKey("cert")
+	digest := "b6ba9fa6ea18201bf39ea635ecca9f13" // md5 of "cert"
+	if strings.Trim(key, "0123456789abcdef") != "" {
+		t.Errorf("key %q has characters sentry SDKs refuse", key)
+	}
+	tests := []struct {
+		name, key, digest string
+	}{
+		{"valid", key, digest},
+		{"certificate", "cert", ""},
+		{"upload token", a.UploadToken("cert"), ""},
+		{"digest only", digest, ""},
+		{"tampered digest", strings.Replace(key, "b6ba", "b6bb", 1), ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := a.dsnDigest(tt.key); got != tt.digest {
+			t.Errorf("%v: got digest %q, want %q", tt.name, got, tt.digest)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package blob stores files too large for the report table (i.e. minidumps), named by the sha256 of their content
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTooLarge is returned by Write for content larger than its max
+var ErrTooLarge = errors.New("blob too large")
+
+// ErrNotFound is returned by Open for ids which are not stored
+var ErrNotFound = errors.New("blob not found")
+
+var idPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Dir stores blobs as files of a directory, which may be shared by every instance (i.e. a network mount)
+type Dir struct {
+	path string
+}
+
+// NewDir stores blobs in path, creating it if needed
+func NewDir(path string) (*Dir, error) {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, errors.Wrap(err, "could not create blob directory")
+	}
+	return &Dir{path: path}, nil
+}
+
+// Write stores the content of r, of at most max bytes, returning its id & size, and the stored file opened for
+// reading, which the caller closes. Identical content is stored once.
+func (d *Dir) Write(r io.Reader, max int64) (id string, size int64, f *os.File, err error) {
+	tmp, err := ioutil.TempFile(d.path, ".upload-")
+	if err != nil {
+		return "", 0, nil, errors.Wrap(err, "could not create blob")
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	h := sha256.New()
+	if size, err = io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, max+1)); err != nil {
+		return "", 0, nil, errors.Wrap(err, "could not write blob")
+	}
+	if size > max {
+		return "", 0, nil, ErrTooLarge
+	}
+	if err = tmp.Sync(); err != nil {
+		return "", 0, nil, errors.Wrap(err, "could not write blob")
+	}
+	id = hex.EncodeToString(h.Sum(nil))
+	if err = os.Rename(tmp.Name(), d.file(id)); err != nil {
+		return "", 0, nil, errors.Wrap(err, "could not store blob")
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, nil, errors.Wrap(err, "could not read blob")
+	}
+	return id, size, tmp, nil
+}
+
+// Open opens the blob for reading, or fails with ErrNotFound
+func (d *Dir) Open(id string) (*os.File, error) {
+	if !idPattern.MatchString(id) {
+		return nil, ErrNotFound
+	}
+	f, err := os.Open(d.file(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, errors.Wrap(err, "could not open blob")
+}
+
+// Remove deletes the blob, if stored
+func (d *Dir) Remove(id string) error {
+	if !idPattern.MatchString(id) {
+		return nil
+	}
+	if err := os.Remove(d.file(id)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "could not remove blob")
+	}
+	return nil
+}
+
+func (d *Dir) file(id string) string {
+	return filepath.Join(d.path, id)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -13,18 +14,25 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"strings"
+	"time"
 )
 
-const baseurl = "http://127.0.0.1:3333"
+const baseurl = "http://127.0.0.1:3333/v1"
 
 var (
-	key, gid, slvl, ghUser, ghToken, jwt, cert string
-	stype                                      = -1
-	ALL                                        = false
-	delReq                                     = false
-	err                                        error
+	key, gid, slvl, ghUser, ghToken, jwt, cert, release string
+	comment, commentID, resolution                      string
+	mirror                                              = false
+	commentsReq                                         = false
+	tailReq                                             = false
+	statsReq                                            = false
+	stype                                               = -1
+	ALL                                                 = false
+	delReq                                              = false
+	err                                                 error
 )
 
 func init() {
@@ -43,6 +51,7 @@ func init() {
 	flag.StringVar(&key, "k", "", "the report key to lookup")
 	flag.StringVar(&gid, "g", "", "the report group id to lookup")
 	flag.StringVar(&cert, "c", "", "the mss application certificate to add/remove")
+	flag.StringVar(&release, "r", "", "the application release an added certificate is minted for")
 
 	// corresponding long flags
 	flag.StringVar(&ghUser, "user", "", "your github username (for token requests)")
@@ -52,6 +61,15 @@ func init() {
 	flag.StringVar(&gid, "gid", "", "the report group id to lookup")
 	flag.BoolVar(&delReq, "Delete", false, "when set, the reports found will be deleted")
 	flag.StringVar(&cert, "certificate", "", "the mss application certificate to add/remove")
+	flag.StringVar(&release, "release", "", "the application release an added certificate is minted for")
+	flag.StringVar(&comment, "comment", "", "markdown comment to add to the group given by -gid")
+	flag.StringVar(&commentID, "commentID", "", "when set with -comment, the id of your comment to replace")
+	flag.BoolVar(&mirror, "mirror", false, "when set with -comment, the comment is also posted to the group's github issue")
+	flag.BoolVar(&commentsReq, "comments", false, "list the comments on the group given by -gid (also listed after the group's reports)")
+	flag.BoolVar(&tailReq, "tail", false, "follow reports as they arrive (like tail -f), filtered by -gid prefix and -severity")
+	flag.StringVar(&slvl, "severity", "", "the report severity (bug, crash) to follow with -tail")
+	flag.BoolVar(&statsReq, "stats", false, "chart the number of reports received recently")
+	flag.StringVar(&resolution, "resolution", "hour", "the time bucket (hour, day) to chart with -stats")
 
 	flag.Parse()
 }
@@ -87,6 +105,38 @@ func main() {
 		return
 	}
 
+	if tailReq {
+		if err := tail(tc); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "could not follow reports: %v\n", err.Error())
+			os.Exit(2)
+		}
+		return
+	}
+
+	if statsReq {
+		if err := chartStats(tc); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "could not chart stats: %v\n", err.Error())
+			os.Exit(2)
+		}
+		return
+	}
+
+	if comment != "" {
+		if err := commentRequest(tc); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "could not complete comment request: %v\n", err.Error())
+			os.Exit(2)
+		}
+		return
+	}
+
+	if commentsReq {
+		if err := listComments(tc); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "could not list comments: %v\n", err.Error())
+			os.Exit(2)
+		}
+		return
+	}
+
 	url := url()
 	if url == "" {
 		os.Exit(0)
@@ -118,6 +168,11 @@ func main() {
 		os.Exit(6)
 	}
 	_, _ = pretty.Printf("%+v", string(blah))
+	if gid != "" && key == "" && !delReq {
+		if err := listComments(tc); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "could not list comments: %v\n", err.Error())
+		}
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
 		_ = fmt.Errorf("Could not decode response from server: %v\n", err.Error())
 		os.Exit(5)
@@ -154,6 +209,9 @@ func certRequest(tc *http.Client) (err error) {
 		req, err = http.NewRequest(http.MethodDelete, url, nil)
 		expected = http.StatusNoContent
 	} else {
+		if release != "" {
+			url += "?release=" + neturl.QueryEscape(release)
+		}
 		req, err = http.NewRequest(http.MethodPost, url, nil)
 		expected = http.StatusCreated
 	}
@@ -170,6 +228,188 @@ func certRequest(tc *http.Client) (err error) {
 	return nil
 }
 
+// commentRequest adds (or with commentID, edits) a comment on the group gid
+func commentRequest(tc *http.Client) error {
+	if gid == "" {
+		return errors.New("comments require a group id (-gid)")
+	}
+	b, err := body(map[string]interface{}{
+		"body":   comment,
+		"mirror": mirror,
+	})
+	if err != nil {
+		return err
+	}
+	var req *http.Request
+	var expected int
+	url := commentsURL()
+	if commentID != "" {
+		req, err = http.NewRequest(http.MethodPut, url+commentID+"/", b)
+		expected = http.StatusOK
+	} else {
+		req, err = http.NewRequest(http.MethodPost, url, b)
+		expected = http.StatusCreated
+	}
+	if err != nil {
+		return err
+	}
+	resp, err := tc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expected {
+		return errors.New(fmt.Sprintf("unexpected response code %v in comment response", resp.StatusCode))
+	}
+	c := map[string]interface{}{}
+	if err := json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		return err
+	}
+	_, _ = pretty.Printf("Comment:\n%+v\n", c)
+	return nil
+}
+
+// listComments prints the comments on the group gid, oldest first
+func listComments(tc *http.Client) error {
+	if gid == "" {
+		return errors.New("comments require a group id (-gid)")
+	}
+	resp, err := tc.Get(commentsURL())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("unexpected response code %v in comments response", resp.StatusCode))
+	}
+	var cs []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&cs); err != nil {
+		return err
+	}
+	_, _ = pretty.Printf("Comments:\n%+v\n", cs)
+	return nil
+}
+
+// tail prints reports from the server's live stream as they arrive, reconnecting (and resuming from the last
+// report printed) whenever the connection drops, until the server refuses the stream
+func tail(tc *http.Client) error {
+	lastID := ""
+	for {
+		retry, err := follow(tc, &lastID)
+		if !retry {
+			return err
+		}
+		log.Printf("stream disconnected (%v), reconnecting...", err)
+		time.Sleep(3 * time.Second)
+	}
+}
+
+// follow reads one connection of the report stream, retry is false if reconnecting would not help
+func follow(tc *http.Client, lastID *string) (retry bool, err error) {
+	q := neturl.Values{}
+	if gid != "" {
+		q.Set("gid", gid)
+	}
+	if slvl != "" {
+		q.Set("severity", slvl)
+	}
+	req, err := http.NewRequest(http.MethodGet, baseurl+"/report/stream?"+q.Encode(), nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastID != "" {
+		req.Header.Set("Last-Event-ID", *lastID)
+	}
+	resp, err := tc.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.New(fmt.Sprintf("unexpected response code %v in stream response", resp.StatusCode))
+	}
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	id, event, data := "", "", new(strings.Builder)
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "" && event == "reset":
+			log.Printf("could not resume the stream (the server restarted, or another instance answered): reports may have been missed")
+			*lastID = id
+			event = ""
+			data.Reset()
+		case line == "": // end of event
+			if data.Len() > 0 {
+				rpt := map[string]interface{}{}
+				if err := json.Unmarshal([]byte(data.String()), &rpt); err != nil {
+					log.Printf("could not decode streamed report: %v", err.Error())
+				} else {
+					_, _ = pretty.Printf("%+v\n", rpt)
+				}
+				*lastID = id
+			}
+			data.Reset()
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "id:"):
+			id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return true, err
+	}
+	return true, io.EOF
+}
+
+// chartStats prints a bar chart of the reports received in each recent time bucket, and the fastest growing groups
+func chartStats(tc *http.Client) error {
+	resp, err := tc.Get(baseurl + "/stats/?resolution=" + neturl.QueryEscape(resolution))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("unexpected response code %v in stats response", resp.StatusCode))
+	}
+	var st struct {
+		Buckets []struct {
+			Start time.Time `json:"start"`
+			Total int       `json:"total"`
+		} `json:"buckets"`
+		Top []struct {
+			GID    string `json:"gid"`
+			Count  int    `json:"count"`
+			Growth int    `json:"growth"`
+		} `json:"top"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+		return err
+	}
+	const width = 50
+	max := 1
+	for _, b := range st.Buckets {
+		if b.Total > max {
+			max = b.Total
+		}
+	}
+	layout := "Jan 02 15:04"
+	if resolution == "day" {
+		layout = "Jan 02"
+	}
+	for _, b := range st.Buckets {
+		fmt.Printf("%-12v %6d %v\n", b.Start.Local().Format(layout), b.Total, strings.Repeat("#", b.Total*width/max))
+	}
+	fmt.Println("\nTop groups by growth:")
+	for _, g := range st.Top {
+		fmt.Printf("  %-40v %6d (%+d)\n", g.GID, g.Count, g.Growth)
+	}
+	return nil
+}
+
 func body(data map[string]interface{}) (io.Reader, error) {
 	b := new(bytes.Buffer)
 	if len(data) == 0 {
@@ -189,6 +429,11 @@ func method() string {
 	return http.MethodGet
 }
 
+// commentsURL is the path of the comments on the group gid
+func commentsURL() string {
+	return baseurl + "/report/group/" + gid + "/comments/"
+}
+
 func url() string {
 	url := baseurl + "/report"
 	if ALL {
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go_report/auth"
+	"go_report/domain"
+	"go_report/failure"
+	"go_report/gh"
+	"go_report/logging"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CommentRequest is the body of a request to add or edit a comment
+type CommentRequest struct {
+	Body   string `json:"body"`             // markdown
+	Mirror bool   `json:"mirror,omitempty"` // also post the comment to the group's github issue
+}
+
+// commentMirrorer stores comments, finding the github issue of their group to mirror them to
+type commentMirrorer interface {
+	domain.CommentStorer
+	domain.GroupStorer
+}
+
+// GetCommentsHandler lists the comments on the group in context, oldest first
+func GetCommentsHandler(s domain.CommentStorer) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gid := r.Context().Value(string(ReportGIDVar)).(string)
+		comments, err := s.SelectComments(r.Context(), gid)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(comments); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode comments json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// PostCommentHandler adds the requesting developer's comment to the group in context
+func PostCommentHandler(s commentMirrorer, ghs *gh.Service) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ghs := ghs.WithContext(r.Context())
+		gid := r.Context().Value(string(ReportGIDVar)).(string)
+		cr, err := decodeCommentRequest(r)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		c := domain.Comment{
+			GID:       gid,
+			Author:    auth.GHUserFromContext(r.Context()),
+			Body:      cr.Body,
+			CreatedOn: time.Now(),
+		}
+		if cr.Mirror {
+			c.IssueCommentID = mirrorComment(r.Context(), s, ghs, logging.FromContext(r.Context()), c)
+		}
+		if c, err = s.NewComment(r.Context(), c); err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(c); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode comment json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// EditCommentHandler replaces the body of the comment in context, which only its author may do
+func EditCommentHandler(s commentMirrorer, ghs *gh.Service) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ghs := ghs.WithContext(r.Context())
+		gid, id := r.Context().Value(string(ReportGIDVar)).(string), r.Context().Value(string(CommentIDVar)).(string)
+		cr, err := decodeCommentRequest(r)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		c, err := s.SelectComment(r.Context(), gid, id)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		} else if c == nil {
+			failure.Fail(w, r, failure.New(errors.Errorf("no comment %v on group %v", id, gid), http.StatusNotFound, "comment not found"))
+			return
+		}
+		if user := auth.GHUserFromContext(r.Context()); user != c.Author {
+			failure.Fail(w, r, failure.New(errors.Errorf("%v cannot edit comment by %v", user, c.Author), http.StatusForbidden, "only the author of a comment may edit it"))
+			return
+		}
+		c.Body, c.EditedOn = cr.Body, time.Now()
+		if c.IssueCommentID != 0 {
+			if err := ghs.EditIssueComment(c.IssueCommentID, mirrorBody(*c)); err != nil {
+				logging.FromContext(r.Context()).Warn("failed to edit mirrored comment", "gid", gid, "id", id, "err", err)
+			}
+		} else if cr.Mirror {
+			c.IssueCommentID = mirrorComment(r.Context(), s, ghs, logging.FromContext(r.Context()), *c)
+		}
+		updated, err := s.UpdateComment(r.Context(), *c)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(updated); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode comment json to http writer response stream"))
+			return
+		}
+	})
+}
+
+func decodeCommentRequest(r *http.Request) (CommentRequest, error) {
+	cr := CommentRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&cr); err != nil {
+		return cr, failure.New(err, http.StatusBadRequest, "Could not decode comment from request body")
+	}
+	if strings.TrimSpace(cr.Body) == "" {
+		return cr, failure.New(errors.New("empty comment body"), http.StatusBadRequest, "comment body must not be empty")
+	}
+	return cr, nil
+}
+
+// mirrorComment posts the comment to the github issue linked to its group, returning the issue comment's id.
+// Mirroring is best effort, so failures are logged and 0 returned.
+func mirrorComment(ctx context.Context, s domain.GroupStorer, ghs *gh.Service, logger *logging.Logger, c domain.Comment) int64 {
+	grp, err := s.SelectGroupInfo(ctx, c.GID)
+	if err != nil {
+		logger.Warn("failed to find github issue to mirror comment", "gid", c.GID, "err", err)
+		return 0
+	} else if grp.IssueNumber == 0 {
+		logger.Info("not mirroring comment, group has no github issue", "gid", c.GID)
+		return 0
+	}
+	id, err := ghs.CreateIssueComment(grp.IssueNumber, mirrorBody(c))
+	if err != nil {
+		logger.Warn("failed to mirror comment", "gid", c.GID, "err", err)
+		return 0
+	}
+	return id
+}
+
+func mirrorBody(c domain.Comment) string {
+	return fmt.Sprintf("**@%v** commented on report group `%v`:\n\n%v", c.Author, c.GID, c.Body)
+}
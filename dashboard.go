@@ -0,0 +1,257 @@
+package main
+
+import "net/http"
+
+// DashboardHandler serves a single page app for developers to browse groups & reports, and manage certificates.
+// It signs in through the /v1/token exchange, and uses only the dev-only api routes.
+func DashboardHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+		_, _ = w.Write([]byte(dashboardPage))
+	})
+}
+
+const dashboardPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>go_report dashboard</title>
+<style>
+body { font-family: sans-serif; margin: 0; color: #222; }
+header { display: flex; align-items: center; gap: 1em; padding: .6em 1em; background: #24292f; color: #fff; }
+header h1 { font-size: 1.1em; margin: 0; flex: 1; }
+header a { color: #fff; cursor: pointer; }
+main { padding: 1em; }
+.hidden { display: none; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; vertical-align: top; padding: .3em .6em; border-bottom: 1px solid #ddd; }
+tr.link { cursor: pointer; }
+tr.link:hover { background: #f6f8fa; }
+pre { background: #f6f8fa; padding: .6em; margin: .3em 0; overflow-x: auto; white-space: pre-wrap; }
+form { margin: .6em 0; }
+input { margin-right: .4em; }
+button { margin-right: .3em; }
+.error { color: #cf222e; }
+.status-open { color: #9a6700; } .status-regressed { color: #cf222e; } .status-resolved { color: #1a7f37; } .status-ignored { color: #888; }
+.report { border: 1px solid #ddd; border-radius: 4px; padding: .6em; margin: .6em 0; }
+</style>
+</head>
+<body>
+<header>
+<h1>go_report</h1>
+<nav id="nav" class="hidden"><a id="nav-groups">groups</a> · <a id="nav-certs">certificates</a> · <a id="nav-logout">sign out</a></nav>
+</header>
+<main>
+<p id="error" class="error"></p>
+
+<section id="login">
+<h2>Sign in</h2>
+<p>Exchange your GitHub username and an oauth token with repository access for a developer token.</p>
+<form id="login-form">
+<input id="login-user" placeholder="github user" required>
+<input id="login-token" type="password" placeholder="github token" required>
+<button>Sign in</button>
+</form>
+</section>
+
+<section id="groups" class="hidden">
+<h2>Groups</h2>
+<form id="groups-form">
+<select id="groups-status"><option value="">any status</option><option>open</option><option>regressed</option><option>resolved</option><option>ignored</option></select>
+<select id="groups-sort"><option value="lastSeen">last seen</option><option value="count">count</option></select>
+<button>Refresh</button>
+</form>
+<table><thead><tr><th>group</th><th>status</th><th>count</th><th>bugs</th><th>crashes</th><th>first seen</th><th>last seen</th><th>last release</th></tr></thead>
+<tbody id="groups-rows"></tbody></table>
+</section>
+
+<section id="group" class="hidden">
+<h2 id="group-title"></h2>
+<p id="group-summary"></p>
+<p>
+<button data-action="resolve">Resolve</button><button data-action="ignore">Ignore</button><button data-action="reopen">Reopen</button>
+<button id="group-csv">Download csv</button>
+</p>
+<h3>Comments</h3>
+<div id="group-comments"></div>
+<h3>Reports</h3>
+<div id="group-reports"></div>
+</section>
+
+<section id="certs" class="hidden">
+<h2>Certificates</h2>
+<p>Applications exchange a certificate for a token to send reports. Certificates cannot be listed, only added or removed.</p>
+<form id="cert-add">
+<input id="cert-add-value" placeholder="certificate" required>
+<input id="cert-add-release" placeholder="release (optional)">
+<button>Add</button>
+</form>
+<form id="cert-remove">
+<input id="cert-remove-value" placeholder="certificate" required>
+<button>Remove</button>
+</form>
+<p id="cert-result"></p>
+</section>
+</main>
+<script>
+var api = "/v1";
+var severities = ["unknown", "bug", "crash"];
+
+function $(id) { return document.getElementById(id); }
+
+function el(tag, text, cls) {
+	var e = document.createElement(tag);
+	if (text !== undefined && text !== null) { e.textContent = String(text); }
+	if (cls) { e.className = cls; }
+	return e;
+}
+
+function when(t) { return t ? new Date(t).toLocaleString() : ""; }
+
+function showError(err) { $("error").textContent = err ? String(err.message || err) : ""; }
+
+function show(id) {
+	["login", "groups", "group", "certs"].forEach(function (s) { $(s).classList.toggle("hidden", s !== id); });
+	$("nav").classList.toggle("hidden", id === "login");
+	showError();
+}
+
+function request(method, path, body) {
+	var headers = {"Authorization": "Bearer " + sessionStorage.getItem("jwt")};
+	if (body !== undefined) { headers["Content-Type"] = "application/json"; }
+	return fetch(api + path, {method: method, headers: headers, body: body === undefined ? undefined : JSON.stringify(body)}).then(function (r) {
+		if (r.status === 401) {
+			sessionStorage.removeItem("jwt");
+			show("login");
+			throw new Error("Your session has expired, sign in again");
+		}
+		if (!r.ok) {
+			return r.json().then(function (e) { throw new Error(e.userMessage || r.statusText); }, function () { throw new Error(r.statusText); });
+		}
+		var ct = r.headers.get("Content-Type") || "";
+		return r.status === 204 || ct.indexOf("application/json") < 0 ? r.text() : r.json();
+	});
+}
+
+$("login-form").onsubmit = function (e) {
+	e.preventDefault();
+	fetch(api + "/token/", {method: "POST", body: JSON.stringify({ghUser: $("login-user").value, ghToken: $("login-token").value})}).then(function (r) {
+		return r.text().then(function (t) {
+			if (!r.ok) { throw new Error("Sign in failed: " + r.statusText); }
+			return t;
+		});
+	}).then(function (jwt) {
+		sessionStorage.setItem("jwt", jwt.trim());
+		$("login-token").value = "";
+		loadGroups();
+	}).catch(showError);
+};
+
+$("nav-groups").onclick = function () { loadGroups(); };
+$("nav-certs").onclick = function () { show("certs"); };
+$("nav-logout").onclick = function () { sessionStorage.removeItem("jwt"); show("login"); };
+$("groups-form").onsubmit = function (e) { e.preventDefault(); loadGroups(); };
+
+function loadGroups() {
+	var q = "?sort=" + encodeURIComponent($("groups-sort").value);
+	if ($("groups-status").value) { q += "&status=" + encodeURIComponent($("groups-status").value); }
+	request("GET", "/group/" + q).then(function (groups) {
+		show("groups");
+		var rows = $("groups-rows");
+		rows.textContent = "";
+		(groups || []).forEach(function (g) {
+			var tr = el("tr", null, "link"), counts = g.severityCounts || {};
+			[g.gid, g.status, g.count, counts.bug || 0, counts.crash || 0, when(g.firstSeen), when(g.lastSeen), g.lastRelease].forEach(function (v, i) {
+				tr.appendChild(el("td", v, i === 1 ? "status-" + g.status : ""));
+			});
+			tr.onclick = function () { loadGroup(g); };
+			rows.appendChild(tr);
+		});
+		if (!groups || groups.length === 0) { rows.appendChild(el("tr")).appendChild(el("td", "No groups")); }
+	}).catch(showError);
+}
+
+function groupPath(gid) { return "/report/group/" + encodeURIComponent(gid); }
+
+function loadGroup(g) {
+	Promise.all([request("GET", groupPath(g.gid) + "/"), request("GET", groupPath(g.gid) + "/comments/")]).then(function (res) {
+		var gr = {reports: res[0], comments: res[1]};
+		show("group");
+		$("group-title").textContent = g.gid;
+		$("group-summary").textContent = g.status + " · " + g.count + " reports · last seen " + when(g.lastSeen) + (g.lastRelease ? " in " + g.lastRelease : "");
+		$("group-summary").className = "status-" + g.status;
+		$("group-csv").onclick = function () {
+			request("GET", groupPath(g.gid) + ".csv").then(function (csv) {
+				var a = el("a");
+				a.href = URL.createObjectURL(new Blob([csv], {type: "text/csv"}));
+				a.download = g.gid + ".csv";
+				a.click();
+				URL.revokeObjectURL(a.href);
+			}).catch(showError);
+		};
+		Array.prototype.forEach.call(document.querySelectorAll("#group button[data-action]"), function (b) {
+			b.onclick = function () {
+				request("POST", groupPath(g.gid) + "/" + b.getAttribute("data-action")).then(loadGroup).catch(showError);
+			};
+		});
+
+		var comments = $("group-comments");
+		comments.textContent = "";
+		(gr.comments || []).forEach(function (c) {
+			var d = el("div", null, "report");
+			d.appendChild(el("strong", c.author));
+			d.appendChild(el("span", " " + when(c.createdOn) + (c.editedOn ? " (edited)" : "")));
+			d.appendChild(el("pre", c.body));
+			comments.appendChild(d);
+		});
+		if (!gr.comments || gr.comments.length === 0) { comments.appendChild(el("p", "No comments")); }
+
+		var reports = $("group-reports");
+		reports.textContent = "";
+		(gr.reports || []).forEach(function (rpt) {
+			var d = el("div", null, "report"), del = el("button", "Delete");
+			d.appendChild(el("strong", severities[rpt.severity] || "unknown"));
+			d.appendChild(el("span", " " + when(rpt.receivedOn) + (rpt.release ? " · " + rpt.release : "") + " · " + rpt.key + " "));
+			del.onclick = function () {
+				if (!confirm("Delete report " + rpt.key + "?")) { return; }
+				request("DELETE", groupPath(g.gid) + "/key/" + encodeURIComponent(rpt.key) + "/").then(function () {
+					reports.removeChild(d);
+				}).catch(showError);
+			};
+			d.appendChild(del);
+			if (rpt.tags) {
+				d.appendChild(el("div", Object.keys(rpt.tags).sort().map(function (t) { return t + "=" + rpt.tags[t]; }).join("  ")));
+			}
+			d.appendChild(el("pre", JSON.stringify(rpt.content, null, 2)));
+			reports.appendChild(d);
+		});
+		if (!gr.reports || gr.reports.length === 0) { reports.appendChild(el("p", "No reports")); }
+	}).catch(showError);
+}
+
+$("cert-add").onsubmit = function (e) {
+	e.preventDefault();
+	var cert = $("cert-add-value").value, release = $("cert-add-release").value;
+	var q = release ? "?release=" + encodeURIComponent(release) : "";
+	request("POST", "/certificate/" + encodeURIComponent(cert) + "/" + q).then(function (res) {
+		$("cert-result").textContent = "Added certificate" + (release ? " for release " + release : "") + ". Upload token (?key= of minidump uploads): " + res.uploadToken + ". Sentry DSN key: " + res.dsnKey;
+		$("cert-add").reset();
+	}).catch(showError);
+};
+
+$("cert-remove").onsubmit = function (e) {
+	e.preventDefault();
+	var cert = $("cert-remove-value").value;
+	if (!confirm("Remove this certificate? Applications using it can no longer send reports.")) { return; }
+	request("DELETE", "/certificate/" + encodeURIComponent(cert) + "/").then(function () {
+		$("cert-result").textContent = "Removed certificate";
+		$("cert-remove").reset();
+	}).catch(showError);
+};
+
+if (sessionStorage.getItem("jwt")) { loadGroups(); } else { show("login"); }
+</script>
+</body>
+</html>
+`
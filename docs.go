@@ -0,0 +1,122 @@
+package main
+
+import "net/http"
+
+// DocsHandler serves a page rendering the OpenAPI document, without depending on any external assets
+func DocsHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(docsPage))
+	})
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>go_report API</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 60em; color: #222; }
+h2 { border-bottom: 1px solid #ccc; text-transform: capitalize; }
+details { margin: .4em 0; border: 1px solid #ddd; border-radius: 4px; }
+summary { padding: .4em; cursor: pointer; }
+.method { display: inline-block; width: 5em; font-weight: bold; font-family: monospace; }
+.get { color: #1a7f37; } .post { color: #0969da; } .put { color: #9a6700; } .delete { color: #cf222e; }
+.path { font-family: monospace; }
+.deprecated .path { text-decoration: line-through; }
+.body { padding: 0 1em 1em; }
+table { border-collapse: collapse; }
+td, th { text-align: left; padding: .2em .6em; border-bottom: 1px solid #eee; vertical-align: top; }
+pre { background: #f6f8fa; padding: .6em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>go_report API</h1>
+<p id="description"></p>
+<p>The machine readable document is served at <a href="/openapi.json">/openapi.json</a>.</p>
+<div id="operations"></div>
+<h2>Schemas</h2>
+<div id="schemas"></div>
+<script>
+function el(tag, text, cls) {
+	var e = document.createElement(tag);
+	if (text) { e.textContent = text; }
+	if (cls) { e.className = cls; }
+	return e;
+}
+
+function schemaName(s) {
+	if (!s) { return ""; }
+	if (s.$ref) { return s.$ref.split("/").pop(); }
+	if (s.type === "array") { return schemaName(s.items) + "[]"; }
+	return s.type || "";
+}
+
+function table(headers, rows) {
+	var t = el("table"), tr = el("tr");
+	headers.forEach(function (h) { tr.appendChild(el("th", h)); });
+	t.appendChild(tr);
+	rows.forEach(function (r) {
+		var tr = el("tr");
+		r.forEach(function (c) { tr.appendChild(el("td", c)); });
+		t.appendChild(tr);
+	});
+	return t;
+}
+
+function operation(path, method, op) {
+	var d = el("details", "", op.deprecated ? "deprecated" : ""), s = el("summary"), body = el("div", "", "body");
+	s.appendChild(el("span", method.toUpperCase(), "method " + method));
+	s.appendChild(el("span", path, "path"));
+	s.appendChild(el("span", " - " + op.summary));
+	d.appendChild(s);
+	if (op.description) { body.appendChild(el("p", op.description)); }
+	if (op.parameters) {
+		body.appendChild(el("h4", "Parameters"));
+		body.appendChild(table(["name", "in", "type", "description"], op.parameters.map(function (p) {
+			return [p.name, p.in, schemaName(p.schema), p.description || ""];
+		})));
+	}
+	if (op.requestBody) {
+		var rb = op.requestBody.content["application/json"];
+		body.appendChild(el("h4", "Request body"));
+		body.appendChild(el("p", "application/json: " + schemaName(rb.schema)));
+	}
+	body.appendChild(el("h4", "Responses"));
+	body.appendChild(table(["status", "description", "content"], Object.keys(op.responses).map(function (code) {
+		var r = op.responses[code], content = r.content ? Object.keys(r.content).map(function (ct) {
+			return ct + ": " + schemaName(r.content[ct].schema);
+		}).join(", ") : "";
+		return [code, r.description, content];
+	})));
+	d.appendChild(body);
+	return d;
+}
+
+fetch("/openapi.json").then(function (r) { return r.json(); }).then(function (spec) {
+	document.getElementById("description").textContent = spec.info.description;
+	var byTag = {}, ops = document.getElementById("operations");
+	Object.keys(spec.paths).sort().forEach(function (path) {
+		Object.keys(spec.paths[path]).forEach(function (method) {
+			var op = spec.paths[path][method], tag = (op.tags || ["other"])[0];
+			(byTag[tag] = byTag[tag] || []).push(operation(path, method, op));
+		});
+	});
+	Object.keys(byTag).forEach(function (tag) {
+		ops.appendChild(el("h2", tag));
+		byTag[tag].forEach(function (d) { ops.appendChild(d); });
+	});
+	var schemas = document.getElementById("schemas");
+	Object.keys(spec.components.schemas).sort().forEach(function (name) {
+		var d = el("details"), s = el("summary", name, "path");
+		d.appendChild(s);
+		d.appendChild(el("pre", JSON.stringify(spec.components.schemas[name], null, 2)));
+		schemas.appendChild(d);
+	});
+}).catch(function (err) {
+	document.getElementById("operations").textContent = "Could not load /openapi.json: " + err;
+});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type CommentStorer interface {
+	NewComment(ctx context.Context, c Comment) (Comment, error)          // Create a comment on c.GID, assigning its ID
+	UpdateComment(ctx context.Context, c Comment) (Comment, error)       // Replace the body (and mirror) of an existing comment
+	SelectComment(ctx context.Context, gid, id string) (*Comment, error) // Select one comment, nil if it does not exist
+	SelectComments(ctx context.Context, gid string) ([]Comment, error)   // Select every comment on a group, oldest first
+}
+
+// Comment is a developer's markdown note on a report group
+type Comment struct {
+	ID        string    `json:"id"`
+	GID       string    `json:"gid"`
+	Author    string    `json:"author"` // github user
+	Body      string    `json:"body"`   // markdown
+	CreatedOn time.Time `json:"createdOn"`
+	EditedOn  time.Time `json:"editedOn"` // zero if never edited
+	// id of the comment mirroring this one on the group's github issue, 0 if not mirrored
+	IssueCommentID int64 `json:"issueCommentID,omitempty"`
+}
+
+// NewCommentID returns an id which sorts in order of creation time
+func NewCommentID(t time.Time) string {
+	return fmt.Sprintf("%016x", t.UnixNano())
+}
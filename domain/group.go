@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GroupStorer keeps the metadata record of each report group, separate from the reports themselves
+type GroupStorer interface {
+	SelectGroupInfo(ctx context.Context, gid string) (*Group, error)                               // Select the metadata of one group, open if never recorded
+	SelectAllGroupInfo(ctx context.Context) ([]Group, error)                                       // Select the metadata of every recorded group
+	SetGroupStatus(ctx context.Context, gid string, status GroupStatus, by string) (*Group, error) // Record a status change made by the github user `by`, failing 404 for groups never reported
+	RegressGroup(ctx context.Context, gid string, release string) (*Group, error)                  // Move a resolved group to regressed, returning it, or nil if it was not resolved
+	SetGroupIssue(ctx context.Context, gid string, number int) error                               // Link the group to a github issue
+}
+
+type GroupStatus string
+
+const (
+	StatusOpen      GroupStatus = "open"
+	StatusResolved  GroupStatus = "resolved"
+	StatusIgnored   GroupStatus = "ignored"
+	StatusRegressed GroupStatus = "regressed"
+)
+
+// Group is the metadata for all reports sharing a GID
+type Group struct {
+	GID      string      `json:"gid"`
+	Status   GroupStatus `json:"status"`
+	StatusBy string      `json:"statusBy,omitempty"` // github user which last changed the status (empty if automatic)
+	StatusOn time.Time   `json:"statusOn"`
+
+	// Summary of the group's reports, maintained by the Storer as each report is added
+	FirstSeen      time.Time      `json:"firstSeen"`
+	LastSeen       time.Time      `json:"lastSeen"`
+	Count          int            `json:"count"`
+	SeverityCounts map[string]int `json:"severityCounts"` // ReportType.String() -> # of reports
+	LatestKey      string         `json:"latestKey"`
+
+	// Releases in which the group was first & last reported, and the releases tied to its resolution
+	FirstRelease string `json:"firstRelease,omitempty"`
+	LastRelease  string `json:"lastRelease,omitempty"`
+	ResolvedIn   string `json:"resolvedIn,omitempty"`  // the last release reported before the group was resolved
+	RegressedIn  string `json:"regressedIn,omitempty"` // the release which reported the group again after its resolution
+
+	IssueNumber int `json:"issueNumber,omitempty"` // the github issue most recently opened for the group, 0 if none
+}
+
+// RegressedBy is true if a new report from release should reopen the group. Reports from the release the
+// group was resolved in (or earlier) are expected, since those builds still carry the bug.
+func (g Group) RegressedBy(release string) bool {
+	if g.Status != StatusResolved {
+		return false
+	}
+	if release == "" || g.ResolvedIn == "" {
+		return true
+	}
+	return CompareReleases(release, g.ResolvedIn) > 0
+}
+
+// CountAtLeast is the number of the group's reports of severity sev or higher
+func (g Group) CountAtLeast(sev ReportType) int {
+	n := 0
+	for s, c := range g.SeverityCounts {
+		if ConvertSeverityLevelString(s) >= sev {
+			n += c
+		}
+	}
+	return n
+}
+
+// sort orders for group listings
+const (
+	SortByLastSeen = "lastSeen"
+	SortByCount    = "count"
+)
+
+// SortGroups orders groups by the given sort key, most recent or most frequent first. False if the key is unknown.
+func SortGroups(groups []Group, by string) bool {
+	switch by {
+	case SortByLastSeen:
+		sort.SliceStable(groups, func(i, j int) bool { return groups[i].LastSeen.After(groups[j].LastSeen) })
+	case SortByCount:
+		sort.SliceStable(groups, func(i, j int) bool { return groups[i].Count > groups[j].Count })
+	default:
+		return false
+	}
+	return true
+}
+
+// NewGroup returns the metadata for a group which has never had its status changed
+func NewGroup(gid string) Group {
+	return Group{GID: gid, Status: StatusOpen, SeverityCounts: map[string]int{}}
+}
+
+func ConvertGroupStatusString(st string) (GroupStatus, bool) {
+	switch s := GroupStatus(strings.ToLower(st)); s {
+	case StatusOpen, StatusResolved, StatusIgnored, StatusRegressed:
+		return s, true
+	default:
+		return "", false
+	}
+}
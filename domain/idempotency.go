@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type IdempotencyStorer interface {
+	// Claim rec.Key until rec.Expires, unless it is already claimed; then held is the current claim
+	ClaimIdempotencyKey(ctx context.Context, rec IdempotencyRecord) (claimed bool, held IdempotencyRecord, err error)
+	CompleteIdempotencyKey(ctx context.Context, key string, rcpt Receipt) error // Remember the receipt of a claimed key's request
+	ReleaseIdempotencyKey(ctx context.Context, key string) error                // Forget a claimed key whose request failed, so it may be retried
+}
+
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyRecord remembers the outcome of a request sent with an Idempotency-Key, so its retries are not repeated
+type IdempotencyRecord struct {
+	Key         string    `json:"key"`
+	Fingerprint string    `json:"fingerprint"`       // of the request, so a key reused for a different report is refused
+	Receipt     *Receipt  `json:"receipt,omitempty"` // nil while the request is in progress
+	Expires     time.Time `json:"expires"`
+}
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type ReleaseStorer interface {
+	SelectReleases(ctx context.Context) ([]Release, error) // Select the summary of every release which has sent a report
+}
+
+// Release summarises the reports sent by one release (version) of an application
+type Release struct {
+	Name      string    `json:"release"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// CompareReleases orders release names as versions, numeric parts compared as numbers (i.e. 1.10 > 1.9) and
+// trailing zero parts ignored (1.0.0 == 1.0). As in semver, a pre-release (what follows the numeric parts, i.e.
+// 1.0.0-beta or 1.0rc1) is lower than its release, and build metadata (after a +) is ignored.
+// Returns -1 if a < b, 0 if a == b, and 1 if a > b.
+func CompareReleases(a, b string) int {
+	aCore, aPre := splitRelease(a)
+	bCore, bPre := splitRelease(b)
+	for i := 0; i < len(aCore) || i < len(bCore); i++ {
+		if c := compareInts(partAt(aCore, i), partAt(bCore, i)); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case aPre == nil && bPre == nil:
+		return 0
+	case aPre == nil:
+		return 1
+	case bPre == nil:
+		return -1
+	}
+	for i := 0; i < len(aPre) && i < len(bPre); i++ {
+		if c := comparePreReleaseParts(aPre[i], bPre[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(aPre), len(bPre))
+}
+
+// SortReleases orders releases newest first
+func SortReleases(rels []Release) {
+	sort.SliceStable(rels, func(i, j int) bool { return CompareReleases(rels[i].Name, rels[j].Name) > 0 })
+}
+
+// splitRelease splits a release name into its numeric parts and its pre-release parts, nil if it is not a
+// pre-release. Letters and digits are separate pre-release parts (rc10 is rc, 10), so rc2 < rc10.
+func splitRelease(rel string) (core []int, pre []string) {
+	rel = strings.TrimPrefix(strings.ToLower(rel), "v")
+	if i := strings.IndexByte(rel, '+'); i >= 0 {
+		rel = rel[:i]
+	}
+	end := strings.IndexFunc(rel, func(r rune) bool { return r != '.' && !unicode.IsDigit(r) })
+	if end < 0 {
+		end = len(rel)
+	}
+	for _, p := range strings.Split(rel[:end], ".") {
+		if n, err := strconv.Atoi(p); err == nil {
+			core = append(core, n)
+		}
+	}
+	var part []rune
+	for _, r := range rel[end:] {
+		isPart := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if len(part) > 0 && (!isPart || unicode.IsDigit(r) != unicode.IsDigit(part[0])) {
+			pre, part = append(pre, string(part)), nil
+		}
+		if isPart {
+			part = append(part, r)
+		}
+	}
+	if len(part) > 0 {
+		pre = append(pre, string(part))
+	}
+	if pre == nil && end < len(rel) {
+		pre = []string{} // i.e. 1.0- is still a pre-release
+	}
+	return core, pre
+}
+
+// comparePreReleaseParts compares numeric parts as numbers, and lower than parts with letters
+func comparePreReleaseParts(a, b string) int {
+	an, aErr := strconv.Atoi(a)
+	bn, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareInts(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func partAt(parts []int, i int) int {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
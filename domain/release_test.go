@@ -0,0 +1,63 @@
+package domain
+
+import "testing"
+
+func TestCompareReleases(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.10", "1.9", 1},
+		{"v1.2", "1.2", 0},
+		{"1.0.0", "1.0", 0},
+		{"1.0", "1.0.0.0", 0},
+		{"1.0.1", "1.0", 1},
+		{"1.0.0-beta", "1.0.0", -1},
+		{"1.0.0-beta", "1.0", -1},
+		{"1.0rc1", "1.0", -1},
+		{"1.0.0-beta", "0.9.9", 1},
+		{"1.0.1", "1.0.0-beta", 1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0-rc.2", "1.0.0-rc.10", -1},
+		{"1.0.0-rc2", "1.0.0-rc10", -1},
+		{"1.0.0-RC1", "1.0.0-rc1", 0},
+		{"1.0.0+build.5", "1.0.0", 0},
+		{"1.0.0-beta+build.5", "1.0.0", -1},
+		{"2019-07-01", "2019-07-02", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareReleases(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareReleases(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := CompareReleases(tt.b, tt.a); got != -tt.want {
+			t.Errorf("CompareReleases(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestRegressedBy(t *testing.T) {
+	resolved := Group{Status: StatusResolved, ResolvedIn: "1.0.0"}
+	tests := []struct {
+		grp     Group
+		release string
+		want    bool
+	}{
+		{resolved, "1.0.1", true},
+		{resolved, "1.1.0-beta", true},
+		{resolved, "1.0.0", false},
+		{resolved, "1.0", false},
+		{resolved, "1.0.0-beta", false},
+		{resolved, "0.9", false},
+		{resolved, "", true},
+		{Group{Status: StatusResolved}, "1.0.0", true},
+		{Group{Status: StatusOpen, ResolvedIn: "1.0.0"}, "2.0", false},
+	}
+	for _, tt := range tests {
+		if got := tt.grp.RegressedBy(tt.release); got != tt.want {
+			t.Errorf("%+v RegressedBy(%q) = %v, want %v", tt.grp, tt.release, got, tt.want)
+		}
+	}
+}
@@ -1,21 +1,39 @@
 package domain
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
 	"strings"
 	"time"
 )
 
+// Storer is the whole store, for wiring; handlers take the narrower interfaces they use
 type Storer interface {
-	NewEntry(r Report) (Receipt, error)                                        // Create a new entry in the store, return receipt
-	Select(lookup Receipt) (*Report, error)                                      // Select one record by its key
-	SelectAll() ([]Report, error)
-	SelectGroup(gid string) ([]Report, error)
-	RemoveEntry(lookup Receipt) error                                              // Erase a record from the store, or a group of records by GID
+	ReportStorer
+	GroupStorer
+	ReleaseStorer
+	TagStorer
+	CommentStorer
+	StatsStorer
+	IdempotencyStorer
+	WebhookStorer
+}
+
+// ReportStorer stores the reports themselves
+type ReportStorer interface {
+	NewEntry(ctx context.Context, r Report) (Receipt, error)     // Create a new entry in the store, return receipt
+	Select(ctx context.Context, lookup Receipt) (*Report, error) // Select one record by its key
+	SelectAll(ctx context.Context) ([]Report, error)
+	SelectGroup(ctx context.Context, gid string) ([]Report, error)
+	RemoveEntry(ctx context.Context, lookup Receipt) error // Erase a record from the store, or a group of records by GID
 }
 
 const DisableIssueCreation = -1
 
 type ReportType int
+
 const (
 	UnknownType ReportType = iota
 	BugType
@@ -24,17 +42,69 @@ const (
 
 type Report struct {
 	// The report creation request will contain these three fields
-	GID      	string                 `json:"gid"`
-	Severity 	ReportType             `json:"severity"`
-	Content  	map[string]interface{} `json:"content"`
-	Key      	string `json:"key"`
-	ReceivedOn    	time.Time		`json:"receivedOn"`
+	GID        string                 `json:"gid"`
+	Severity   ReportType             `json:"severity"`
+	Content    map[string]interface{} `json:"content"`
+	Key        string                 `json:"key"`
+	ReceivedOn time.Time              `json:"receivedOn"`
+	Release    string                 `json:"release,omitempty"` // the version of the application which sent the report
+	Tags       map[string]string      `json:"tags,omitempty"`    // client supplied key/value pairs (environment, os version, ...) see tags.go
+}
+
+// CertificateGID is the group application certificates are stored in (see msscerts), which reports may not be filed in
+const CertificateGID = "MSS_CERTIFICATE"
+
+// ValidateGID checks the report is not filed in a reserved group
+func (r Report) ValidateGID() error {
+	if r.GID == CertificateGID {
+		return fmt.Errorf("gid %v is reserved", r.GID)
+	}
+	return nil
+}
+
+// MaxStoredReport is the largest report, as json, which is stored: a DynamoDB item is at most 400KB, which must also
+// hold the item's keys & attribute names
+const MaxStoredReport = 350 << 10
+
+// ValidateSize checks the report is small enough to be stored
+func (r Report) ValidateSize() error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if len(b) > MaxStoredReport {
+		return fmt.Errorf("report is %d bytes, at most %d are allowed", len(b), MaxStoredReport)
+	}
+	return nil
 }
 
 // For sending responses to queries regarding report creation confirmation, and lookup help
 type Receipt struct {
-	GID string   `json:"gid"`// the id of the report - PARTITION KEY
-	Key string   `json:"key"` // the report's md5 hash - SORT KEY
+	GID string `json:"gid"` // the id of the report - PARTITION KEY
+	Key string `json:"key"` // the report's md5 hash - SORT KEY
+
+	NewGroup bool `json:"-"` // set by NewEntry if the report was its group's first
+}
+
+// ContentKey is the md5 hash of the report's json (without its key), which identifies the report within its group
+func (r Report) ContentKey() (string, error) {
+	r.Key = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", md5.Sum(b)), nil
+}
+
+func (t ReportType) String() string {
+	switch t {
+	case BugType:
+		return "bug"
+	case CrashType:
+		return "crash"
+	default:
+		return "unknown"
+	}
 }
 
 func ConvertSeverityLevelString(slvl string) ReportType {
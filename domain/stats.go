@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+type StatsStorer interface {
+	// Select the counts of every bucket from..to (inclusive); NewEntry counts each report
+	SelectStats(ctx context.Context, res Resolution, from, to time.Time) ([]StatCount, error)
+}
+
+// Resolution is the size of the time buckets reports are counted in
+type Resolution string
+
+const (
+	Hourly Resolution = "hour"
+	Daily  Resolution = "day"
+)
+
+var Resolutions = []Resolution{Hourly, Daily}
+
+func ConvertResolutionString(res string) (Resolution, bool) {
+	switch r := Resolution(strings.ToLower(res)); r {
+	case Hourly, Daily:
+		return r, true
+	default:
+		return "", false
+	}
+}
+
+func (res Resolution) Duration() time.Duration {
+	if res == Daily {
+		return 24 * time.Hour
+	}
+	return time.Hour
+}
+
+// Bucket returns the start of the bucket t falls in (buckets are aligned to UTC)
+func (res Resolution) Bucket(t time.Time) time.Time {
+	return t.UTC().Truncate(res.Duration())
+}
+
+// StatCount is the number of reports of one group & severity received in one time bucket
+type StatCount struct {
+	Bucket   time.Time  `json:"bucket"`
+	GID      string     `json:"gid"`
+	Severity ReportType `json:"severity"`
+	Count    int        `json:"count"`
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type TagStorer interface {
+	SelectTagged(ctx context.Context, tag, value string) ([]Report, error) // Select every report tagged with tag=value
+	SelectFacets(ctx context.Context, gid string) ([]Facet, error)         // Select the count of each tag=value reported by a group
+}
+
+// Limits on report tags, each tag is indexed as its report is stored so the number per report is bounded
+const (
+	MaxTags        = 8
+	MaxTagLength   = 64
+	MaxValueLength = 256
+)
+
+// Facet is the number of reports in a group carrying tag=value
+type Facet struct {
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// ValidateTags checks the report's tags are within limits, and can be parsed back out of a tag=value pair
+func (r Report) ValidateTags() error {
+	if len(r.Tags) > MaxTags {
+		return fmt.Errorf("report has %v tags, at most %v are allowed", len(r.Tags), MaxTags)
+	}
+	for t, v := range r.Tags {
+		switch {
+		case t == "" || len(t) > MaxTagLength:
+			return fmt.Errorf("tag names must be 1-%v characters", MaxTagLength)
+		case strings.Contains(t, "="):
+			return fmt.Errorf("tag %v must not contain '='", t)
+		case len(v) > MaxValueLength:
+			return fmt.Errorf("tag %v value must be at most %v characters", t, MaxValueLength)
+		}
+	}
+	return nil
+}
+
+// HasTags is true if the report carries every tag=value given
+func (r Report) HasTags(tags map[string]string) bool {
+	for t, v := range tags {
+		if rv, ok := r.Tags[t]; !ok || rv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTag splits a tag=value pair
+func ParseTag(pair string) (tag, value string, ok bool) {
+	i := strings.Index(pair, "=")
+	if i < 1 {
+		return "", "", false
+	}
+	return pair[:i], pair[i+1:], true
+}
+
+// TopFacets groups facets by tag, keeping the n most frequent values of each
+func TopFacets(facets []Facet, n int) map[string][]Facet {
+	byTag := map[string][]Facet{}
+	for _, f := range facets {
+		byTag[f.Tag] = append(byTag[f.Tag], f)
+	}
+	for t, fs := range byTag {
+		sort.SliceStable(fs, func(i, j int) bool { return fs[i].Count > fs[j].Count })
+		if n > 0 && len(fs) > n {
+			fs = fs[:n]
+		}
+		byTag[t] = fs
+	}
+	return byTag
+}
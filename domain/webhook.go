@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type WebhookStorer interface {
+	NewWebhook(ctx context.Context, h Webhook) (Webhook, error)               // Register a webhook, assigning its ID
+	SelectWebhook(ctx context.Context, id string) (*Webhook, error)           // Select one webhook, nil if it does not exist
+	SelectWebhooks(ctx context.Context) ([]Webhook, error)                    // Select every webhook, oldest first
+	RemoveWebhook(ctx context.Context, id string) error                       // Erase a webhook; its deliveries expire with the log
+	SaveDelivery(ctx context.Context, d Delivery) error                       // Create or replace a delivery of d.WebhookID
+	SelectDelivery(ctx context.Context, hookID, id string) (*Delivery, error) // Select one delivery, nil if it does not exist
+	SelectDeliveries(ctx context.Context, hookID string) ([]Delivery, error)  // Select the logged deliveries of a webhook, oldest first
+}
+
+// DeliveryRetention is how long a delivery is kept in the log
+const DeliveryRetention = 30 * 24 * time.Hour
+
+// WebhookEvent is a kind of event a webhook may subscribe to
+type WebhookEvent string
+
+const (
+	EventNewReport     WebhookEvent = "report.new"         // a report was stored
+	EventNewGroup      WebhookEvent = "group.new"          // the first report of a group was stored
+	EventSeverity      WebhookEvent = "severity.threshold" // the first report of a group at or above the webhook's MinSeverity was stored
+	EventGroupResolved WebhookEvent = "group.resolved"     // a developer resolved a group
+)
+
+var WebhookEvents = []WebhookEvent{EventNewReport, EventNewGroup, EventSeverity, EventGroupResolved}
+
+func ConvertWebhookEventString(ev string) (WebhookEvent, bool) {
+	for _, e := range WebhookEvents {
+		if string(e) == ev {
+			return e, true
+		}
+	}
+	return "", false
+}
+
+// Webhook is a developer's subscription to events, POSTed to URL signed with Secret
+type Webhook struct {
+	ID          string         `json:"id"`
+	URL         string         `json:"url"`
+	Events      []WebhookEvent `json:"events"`
+	MinSeverity ReportType     `json:"minSeverity"`      // of severity.threshold events
+	Secret      string         `json:"secret,omitempty"` // hmac key of the payloads, only shown when registered
+	CreatedBy   string         `json:"createdBy"`        // github user
+	CreatedOn   time.Time      `json:"createdOn"`
+}
+
+// Subscribes is true if the webhook wants events of the kind
+func (h Webhook) Subscribes(ev WebhookEvent) bool {
+	for _, e := range h.Events {
+		if e == ev {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending" // waiting for its first attempt, or a retry
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed" // out of attempts
+)
+
+// Delivery is the log of sending one event to a webhook
+type Delivery struct {
+	ID           string          `json:"id"`
+	WebhookID    string          `json:"webhookId"`
+	Event        WebhookEvent    `json:"event"`
+	Payload      json.RawMessage `json:"payload"`
+	Status       DeliveryStatus  `json:"status"`
+	Attempts     int             `json:"attempts"`
+	StatusCode   int             `json:"statusCode,omitempty"` // of the last attempt's response, 0 if there was none
+	Error        string          `json:"error,omitempty"`      // why the last attempt failed
+	CreatedOn    time.Time       `json:"createdOn"`
+	LastAttempt  time.Time       `json:"lastAttempt"`            // zero before the first attempt
+	NextAttempt  time.Time       `json:"nextAttempt"`            // zero unless pending
+	RedeliveryOf string          `json:"redeliveryOf,omitempty"` // id of the delivery this one repeats
+}
@@ -3,52 +3,41 @@ package failure
 import (
 	"encoding/json"
 	"github.com/pkg/errors"
-	"log"
+	"go_report/logging"
 	"net/http"
-	"sync"
 )
 
-// Endpoint related sync + instance
-var (
-	initOnce sync.Once
-	ep       *Endpoint
-)
-
-type Endpoint struct {
-	*log.Logger
-}
-
-func Init(logger *log.Logger) {
-	initOnce.Do(func() {
-		ep = &Endpoint{
-			Logger: logger,
-		}
-	})
-}
-
-func Fail(w http.ResponseWriter, err error) {
+// Fail sends err to the client as a json error, and logs it (with the request's logger, see logging.FromContext)
+// unless it is the client's fault
+func Fail(w http.ResponseWriter, r *http.Request, err error) {
+	logger := logging.FromContext(r.Context())
 	switch errors.Cause(err).(type) {
 	case *json.UnsupportedValueError, *json.UnsupportedTypeError, *json.SyntaxError, *json.UnmarshalTypeError:
 		SendError(w, http.StatusBadRequest, "JSON format error")
-		ep.Printf("request failed - json format error: %v", err.Error())
+		logger.Info("request failed - json format error", "err", err)
 	case *RequestFailure:
-		rf := err.(*RequestFailure)
-		if rf.Code != http.StatusBadRequest && rf.Code != http.StatusUnauthorized {
-			ep.Println("Request failed: ", errors.WithStack(err).Error())
+		rf := errors.Cause(err).(*RequestFailure)
+		if !expected(rf.Code) {
+			logger.Error("request failed", "status", rf.Code, "err", rf.cause())
 		}
 		SendError(w, rf.Code, rf.Msg)
 	case RequestFailure:
-		rf := err.(RequestFailure)
-		if rf.Code != http.StatusBadRequest && rf.Code != http.StatusUnauthorized {
-			ep.Println("Request failed: ", errors.WithStack(err).Error())
+		rf := errors.Cause(err).(RequestFailure)
+		if !expected(rf.Code) {
+			logger.Error("request failed", "status", rf.Code, "err", rf.cause())
 		}
 		SendError(w, rf.Code, rf.Msg)
 	default:
-		ep.Println("Request failed (internal server error): ", errors.WithStack(err).Error())
+		logger.Error("request failed (internal server error)", "status", http.StatusInternalServerError, "err", err)
 		SendError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
 	}
 }
 
+// expected failures are the client's, and are not logged (a misbehaving client would flood the log)
+func expected(code int) bool {
+	return code == http.StatusBadRequest || code == http.StatusUnauthorized || code == http.StatusTooManyRequests
+}
+
 //sends an http error in json format
 func SendError(w http.ResponseWriter, statusCode int, userMessage string) {
 	type ErrorMessage struct {
@@ -63,7 +52,7 @@ func SendError(w http.ResponseWriter, statusCode int, userMessage string) {
 	}
 	w.WriteHeader(statusCode)
 	if err := json.NewEncoder(w).Encode(errorMes); err != nil {
-		ep.Printf("Error response not sent: %v", err.Error())
+		logging.Default().Warn("error response not sent", "err", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -22,6 +22,14 @@ func New(err error, statusCode int, userMsg string) *RequestFailure {
 	}
 }
 
+// cause is the developer level error, for logging
+func (rf RequestFailure) cause() error {
+	if rf.err == nil {
+		return rf
+	}
+	return rf.err
+}
+
 func (rf RequestFailure) Error() string {
 	return fmt.Sprintf("%v - %v", http.StatusText(rf.Code), rf.Msg)
 }
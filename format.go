@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go_report/domain"
+	"go_report/failure"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/pkg/errors"
+)
+
+// Report listings are served as json, csv or html, by the url's extension (i.e. /report.csv, see URLFormat)
+// or else the Accept header
+
+type responseFormat string
+
+const (
+	formatJSON responseFormat = "application/json"
+	formatCSV  responseFormat = "text/csv"
+	formatHTML responseFormat = "text/html"
+)
+
+var formatExtensions = map[string]responseFormat{
+	"json": formatJSON,
+	"csv":  formatCSV,
+	"html": formatHTML,
+}
+
+// negotiateFormat picks the format of a report listing, failing with 406 if the client accepts none of them
+func negotiateFormat(w http.ResponseWriter, r *http.Request) (responseFormat, error) {
+	w.Header().Add("Vary", "Accept")
+	if ext, _ := r.Context().Value(middleware.URLFormatCtxKey).(string); ext != "" {
+		if f, ok := formatExtensions[strings.ToLower(ext)]; ok {
+			return f, nil
+		}
+		return "", failure.New(errors.Errorf("unknown extension %v", ext), http.StatusNotAcceptable, "extension must be one of .json, .csv, .html")
+	}
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return formatJSON, nil
+	}
+	// the highest q wins, then the most specific media range, then json
+	best, bestQ, bestExact := responseFormat(""), 0.0, false
+	for _, rng := range strings.Split(accept, ",") {
+		params := strings.Split(rng, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, p := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(p), "=", 2); len(kv) == 2 && kv[0] == "q" {
+				if v, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		f, exact := responseFormat(mediaType), true
+		switch mediaType {
+		case string(formatJSON), string(formatCSV), string(formatHTML):
+		case "*/*", "application/*":
+			f, exact = formatJSON, false
+		case "text/*":
+			f, exact = formatHTML, false
+		default:
+			continue
+		}
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && (exact && !bestExact || exact == bestExact && f == formatJSON)) {
+			best, bestQ, bestExact = f, q, exact
+		}
+	}
+	if best == "" {
+		return "", failure.New(errors.Errorf("unacceptable Accept %v", accept), http.StatusNotAcceptable, "Accept must allow one of application/json, text/csv, text/html")
+	}
+	return best, nil
+}
+
+// writeReports writes reports as csv or html; json listings are encoded by their handler, as their shapes differ
+func writeReports(w http.ResponseWriter, f responseFormat, title string, reports []domain.Report) error {
+	switch f {
+	case formatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", title+".csv"))
+		return writeReportsCSV(w, reports)
+	case formatHTML:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		return writeReportsHTML(w, title, reports)
+	default:
+		return errors.Errorf("cannot write reports as %v", f)
+	}
+}
+
+// reportColumns are the first columns of a csv listing, followed by one column per tag ("tag.name"), and per
+// flattened Content field ("content.a.b")
+var reportColumns = []string{"gid", "key", "severity", "release", "receivedOn"}
+
+func writeReportsCSV(w http.ResponseWriter, reports []domain.Report) error {
+	rows := make([]map[string]string, len(reports))
+	extra := map[string]bool{}
+	for i, rpt := range reports {
+		row := map[string]string{
+			"gid":        rpt.GID,
+			"key":        rpt.Key,
+			"severity":   rpt.Severity.String(),
+			"release":    rpt.Release,
+			"receivedOn": rpt.ReceivedOn.Format(time.RFC3339),
+		}
+		for t, v := range rpt.Tags {
+			row["tag."+t] = v
+			extra["tag."+t] = true
+		}
+		flattenContent("content", rpt.Content, row)
+		for col := range row {
+			if strings.HasPrefix(col, "content.") {
+				extra[col] = true
+			}
+		}
+		rows[i] = row
+	}
+	cols := make([]string, 0, len(extra))
+	for col := range extra {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	cols = append(reportColumns[:len(reportColumns):len(reportColumns)], cols...)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(cols); err != nil {
+		return err
+	}
+	record := make([]string, len(cols))
+	for _, row := range rows {
+		for i, col := range cols {
+			record[i] = csvCell(row[col])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// flattenContent adds each leaf of content to row, keyed by its dotted path. Arrays are kept as json.
+func flattenContent(prefix string, content map[string]interface{}, row map[string]string) {
+	for k, v := range content {
+		col := prefix + "." + k
+		switch val := v.(type) {
+		case map[string]interface{}:
+			flattenContent(col, val, row)
+		case string:
+			row[col] = val
+		case nil:
+			row[col] = ""
+		default:
+			b, _ := json.Marshal(val)
+			row[col] = string(b)
+		}
+	}
+}
+
+// csvCell keeps a spreadsheet from evaluating a cell as a formula
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+var reportsTemplate = template.Must(template.New("reports").Funcs(template.FuncMap{"prettyJSON": prettyJSON}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { text-align: left; vertical-align: top; padding: .3em .6em; border-bottom: 1px solid #ddd; }
+pre { margin: 0; font-size: .9em; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{len .Reports}} reports</p>
+<table>
+<tr><th>gid</th><th>key</th><th>severity</th><th>release</th><th>received</th><th>tags</th><th>content</th></tr>
+{{range .Reports}}<tr>
+<td>{{.GID}}</td><td>{{.Key}}</td><td>{{.Severity}}</td><td>{{.Release}}</td><td>{{.ReceivedOn.Format "2006-01-02 15:04:05 MST"}}</td>
+<td>{{range $t, $v := .Tags}}{{$t}}={{$v}}<br>{{end}}</td>
+<td><pre>{{prettyJSON .Content}}</pre></td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// prettyJSON indents v, leaving html escaping to the template
+func prettyJSON(v interface{}) string {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func writeReportsHTML(w http.ResponseWriter, title string, reports []domain.Report) error {
+	return reportsTemplate.Execute(w, struct {
+		Title   string
+		Reports []domain.Report
+	}{title, reports})
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/bradleyfalzon/ghinstallation"
 	"github.com/google/go-github/github"
 	"github.com/pkg/errors"
+	"go_report/logging"
+	"go_report/tracing"
 	"golang.org/x/oauth2"
 	"net/http"
 	"os"
@@ -30,6 +32,11 @@ type Repo struct {
 type Service struct {
 	Secrets
 	Repo
+	// Transport makes the api calls of a client: "app" (the installation) or "user" (a developer's token).
+	// Defaults to http.DefaultTransport.
+	Transport func(client string) http.RoundTripper
+	Log       *logging.Logger
+	ctx       context.Context // of the api calls, see WithContext
 }
 
 //ReadConfig reads a _secrets.json file into a Config struct
@@ -48,38 +55,114 @@ func NewFromFile(fp string) (s *Service, err error) {
 	return shh, fd.Close()
 }
 
-func New(repo Repo, shh Secrets) *Service {
+func New(repo Repo, shh Secrets, logger *logging.Logger) *Service {
 	return &Service{
 		Secrets: shh,
 		Repo:    repo,
+		Log:     logger,
 	}
 }
 
+// WithContext returns a copy of the service whose api calls are made with ctx, i.e. to be spans of a request's trace
+func (s *Service) WithContext(ctx context.Context) *Service {
+	c := *s
+	c.ctx = ctx
+	return &c
+}
+
+func (s *Service) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// span starts the span of an api method, returning the context its calls are made with
+func (s *Service) span(method string, kv ...interface{}) (context.Context, *tracing.Span) {
+	return tracing.Start(s.context(), "github."+method, kv...)
+}
+
+func (s *Service) transport(client string) http.RoundTripper {
+	if s.Transport == nil {
+		return http.DefaultTransport
+	}
+	return s.Transport(client)
+}
+
 func (s *Service) newTokenClient(tkn string) *github.Client {
 	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: tkn})
-	tc := oauth2.NewClient(context.Background(), ts)
+	ctx := context.WithValue(s.context(), oauth2.HTTPClient, &http.Client{Transport: s.transport("user")})
+	tc := oauth2.NewClient(ctx, ts)
 	gh := github.NewClient(tc)
 	return gh
 }
 
 func (s *Service) newInstallationClient() (*github.Client, error) {
 
-	tr, err := ghinstallation.New(http.DefaultTransport, s.AppID, s.InstallID, []byte(s.PrivateKey))
+	tr, err := ghinstallation.New(s.transport("app"), int64(s.AppID), int64(s.InstallID), []byte(s.PrivateKey))
 	if err != nil {
 		return nil, errors.Wrapf(err, "error creating app transport: %v", err.Error())
 	}
 	return github.NewClient(&http.Client{Transport: tr}), nil
 }
 
-func (s *Service) CreateGitHubIssue(issReq github.IssueRequest) error {
+// CreateGitHubIssue opens an issue on the target repo, returning the new issue's number
+func (s *Service) CreateGitHubIssue(issReq github.IssueRequest) (n int, err error) {
+	ctx, span := s.span("CreateGitHubIssue")
+	defer func() { span.EndErr(err) }()
+	gh, err := s.newInstallationClient()
+	if err != nil {
+		return 0, err
+	}
+	iss, _, err := gh.Issues.Create(ctx, s.Repo.Owner, s.Repo.Name, &issReq)
+	if err != nil {
+		return 0, err
+	}
+	s.Log.Info("created github issue", "number", iss.GetNumber(), "title", issReq.GetTitle())
+	return iss.GetNumber(), nil
+}
+
+// CreateIssueComment comments on the target repo's issue, returning the new comment's id
+func (s *Service) CreateIssueComment(number int, body string) (id int64, err error) {
+	ctx, span := s.span("CreateIssueComment", "github.issue", number)
+	defer func() { span.EndErr(err) }()
+	gh, err := s.newInstallationClient()
+	if err != nil {
+		return 0, err
+	}
+	c, _, err := gh.Issues.CreateComment(ctx, s.Repo.Owner, s.Repo.Name, number, &github.IssueComment{Body: github.String(body)})
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to comment on issue #%v", number)
+	}
+	s.Log.Debug("commented on github issue", "number", number, "comment", c.GetID())
+	return c.GetID(), nil
+}
+
+// EditIssueComment replaces the body of a comment made by CreateIssueComment
+func (s *Service) EditIssueComment(id int64, body string) (err error) {
+	ctx, span := s.span("EditIssueComment", "github.comment", id)
+	defer func() { span.EndErr(err) }()
 	gh, err := s.newInstallationClient()
 	if err != nil {
 		return err
 	}
-	_, _, err = gh.Issues.Create(context.Background(), s.Repo.Owner, s.Repo.Name, &issReq)
+	_, _, err = gh.Issues.EditComment(ctx, s.Repo.Owner, s.Repo.Name, id, &github.IssueComment{Body: github.String(body)})
+	if err != nil {
+		return errors.Wrapf(err, "failed to edit issue comment %v", id)
+	}
+	return nil
+}
+
+// CheckInstallation confirms the app's key & installation are valid, by fetching an installation token and reading
+// the target repo with it
+func (s *Service) CheckInstallation(ctx context.Context) error {
+	gh, err := s.newInstallationClient()
 	if err != nil {
 		return err
 	}
+	if _, _, err = gh.Repositories.Get(ctx, s.Repo.Owner, s.Repo.Name); err != nil {
+		return errors.Wrap(err, "failed to read the repository as the app installation")
+	}
 	return nil
 }
 
@@ -87,18 +170,20 @@ func (s *Service) CreateGitHubIssue(issReq github.IssueRequest) error {
 // token, and that the token belongs to a contributor/collaborator of the target repo.
 // if the token request user is not the same as the github token username, false + error is returned.
 // For all cases other than a successful verification, false + error is returned.
-func (s *Service) VerifyDeveloperToken(user, ghTkn string) (bool, error) {
+func (s *Service) VerifyDeveloperToken(user, ghTkn string) (ok bool, err error) {
+	ctx, span := s.span("VerifyDeveloperToken", "github.user", user)
+	defer func() { span.EndErr(err) }()
 	gh := s.newTokenClient(ghTkn)
 	req, err := gh.NewRequest(http.MethodGet, "https://api.github.com/user", nil)
 	if err != nil {
 		return false, errors.Wrap(err, "failed to create /user request")
 	}
 	usr := new(github.User)
-	_, err = gh.Do(context.Background(), req, usr)
+	_, err = gh.Do(ctx, req, usr)
 	if err != nil {
 		return false, errors.Wrap(err, "gh.Do failed")
 	}
-	if ok, err := s.IsContributorOrCollaborator(*usr.Login); err != nil {
+	if ok, err := s.WithContext(ctx).IsContributorOrCollaborator(*usr.Login); err != nil {
 		return false, errors.Wrap(err, "")
 	} else if !ok {
 		return false, errors.New(fmt.Sprintf("user %v is not a contributor/collaborator", *usr.Login))
@@ -110,37 +195,39 @@ func (s *Service) VerifyDeveloperToken(user, ghTkn string) (bool, error) {
 
 // IsContributor returns a boolean status for whether the given username is a repository contributor
 func (s *Service) IsContributorOrCollaborator(name string) (authorized bool, err error) {
+	ctx, span := s.span("IsContributorOrCollaborator", "github.user", name)
+	defer func() { span.EndErr(err) }()
 	var insClient *github.Client
 	insClient, err = s.newInstallationClient()
 	if err != nil {
 		return false, errors.Wrap(err, "Failed to get app installation client")
 	}
-	repo, _, err := insClient.Repositories.Get(context.Background(), s.Repo.Owner, s.Repo.Name)
+	repo, _, err := insClient.Repositories.Get(ctx, s.Repo.Owner, s.Repo.Name)
 	if err != nil {
 		return false, errors.Errorf("Failed to confirm user is contributor because of error getting repository: %v", err.Error())
 	} else if repo == nil {
 		return false, errors.New("Failed to confirm user is contributor because repository was nil")
 	}
 	url := fmt.Sprintf("https://api.github.com/repos/%v/%v/collaborators", s.Owner, s.Name)
-	ok, err := IsInCCList(insClient, url, name)
+	ok, err := IsInCCList(ctx, insClient, url, name)
 	if err != nil {
 		return false, err
 	}
 	if !ok { // not a collaborator...
 		url := fmt.Sprintf("https://api.github.com/repos/%v/%v/contributors", s.Owner, s.Name)
-		return IsInCCList(insClient, url, name) // is contributor?
+		return IsInCCList(ctx, insClient, url, name) // is contributor?
 	}
 	return true, nil // is collaborator.
 }
 
 // IsInCCList checks whether a given username is a contributor or collaborator to the target repository
 // where ins is the repository installation client (see NewGitHubInstallationClient)
-func IsInCCList(ins *github.Client, url string, uname string) (isPresent bool, err error) {
+func IsInCCList(ctx context.Context, ins *github.Client, url string, uname string) (isPresent bool, err error) {
 	r, err := ins.NewRequest(http.MethodGet, url+"/"+uname, nil)
 	if err != nil {
 		return false, errors.Errorf("Failed to create GET %v request: %v", url, err.Error())
 	}
-	rsp, err := ins.Do(context.Background(), r, nil)
+	rsp, err := ins.Do(ctx, r, nil)
 	if err != nil {
 		switch t := err.(type) {
 		case *github.RateLimitError:
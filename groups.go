@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"go_report/auth"
+	"go_report/domain"
+	"go_report/failure"
+	"go_report/logging"
+	"go_report/webhook"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// GetGroupsHandler lists the summary of every group, ordered by ?sort=lastSeen (default) or ?sort=count,
+// optionally only those with the ?status=... given
+func GetGroupsHandler(s domain.GroupStorer) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		groups, err := s.SelectAllGroupInfo(r.Context())
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		if st := r.URL.Query().Get("status"); st != "" {
+			status, ok := domain.ConvertGroupStatusString(st)
+			if !ok {
+				failure.Fail(w, r, failure.New(errors.Errorf("unknown group status %v", st), http.StatusBadRequest, "status must be one of open, resolved, ignored, regressed"))
+				return
+			}
+			filtered := make([]domain.Group, 0, len(groups))
+			for _, g := range groups {
+				if g.Status == status {
+					filtered = append(filtered, g)
+				}
+			}
+			groups = filtered
+		}
+		by := r.URL.Query().Get("sort")
+		if by == "" {
+			by = domain.SortByLastSeen
+		}
+		if ok := domain.SortGroups(groups, by); !ok {
+			failure.Fail(w, r, failure.New(errors.Errorf("unknown group sort %v", by), http.StatusBadRequest, "sort must be one of lastSeen, count"))
+			return
+		}
+		if err := json.NewEncoder(w).Encode(groups); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode groups json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// GetGroupStatusHandler returns the status and summary of the group in context
+func GetGroupStatusHandler(s domain.GroupStorer) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gid := r.Context().Value(string(ReportGIDVar)).(string)
+		grp, err := s.SelectGroupInfo(r.Context(), gid)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(grp); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode group json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// SetGroupStatusHandler moves the group in context to status, recording the developer who made the change.
+// Resolving a group sends group.resolved to its webhooks.
+func SetGroupStatusHandler(s domain.GroupStorer, hooks *webhook.Dispatcher, status domain.GroupStatus) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gid := r.Context().Value(string(ReportGIDVar)).(string)
+		grp, err := s.SetGroupStatus(r.Context(), gid, status, auth.GHUserFromContext(r.Context()))
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		if status == domain.StatusResolved {
+			if err := hooks.GroupResolved(r.Context(), *grp); err != nil {
+				logging.FromContext(r.Context()).Warn("failed to send group.resolved to webhooks", "gid", gid, "err", err)
+			}
+		}
+		if err := json.NewEncoder(w).Encode(grp); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode group json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// filterByGroupStatus keeps the reports belonging to groups with the given status (groups never changed are open)
+func filterByGroupStatus(ctx context.Context, s domain.GroupStorer, reports []domain.Report, status domain.GroupStatus) ([]domain.Report, error) {
+	groups, err := s.SelectAllGroupInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]domain.GroupStatus, len(groups))
+	for _, g := range groups {
+		statuses[g.GID] = g.Status
+	}
+	filtered := make([]domain.Report, 0, len(reports))
+	for _, rpt := range reports {
+		st, ok := statuses[rpt.GID]
+		if !ok {
+			st = domain.StatusOpen
+		}
+		if st == status {
+			filtered = append(filtered, rpt)
+		}
+	}
+	return filtered, nil
+}
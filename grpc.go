@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"go_report/auth"
+	"go_report/domain"
+	"go_report/failure"
+	"go_report/ingest"
+	"go_report/logging"
+	"go_report/reportpb"
+	"go_report/tracing"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // clients may compress their messages
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// devMethods may only be called with a developer jwt; Submit & SubmitStream take application jwts too
+var devMethods = map[string]bool{
+	"/goreport.v1.Reports/GetReport":        true,
+	"/goreport.v1.Reports/ListGroupReports": true,
+	"/goreport.v1.Reports/GetGroup":         true,
+	"/goreport.v1.Reports/ListGroups":       true,
+}
+
+// reportLimitedMethods are rate limited per report they receive (see SubmitStream), rather than per call
+var reportLimitedMethods = map[string]bool{
+	"/goreport.v1.Reports/SubmitStream": true,
+}
+
+// NewGRPCServer serves the Reports service of reportpb/report.proto over the same store, queue & limits as the http
+// api, with its authentication & rate limits (see callInterceptors). Calls are made over tls if the api has it.
+// Messages are limited to MaxReport, which is itself capped at domain.MaxStoredReport.
+func NewGRPCServer(svc Services, tlsCfg *tls.Config) *grpc.Server {
+	ci := callInterceptors{auth: svc.Auth, limits: svc.RateLimits, logger: svc.Logger, tracer: svc.Tracer, metrics: svc.Metrics}
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(int(svc.Limits.MaxReport)),
+		grpc.UnaryInterceptor(ci.unary),
+		grpc.StreamInterceptor(ci.stream),
+	}
+	if tlsCfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	srv := grpc.NewServer(opts...)
+	reportpb.RegisterReportsServer(srv, &reportsServer{limits: svc.Limits, rateLimits: svc.RateLimits, queue: svc.Queue, store: svc.Store})
+	return srv
+}
+
+// ServeGRPC serves the gRPC api on its own port, apart from the http api, until stop. stop waits for calls in flight
+// until ctx is done, then cancels them. A port of none serves no gRPC api.
+func ServeGRPC(port string, srv *grpc.Server, logger *logging.Logger) (stop func(ctx context.Context) error, err error) {
+	if port == "none" {
+		return func(context.Context) error { return nil }, nil
+	}
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not listen for gRPC calls")
+	}
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			logger.Error("gRPC server failed", "port", port, "err", err)
+		}
+	}()
+	return func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			srv.Stop()
+			return errors.Wrap(ctx.Err(), "gRPC calls did not finish")
+		}
+	}, nil
+}
+
+// callInterceptors are the gRPC api's middlewares, as NewRouter's are the http api's: each call gets a request id, a
+// logger (logged once the call is done) & a span, recovers from panics, must carry a jwt as "authorization: Bearer
+// <jwt>" metadata (Unauthenticated otherwise), and is rate limited by its client (ResourceExhausted). Errors are sent
+// as statuses, see rpcError.
+type callInterceptors struct {
+	auth    *auth.Service
+	limits  RateLimiters
+	logger  *logging.Logger
+	tracer  trace.Tracer
+	metrics *Metrics
+}
+
+func (ci callInterceptors) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	ctx, span := ci.start(ctx, info.FullMethod)
+	defer ci.finish(ctx, span, info.FullMethod, time.Now(), &err)
+	if ctx, err = ci.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (ci callInterceptors) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	ctx, span := ci.start(ss.Context(), info.FullMethod)
+	defer ci.finish(ctx, span, info.FullMethod, time.Now(), &err)
+	if ctx, err = ci.authorize(ctx, info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, contextStream{ss, ctx})
+}
+
+// contextStream is a server stream whose handler sees the interceptor's context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// start names the call's request id & logger, and starts its span as a child of the client's traceparent metadata
+func (ci callInterceptors) start(ctx context.Context, method string) (context.Context, *tracing.Span) {
+	id := fmt.Sprintf("grpc-%06d", middleware.NextRequestID())
+	ctx = context.WithValue(ctx, middleware.RequestIDKey, id)
+	ctx = logging.NewContext(ctx, ci.logger.With("request_id", id))
+	if ci.tracer == nil {
+		return ctx, nil
+	}
+	attrs := []interface{}{"rpc.system", "grpc", "rpc.method", method, "rpc.request_id", id}
+	ctx = tracing.ContextWithTraceParent(ctx, firstMetadata(ctx, "traceparent"))
+	ctx, span := tracing.StartWith(ctx, ci.tracer, method, tracing.Server, attrs...)
+	logging.AddFields(ctx, "trace_id", span.TraceID())
+	return ctx, span
+}
+
+// authorize authenticates the call's jwt, then counts the call against its client's rate limit (unless its reports
+// are counted, see reportLimitedMethods)
+func (ci callInterceptors) authorize(ctx context.Context, method string) (context.Context, error) {
+	ctx, err := ci.auth.AuthenticateBearer(ctx, firstMetadata(ctx, "authorization"), devMethods[method])
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, "authorization metadata must be a valid jwt (Bearer <jwt>)")
+	}
+	if reportLimitedMethods[method] {
+		return ctx, nil
+	}
+	if ok, retryAfter := ci.limits.allow(ctx); !ok {
+		_ = grpc.SetHeader(ctx, retryAfterMD(retryAfter))
+		return ctx, status.Error(codes.ResourceExhausted, "Too many requests, retry later")
+	}
+	return ctx, nil
+}
+
+func retryAfterMD(d time.Duration) metadata.MD {
+	return metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
+
+// finish sends the call's error as a status, recovering from a panic of its handler, then ends its span, and logs &
+// counts it
+func (ci callInterceptors) finish(ctx context.Context, span *tracing.Span, method string, start time.Time, errp *error) {
+	if p := recover(); p != nil {
+		logging.FromContext(ctx).Error("handler panicked", "panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+		*errp = status.Error(codes.Internal, "internal error")
+	}
+	st := rpcError(ctx, *errp)
+	if *errp != nil {
+		*errp = st.Err()
+	}
+	span.SetAttributes("rpc.grpc.status_code", int(st.Code()))
+	if serverFault(st.Code()) {
+		span.SetError(*errp)
+	}
+	span.End()
+	took := time.Since(start)
+	ci.metrics.ObserveCall(method, st.Code(), took)
+	kv := []interface{}{"method", method, "grpc_status", st.Code().String(), "durationMs", float64(took/time.Microsecond) / 1000}
+	if p, ok := peer.FromContext(ctx); ok {
+		kv = append(kv, "remote", p.Addr.String())
+	}
+	logging.FromContext(ctx).Info("call", kv...)
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if vs := md.Get(key); len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+// reportsServer answers the methods of the Reports service, as the http api's handlers do
+type reportsServer struct {
+	reportpb.UnimplementedReportsServer
+	limits     BodyLimits
+	rateLimits RateLimiters // of the reports of SubmitStream, whose calls are not limited
+	queue      *ingest.Queue
+	store      reportsQuerier
+}
+
+// reportsQuerier reads reports & groups for developers' queries
+type reportsQuerier interface {
+	domain.ReportStorer
+	domain.GroupStorer
+}
+
+// Submit queues a report, as PostHandler (without idempotency keys)
+func (rs *reportsServer) Submit(ctx context.Context, req *reportpb.Report) (*reportpb.Receipt, error) {
+	rr, err := enqueueRPCReport(ctx, rs.limits, rs.queue, req)
+	if err != nil {
+		return nil, err
+	}
+	return &reportpb.Receipt{Gid: rr.GID, Key: rr.Key}, nil
+}
+
+// SubmitStream queues each report of the stream, rejecting invalid ones. Each report counts against the client's rate
+// limit, as a Submit call would. Being rate limited or a full queue ends the call, naming the first report which was
+// not queued.
+func (rs *reportsServer) SubmitStream(stream reportpb.Reports_SubmitStreamServer) error {
+	ctx := stream.Context()
+	summary := &reportpb.SubmitSummary{}
+	for i := int32(0); ; i++ {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if ok, retryAfter := rs.rateLimits.allow(ctx); !ok {
+			_ = stream.SetHeader(retryAfterMD(retryAfter))
+			return failure.New(errors.New("report stream rate limited"), http.StatusTooManyRequests, fmt.Sprintf("Report %d and later were not queued: too many requests, retry later", i))
+		}
+		rr, err := enqueueRPCReport(ctx, rs.limits, rs.queue, req)
+		if rf, ok := errors.Cause(err).(*failure.RequestFailure); ok && (rf.Code == http.StatusBadRequest || rf.Code == http.StatusRequestEntityTooLarge) {
+			summary.Rejected = append(summary.Rejected, &reportpb.Rejection{Index: i, Message: rf.Msg})
+			continue
+		} else if ok {
+			return failure.New(err, rf.Code, fmt.Sprintf("Report %d and later were not queued: %v", i, rf.Msg))
+		} else if err != nil {
+			return err
+		}
+		summary.Receipts = append(summary.Receipts, &reportpb.Receipt{Gid: rr.GID, Key: rr.Key})
+	}
+	logging.FromContext(ctx).Debug("queued report stream", "queued", len(summary.Receipts), "rejected", len(summary.Rejected))
+	return stream.SendAndClose(summary)
+}
+
+// enqueueRPCReport checks & queues the report, failing with RequestFailures as the http api does
+func enqueueRPCReport(ctx context.Context, limits BodyLimits, q *ingest.Queue, req *reportpb.Report) (domain.Receipt, error) {
+	rpt := domain.Report{GID: req.Gid, Severity: domain.ReportType(req.Severity), Release: req.Release, Tags: req.Tags}
+	if rpt.GID == "" {
+		return domain.Receipt{}, failure.New(errors.New("report has no gid"), http.StatusBadRequest, "report must have a gid")
+	}
+	if err := rpt.ValidateGID(); err != nil {
+		return domain.Receipt{}, failure.New(err, http.StatusBadRequest, err.Error())
+	}
+	if len(bytes.TrimSpace(req.ContentJson)) > 0 {
+		if err := limits.CheckJSON(req.ContentJson); err != nil {
+			return domain.Receipt{}, err
+		}
+		if err := json.Unmarshal(req.ContentJson, &rpt.Content); err != nil {
+			return domain.Receipt{}, failure.New(err, http.StatusBadRequest, "content_json must be a json object")
+		}
+	}
+	if err := rpt.ValidateTags(); err != nil {
+		return domain.Receipt{}, failure.New(err, http.StatusBadRequest, err.Error())
+	}
+	rpt.ReceivedOn = time.Now()
+	if rel := auth.ReleaseFromContext(ctx); rel != "" {
+		rpt.Release = rel // the certificate's release is trusted over the payload's
+	}
+	if err := rpt.ValidateSize(); err != nil {
+		return domain.Receipt{}, failure.New(err, http.StatusRequestEntityTooLarge, err.Error())
+	}
+	key, err := rpt.ContentKey()
+	if err != nil {
+		return domain.Receipt{}, failure.New(errors.Wrap(err, "failed to key report"), http.StatusInternalServerError, "")
+	}
+	rpt.Key = key
+	if err := q.Enqueue(ingest.Job{Report: rpt, RequestID: middleware.GetReqID(ctx), TraceParent: tracing.TraceParentFromContext(ctx)}); err != nil {
+		return domain.Receipt{}, failure.New(errors.Wrap(err, "failed to queue report"), http.StatusServiceUnavailable, "Too many reports are waiting to be stored, retry later")
+	}
+	return domain.Receipt{GID: rpt.GID, Key: rpt.Key}, nil
+}
+
+// GetReport answers one report, as GetReportHandler
+func (rs *reportsServer) GetReport(ctx context.Context, req *reportpb.ReportRef) (*reportpb.Report, error) {
+	rpt, err := rs.store.Select(ctx, domain.Receipt{GID: req.Gid, Key: req.Key})
+	if err != nil {
+		return nil, err
+	}
+	if rpt == nil || rpt.Key == "" {
+		return nil, status.Errorf(codes.NotFound, "no report %v in group %v", req.Key, req.Gid)
+	}
+	return reportMessage(*rpt)
+}
+
+// ListGroupReports answers a group's reports
+func (rs *reportsServer) ListGroupReports(ctx context.Context, req *reportpb.GroupRef) (*reportpb.ReportList, error) {
+	rpts, err := rs.store.SelectGroup(ctx, req.Gid)
+	if err != nil {
+		return nil, err
+	}
+	list := &reportpb.ReportList{Reports: make([]*reportpb.Report, 0, len(rpts))}
+	for _, rpt := range rpts {
+		m, err := reportMessage(rpt)
+		if err != nil {
+			return nil, err
+		}
+		list.Reports = append(list.Reports, m)
+	}
+	return list, nil
+}
+
+// GetGroup answers a group's status & summary, as GetGroupStatusHandler
+func (rs *reportsServer) GetGroup(ctx context.Context, req *reportpb.GroupRef) (*reportpb.Group, error) {
+	g, err := rs.store.SelectGroupInfo(ctx, req.Gid)
+	if err != nil {
+		return nil, err
+	}
+	return groupMessage(*g), nil
+}
+
+// ListGroups answers every group, as GetGroupsHandler
+func (rs *reportsServer) ListGroups(ctx context.Context, req *reportpb.ListGroupsRequest) (*reportpb.GroupList, error) {
+	groups, err := rs.store.SelectAllGroupInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != "" {
+		st, ok := domain.ConvertGroupStatusString(req.Status)
+		if !ok {
+			return nil, failure.New(errors.Errorf("unknown group status %v", req.Status), http.StatusBadRequest, "status must be one of open, resolved, ignored, regressed")
+		}
+		filtered := make([]domain.Group, 0, len(groups))
+		for _, g := range groups {
+			if g.Status == st {
+				filtered = append(filtered, g)
+			}
+		}
+		groups = filtered
+	}
+	by := req.Sort
+	if by == "" {
+		by = domain.SortByLastSeen
+	}
+	if ok := domain.SortGroups(groups, by); !ok {
+		return nil, failure.New(errors.Errorf("unknown group sort %v", by), http.StatusBadRequest, "sort must be one of lastSeen, count")
+	}
+	list := &reportpb.GroupList{Groups: make([]*reportpb.Group, 0, len(groups))}
+	for _, g := range groups {
+		list.Groups = append(list.Groups, groupMessage(g))
+	}
+	return list, nil
+}
+
+func reportMessage(rpt domain.Report) (*reportpb.Report, error) {
+	m := &reportpb.Report{
+		Gid:              rpt.GID,
+		Severity:         reportpb.Severity(rpt.Severity),
+		Key:              rpt.Key,
+		ReceivedOnUnixMs: unixMs(rpt.ReceivedOn),
+		Release:          rpt.Release,
+		Tags:             rpt.Tags,
+	}
+	if rpt.Content != nil {
+		b, err := json.Marshal(rpt.Content)
+		if err != nil {
+			return nil, failure.New(errors.Wrap(err, "could not encode report content"), http.StatusInternalServerError, "")
+		}
+		m.ContentJson = b
+	}
+	return m, nil
+}
+
+func groupMessage(g domain.Group) *reportpb.Group {
+	m := &reportpb.Group{
+		Gid:             g.GID,
+		Status:          string(g.Status),
+		StatusBy:        g.StatusBy,
+		StatusOnUnixMs:  unixMs(g.StatusOn),
+		FirstSeenUnixMs: unixMs(g.FirstSeen),
+		LastSeenUnixMs:  unixMs(g.LastSeen),
+		Count:           int64(g.Count),
+		LatestKey:       g.LatestKey,
+		FirstRelease:    g.FirstRelease,
+		LastRelease:     g.LastRelease,
+		ResolvedIn:      g.ResolvedIn,
+		RegressedIn:     g.RegressedIn,
+		IssueNumber:     int64(g.IssueNumber),
+	}
+	if len(g.SeverityCounts) > 0 {
+		m.SeverityCounts = make(map[string]int64, len(g.SeverityCounts))
+		for sev, n := range g.SeverityCounts {
+			m.SeverityCounts[sev] = int64(n)
+		}
+	}
+	return m
+}
+
+// unixMs is 0 for the zero time
+func unixMs(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// rpcError is the status of a call's error: the RequestFailures of the store & the http api are translated (see
+// rpcCode), and unexpected errors are logged as failure.Fail does, and sent as Unknown without their detail
+func rpcError(ctx context.Context, err error) *status.Status {
+	if err == nil {
+		return status.New(codes.OK, "")
+	}
+	var st *status.Status
+	cause := errors.Cause(err)
+	switch c := cause.(type) {
+	case *failure.RequestFailure:
+		st = status.New(rpcCode(c.Code), c.Msg)
+	case failure.RequestFailure:
+		st = status.New(rpcCode(c.Code), c.Msg)
+	default:
+		if s, ok := status.FromError(cause); ok {
+			st = s
+		} else if cause == context.Canceled {
+			st = status.New(codes.Canceled, "call canceled")
+		} else if cause == context.DeadlineExceeded {
+			st = status.New(codes.DeadlineExceeded, "call deadline exceeded")
+		} else {
+			st = status.New(codes.Unknown, "internal error")
+		}
+	}
+	if serverFault(st.Code()) {
+		logging.FromContext(ctx).Error("call failed", "code", st.Code().String(), "err", err)
+	}
+	return st
+}
+
+func serverFault(c codes.Code) bool {
+	return c == codes.Internal || c == codes.Unknown || c == codes.Unavailable
+}
+
+// rpcCode is the gRPC status of an http status, as in gRPC's http mapping
+func rpcCode(status int) codes.Code {
+	switch status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
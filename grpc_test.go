@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go_report/auth"
+	"go_report/domain"
+	"go_report/ingest"
+	"go_report/ratelimit"
+	"go_report/reportpb"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/go-chi/jwtauth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const testJWTKey = "grpc-test-key"
+
+// groupStore answers one group, and no reports; the rest of the store panics if called
+type groupStore struct {
+	domain.Storer
+}
+
+func (groupStore) Select(ctx context.Context, lookup domain.Receipt) (*domain.Report, error) {
+	return nil, nil
+}
+
+func (groupStore) SelectGroupInfo(ctx context.Context, gid string) (*domain.Group, error) {
+	return &domain.Group{GID: gid, Status: domain.StatusOpen}, nil
+}
+
+func (groupStore) SelectAllGroupInfo(ctx context.Context) ([]domain.Group, error) {
+	return []domain.Group{{GID: "g", Status: domain.StatusOpen}}, nil
+}
+
+func testToken(t *testing.T, claims jwt.MapClaims) string {
+	_, tkn, err := jwtauth.New(jwt.SigningMethodHS512.Name, []byte(testJWTKey), nil).Encode(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tkn
+}
+
+// startGRPC serves the Reports service on a local port, returning a client of it
+func startGRPC(t *testing.T, limits RateLimiters) (reportpb.ReportsClient, func()) {
+	dir, err := ioutil.TempDir("", "grpc-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := ingest.New(ingest.Config{Size: 10, Workers: 1, SpillDir: dir, MaxSpill: 10}, func(ingest.Job) error { return nil }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewGRPCServer(Services{
+		Store:      groupStore{},
+		Auth:       auth.New(nil, auth.Secrets{JWTKey: testJWTKey}, nil, nil),
+		Queue:      q,
+		Limits:     DefaultBodyLimits,
+		RateLimits: limits,
+		Metrics:    NewMetrics(),
+	}, nil)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(lis)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reportpb.NewReportsClient(conn), func() {
+		conn.Close()
+		srv.Stop()
+		q.Shutdown(context.Background())
+		os.RemoveAll(dir)
+	}
+}
+
+func withToken(tkn string) context.Context {
+	if tkn == "" {
+		return context.Background()
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+tkn)
+}
+
+func TestGRPCCalls(t *testing.T) {
+	client, stop := startGRPC(t, RateLimiters{})
+	defer stop()
+	dev := testToken(t, jwt.MapClaims{"aud": string(auth.GHAudience), string(auth.GHUser): "octocat"})
+	app := testToken(t, jwt.MapClaims{"aud": string(auth.MSSAudience), string(auth.MSSCertificate): "cert"})
+	forged := testToken(t, jwt.MapClaims{"aud": string(auth.GHAudience), string(auth.GHUser): "octocat"}) + "x"
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+		tkn  string
+		want codes.Code
+	}{
+		{"query without jwt", getGroup(client), "", codes.Unauthenticated},
+		{"query with forged jwt", getGroup(client), forged, codes.Unauthenticated},
+		{"query with app jwt", getGroup(client), app, codes.Unauthenticated},
+		{"query with dev jwt", getGroup(client), dev, codes.OK},
+		{"missing report", func(ctx context.Context) error {
+			_, err := client.GetReport(ctx, &reportpb.ReportRef{Gid: "g", Key: "k"})
+			return err
+		}, dev, codes.NotFound},
+		{"unknown group status", func(ctx context.Context) error {
+			_, err := client.ListGroups(ctx, &reportpb.ListGroupsRequest{Status: "lost"})
+			return err
+		}, dev, codes.InvalidArgument},
+		{"store panics", func(ctx context.Context) error {
+			_, err := client.ListGroupReports(ctx, &reportpb.GroupRef{Gid: "g"})
+			return err
+		}, dev, codes.Internal},
+		{"submit without jwt", submit(client, "g"), "", codes.Unauthenticated},
+		{"submit with app jwt", submit(client, "g"), app, codes.OK},
+		{"submit without gid", submit(client, ""), app, codes.InvalidArgument},
+		{"submit to the certificates' gid", submit(client, domain.CertificateGID), app, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call(withToken(tt.tkn))); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("1/1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, stop := startGRPC(t, RateLimiters{Devs: ratelimit.New("developers", limit, nil)})
+	defer stop()
+	ctx := withToken(testToken(t, jwt.MapClaims{"aud": string(auth.GHAudience), string(auth.GHUser): "octocat"}))
+	for i, want := range []codes.Code{codes.OK, codes.ResourceExhausted} {
+		var md metadata.MD
+		_, err := client.GetGroup(ctx, &reportpb.GroupRef{Gid: "g"}, grpc.Header(&md))
+		if got := status.Code(err); got != want {
+			t.Fatalf("call %d: got %v, want %v", i, got, want)
+		}
+		if want == codes.ResourceExhausted && len(md.Get("retry-after")) == 0 {
+			t.Errorf("call %d: no retry-after metadata", i)
+		}
+	}
+}
+
+func TestGRPCStreamRateLimit(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("2/1h,2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, stop := startGRPC(t, RateLimiters{Apps: ratelimit.New("apps", limit, nil)})
+	defer stop()
+	var md metadata.MD
+	stream, err := client.SubmitStream(withToken(testToken(t, jwt.MapClaims{"aud": string(auth.MSSAudience), string(auth.MSSCertificate): "cert"})), grpc.Header(&md))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := stream.Send(&reportpb.Report{Gid: "g", Severity: reportpb.Severity_BUG, ContentJson: []byte(fmt.Sprintf(`{"n":%d}`, i))}); err != nil {
+			break // the server ended the call
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	if st := status.Convert(err); st.Code() != codes.ResourceExhausted || !strings.Contains(st.Message(), "Report 2 and later") {
+		t.Errorf("got %v, want the third report to be rate limited", err)
+	}
+	if len(md.Get("retry-after")) == 0 {
+		t.Error("no retry-after metadata")
+	}
+}
+
+func getGroup(client reportpb.ReportsClient) func(context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := client.GetGroup(ctx, &reportpb.GroupRef{Gid: "g"})
+		return err
+	}
+}
+
+func submit(client reportpb.ReportsClient, gid string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := client.Submit(ctx, &reportpb.Report{Gid: gid, Severity: reportpb.Severity_BUG, ContentJson: []byte(`{"a":1}`)})
+		return err
+	}
+}
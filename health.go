@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Check is a dependency the server needs to serve requests (i.e. the store)
+type Check struct {
+	Name    string
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of a check's latest run
+type CheckResult struct {
+	OK        bool      `json:"ok"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMS int64     `json:"latencyMs"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Readiness is the combined result of every check
+type Readiness struct {
+	Ready  bool                   `json:"ready"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+type cachedCheck struct {
+	Check
+	lock sync.Mutex // held while running, so concurrent probes wait for one run
+	last CheckResult
+}
+
+// Health runs the checks for /readyz, caching each result for cacheFor, so frequent probes do not load the
+// dependencies (or GitHub's rate limit)
+type Health struct {
+	checks   []*cachedCheck
+	cacheFor time.Duration
+}
+
+func NewHealth(cacheFor time.Duration, checks ...Check) *Health {
+	h := &Health{cacheFor: cacheFor}
+	for _, c := range checks {
+		h.checks = append(h.checks, &cachedCheck{Check: c})
+	}
+	return h
+}
+
+// Readiness runs the checks whose results are stale, in parallel
+func (h *Health) Readiness() Readiness {
+	rd := Readiness{Ready: true, Checks: make(map[string]CheckResult, len(h.checks))}
+	results := make([]CheckResult, len(h.checks))
+	var wg sync.WaitGroup
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func(i int, c *cachedCheck) {
+			defer wg.Done()
+			results[i] = c.result(h.cacheFor)
+		}(i, c)
+	}
+	wg.Wait()
+	for i, c := range h.checks {
+		rd.Checks[c.Name] = results[i]
+		rd.Ready = rd.Ready && results[i].OK
+	}
+	return rd
+}
+
+func (c *cachedCheck) result(cacheFor time.Duration) CheckResult {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.last.CheckedAt.IsZero() && time.Since(c.last.CheckedAt) < cacheFor {
+		return c.last
+	}
+	// not the probe's context: a probe which hangs up should not cache a failure
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done(): // for checks which cannot be cancelled
+		err = errors.Errorf("timed out after %v", c.Timeout)
+	}
+	c.last = CheckResult{OK: err == nil, LatencyMS: int64(time.Since(start) / time.Millisecond), CheckedAt: start}
+	if err != nil {
+		c.last.Error = err.Error()
+	}
+	return c.last
+}
+
+// HealthzHandler responds while the process is alive, without checking its dependencies
+func HealthzHandler() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	})
+}
+
+// ReadyzHandler responds 200 when every check passes, and 503 otherwise, with each check's result
+func ReadyzHandler(h *Health) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rd := h.Readiness()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !rd.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(rd)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"go_report/auth"
+	"go_report/domain"
+	"go_report/failure"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A report sent with an Idempotency-Key header is stored once: retries with the same key (from the same client,
+// within the window) are answered with the original receipt.
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// claimIdempotencyKey claims the request's Idempotency-Key for the report, returning the key to be completed or
+// released once the report is stored ("" if the request has none). If the key was already used, it responds with
+// the original receipt (or fails, if the original is in progress or was a different report), and handled is true.
+func claimIdempotencyKey(w http.ResponseWriter, r *http.Request, s domain.IdempotencyStorer, window time.Duration, rpt domain.Report) (key string, handled bool) {
+	header := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+	if header == "" || window <= 0 {
+		return "", false
+	}
+	if len(header) > domain.MaxIdempotencyKeyLength {
+		failure.Fail(w, r, failure.New(errors.New("idempotency key too long"), http.StatusBadRequest,
+			fmt.Sprintf("%v must be at most %d characters", IdempotencyKeyHeader, domain.MaxIdempotencyKeyLength)))
+		return "", true
+	}
+	key = auth.ClientFromContext(r.Context()) + "|" + header // keys of different clients never collide
+	b, err := json.Marshal(rpt)
+	if err != nil {
+		failure.Fail(w, r, err)
+		return "", true
+	}
+	fingerprint := fmt.Sprintf("%x", md5.Sum(b))
+	claimed, held, err := s.ClaimIdempotencyKey(r.Context(), domain.IdempotencyRecord{Key: key, Fingerprint: fingerprint, Expires: time.Now().Add(window)})
+	switch {
+	case err != nil:
+		failure.Fail(w, r, errors.Wrap(err, "failed to claim idempotency key"))
+	case claimed:
+		return key, false
+	case held.Fingerprint != fingerprint:
+		failure.Fail(w, r, failure.New(errors.Errorf("idempotency key %v reused", key), http.StatusUnprocessableEntity,
+			IdempotencyKeyHeader+" was already used for a different report"))
+	case held.Receipt == nil:
+		w.Header().Set("Retry-After", "1")
+		failure.Fail(w, r, failure.New(errors.Errorf("idempotency key %v in progress", key), http.StatusConflict,
+			"A report with this "+IdempotencyKeyHeader+" is still being stored"))
+	default:
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(held.Receipt); err != nil {
+			failure.Fail(w, r, err)
+		}
+	}
+	return "", true
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go_report/domain"
+	"go_report/failure"
+	"go_report/gh"
+	"go_report/ingest"
+	"go_report/logging"
+	"go_report/stream"
+	"go_report/tracing"
+	"go_report/webhook"
+	"net/http"
+	"strconv"
+
+	"github.com/google/go-github/github"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// reportIngester stores reports, completing their idempotency keys and updating their group
+type reportIngester interface {
+	domain.ReportStorer
+	domain.IdempotencyStorer
+	domain.GroupStorer
+}
+
+// StoreReport returns the ingestion queue's processing of a report: it is stored (and counted), then published to live
+// tails, checked for regression, sent to webhooks, and (at or above issThreshold) raised as a github issue. Only a failure to store
+// the report fails the job, to be retried unless the store refused the report itself (see ingest.Permanent); failed
+// side effects are logged. With a tracer, each job is a span of the submitting request's trace.
+func StoreReport(issThreshold int, s reportIngester, ghs *gh.Service, b *stream.Broker, hooks *webhook.Dispatcher, m *Metrics, tracer trace.Tracer, logger *logging.Logger) func(ingest.Job) error {
+	return func(j ingest.Job) (err error) {
+		rpt := j.Report
+		logger := logger.With("request_id", j.RequestID, "gid", rpt.GID, "key", rpt.Key)
+		ctx, span := startJobSpan(tracer, j)
+		defer func() { span.EndErr(err) }()
+		ghs := ghs.WithContext(ctx)
+		rr, err := s.NewEntry(ctx, rpt)
+		if rf, ok := errors.Cause(err).(*failure.RequestFailure); ok && rf.Code < http.StatusInternalServerError {
+			return ingest.Permanent(errors.Wrap(err, "store refused entry"))
+		} else if err != nil {
+			return errors.Wrap(err, "failed to create store entry")
+		}
+		if j.IdempotencyKey != "" {
+			if err := s.CompleteIdempotencyKey(ctx, j.IdempotencyKey, rr); err != nil {
+				logger.Warn("failed to remember receipt of idempotency key", "idempotencyKey", j.IdempotencyKey, "err", err)
+			}
+		}
+		m.reports.WithLabelValues(rpt.Severity.String()).Inc()
+		if j.Attempts > 1 {
+			logger.Info("stored report after retrying", "attempts", j.Attempts)
+		}
+		b.Publish(rpt) // to live tails
+		grp, err := s.SelectGroupInfo(ctx, rr.GID)
+		if err != nil {
+			logger.Warn("failed to check group status", "err", err)
+		} else if grp.RegressedBy(rpt.Release) {
+			if regressed, err := s.RegressGroup(ctx, rr.GID, rpt.Release); err != nil {
+				logger.Warn("failed to regress group", "err", err)
+			} else if regressed != nil {
+				logger.Info("resolved group has regressed", "release", rpt.Release)
+				grp = regressed
+			}
+		}
+		if err := hooks.ReportStored(ctx, rpt, grp, rr.NewGroup); err != nil { // without the group if it could not be read
+			logger.Warn("failed to send report to webhooks", "err", err)
+		}
+		if issThreshold > 0 && int(rpt.Severity) >= issThreshold {
+			logger.Debug("creating github issue for report", "severity", rpt.Severity)
+			num, err := ghs.CreateGitHubIssue(github.IssueRequest{
+				Title:  github.String(rr.GID + " " + rr.Key),
+				Body:   github.String(fmt.Sprintf("---- Automated Crash Report ----\n\nKey: %v", rr.Key)),
+				Labels: &[]string{"Critical"},
+			})
+			if err != nil {
+				m.githubIssues.WithLabelValues("failed").Inc()
+				logger.Error("failed to create github issue", "err", err)
+			} else {
+				m.githubIssues.WithLabelValues("created").Inc()
+				logger.Info("created github issue for report", "number", num)
+				if err := s.SetGroupIssue(ctx, rr.GID, num); err != nil {
+					logger.Warn("failed to link github issue to group", "number", num, "err", err)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// ReleaseDropped is the ingestion queue's OnDrop: it releases the idempotency key of a report which was never
+// stored, so the client may send it again
+func ReleaseDropped(s domain.IdempotencyStorer, logger *logging.Logger) func(ingest.Job) {
+	return func(j ingest.Job) {
+		if j.IdempotencyKey == "" {
+			return
+		}
+		if err := s.ReleaseIdempotencyKey(context.Background(), j.IdempotencyKey); err != nil {
+			logger.Warn("failed to release idempotency key of dropped report", "request_id", j.RequestID, "idempotencyKey", j.IdempotencyKey, "err", err)
+		}
+	}
+}
+
+// startJobSpan starts the span of storing a report, as a child of the submitting request's span if it was traced
+func startJobSpan(tracer trace.Tracer, j ingest.Job) (context.Context, *tracing.Span) {
+	ctx := context.Background()
+	if tracer == nil {
+		return ctx, nil
+	}
+	attrs := []interface{}{"report.gid", j.Report.GID, "report.key", j.Report.Key, "ingest.attempts", j.Attempts}
+	ctx = tracing.ContextWithTraceParent(ctx, j.TraceParent)
+	return tracing.StartWith(ctx, tracer, "ingest report", tracing.Consumer, attrs...)
+}
+
+// ParseIngestConfig reads the ingestion queue's config values
+func ParseIngestConfig(size, workers, spillDir, maxSpill string) (ingest.Config, error) {
+	cfg := ingest.Config{SpillDir: spillDir}
+	var err error
+	if cfg.Size, err = strconv.Atoi(size); err != nil {
+		return cfg, errors.Wrap(err, "invalid ingestion queue size")
+	}
+	if cfg.Workers, err = strconv.Atoi(workers); err != nil {
+		return cfg, errors.Wrap(err, "invalid ingestion worker count")
+	}
+	if cfg.MaxSpill, err = strconv.Atoi(maxSpill); err != nil {
+		return cfg, errors.Wrap(err, "invalid ingestion spill size")
+	}
+	return cfg, nil
+}
@@ -0,0 +1,395 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go_report/domain"
+	"go_report/logging"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MaxAttempts   = 5           // times a job is processed before it is dropped
+	refillEvery   = time.Second // how often spilled jobs are moved back into the queue
+	spillSuffix   = ".json"
+	maxRetryDelay = 5 * time.Minute // between attempts of a failing job
+)
+
+var (
+	ErrFull   = errors.New("ingestion queue and spill are full")
+	ErrClosed = errors.New("ingestion queue is shut down")
+)
+
+// permanentError is a failure which retrying the job cannot fix
+type permanentError struct {
+	error
+}
+
+// Permanent marks a job's failure as one retrying cannot fix (i.e. an invalid report), so the job is dropped at once
+// rather than retried. Other failures (i.e. the store being unavailable or throttled) are retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err, or an error it wraps (see errors.Cause), was marked Permanent
+func IsPermanent(err error) bool {
+	for err != nil {
+		if _, ok := err.(permanentError); ok {
+			return true
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = c.Cause()
+	}
+	return false
+}
+
+// Job is a report accepted for storage, whose key is already known
+type Job struct {
+	Report      domain.Report `json:"report"`
+	Attempts    int           `json:"attempts"`
+	RequestID   string        `json:"requestId,omitempty"`   // of the request which submitted the report, for the logs
+	TraceParent string        `json:"traceParent,omitempty"` // of the request's span, so storing the report joins its trace
+	// IdempotencyKey is the claimed key of the request, to be completed once the report is stored (or released by
+	// Config.OnDrop if it never is)
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	file           string // spill file the job was read from, removed once it is done
+}
+
+// Config sizes the queue
+type Config struct {
+	Size     int    // jobs held in memory
+	Workers  int    // jobs processed at once
+	SpillDir string // where jobs wait on disk while the queue is full, or between failed attempts
+	MaxSpill int    // jobs on disk, beyond which new jobs are refused
+	// OnDrop, if set, is called with every job given up on: after a permanent failure or MaxAttempts, or when it could
+	// not be spilled
+	OnDrop func(Job)
+}
+
+// Queue stores reports in the background. Jobs which do not fit in memory are spilled to disk, and read back as the
+// queue empties; spilled jobs survive a restart. Failed jobs are retried (via the spill) with a growing delay, unless
+// they failed permanently (see Permanent).
+type Queue struct {
+	cfg     Config
+	process func(Job) error
+	log     *logging.Logger
+
+	jobs    chan Job
+	lock    sync.Mutex // guards closed, and sends to jobs once closing
+	closed  bool
+	stop    chan struct{} // stops the refill loop
+	abort   chan struct{} // closed when the shutdown deadline passes: workers spill their remaining jobs
+	workers sync.WaitGroup
+	refill  sync.WaitGroup
+
+	spillLock sync.Mutex
+	inflight  map[string]bool // spill files read into the queue, but not yet done
+	spilled   int             // jobs on disk
+	seq       uint64
+
+	processed, failed, dropped uint64
+}
+
+// New recovers any jobs spilled by a previous process, and starts the workers
+func New(cfg Config, process func(Job) error, logger *logging.Logger) (*Queue, error) {
+	if cfg.Size < 1 || cfg.Workers < 1 || cfg.MaxSpill < 1 || cfg.SpillDir == "" {
+		return nil, errors.Errorf("invalid ingestion queue config %+v", cfg)
+	}
+	if err := os.MkdirAll(cfg.SpillDir, 0700); err != nil {
+		return nil, errors.Wrap(err, "could not create ingestion spill directory")
+	}
+	q := &Queue{
+		cfg:      cfg,
+		process:  process,
+		log:      logger,
+		jobs:     make(chan Job, cfg.Size),
+		stop:     make(chan struct{}),
+		abort:    make(chan struct{}),
+		inflight: map[string]bool{},
+	}
+	files, err := q.spillFiles()
+	if err != nil {
+		return nil, err
+	}
+	q.spilled = len(files)
+	if q.spilled > 0 {
+		logger.Info("recovered spilled reports", "count", q.spilled, "dir", cfg.SpillDir)
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+	q.refill.Add(1)
+	go q.refillLoop()
+	return q, nil
+}
+
+// Enqueue accepts the job for storage, spilling it to disk if the queue is full. It fails with ErrFull if the
+// spill is full too, or ErrClosed once shutting down.
+func (q *Queue) Enqueue(j Job) error {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return ErrClosed
+	}
+	select {
+	case q.jobs <- j:
+		q.lock.Unlock()
+		return nil
+	default:
+		q.lock.Unlock()
+		return q.spill(j, time.Now(), true)
+	}
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+	for j := range q.jobs {
+		select {
+		case <-q.abort:
+			if j.file == "" { // otherwise it is still on disk
+				if err := q.spill(j, time.Now(), false); err != nil {
+					q.log.Error("lost report at shutdown", j.fields("err", err)...)
+					q.drop(j)
+				}
+			}
+			continue
+		default:
+		}
+		j.Attempts++
+		if err := q.process(j); err != nil {
+			atomic.AddUint64(&q.failed, 1)
+			if IsPermanent(err) {
+				q.log.Error("dropped report, it cannot be stored", j.fields("attempts", j.Attempts, "err", err)...)
+				q.drop(j)
+			} else if j.Attempts >= MaxAttempts {
+				q.log.Error("dropped report after too many attempts", j.fields("attempts", j.Attempts, "err", err)...)
+				q.drop(j)
+			} else if serr := q.spill(j, time.Now().Add(retryDelay(j.Attempts)), false); serr != nil {
+				q.log.Error("dropped report, could not spill it for retry", j.fields("spillErr", serr, "err", err)...)
+				q.drop(j)
+			} else {
+				q.log.Warn("failed to store report, will retry", j.fields("attempts", j.Attempts, "err", err)...)
+			}
+		} else {
+			atomic.AddUint64(&q.processed, 1)
+		}
+		q.done(j)
+	}
+}
+
+// drop counts a job given up on, and calls OnDrop with it
+func (q *Queue) drop(j Job) {
+	atomic.AddUint64(&q.dropped, 1)
+	if q.cfg.OnDrop != nil {
+		q.cfg.OnDrop(j)
+	}
+}
+
+// fields identify the job in log lines, followed by kv
+func (j Job) fields(kv ...interface{}) []interface{} {
+	return append([]interface{}{"request_id", j.RequestID, "gid", j.Report.GID, "key", j.Report.Key}, kv...)
+}
+
+func retryDelay(attempts int) time.Duration {
+	d := time.Duration(attempts*attempts) * time.Second
+	if d > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return d
+}
+
+// done removes the job's spill file, if it was read from one
+func (q *Queue) done(j Job) {
+	if j.file == "" {
+		return
+	}
+	q.spillLock.Lock()
+	defer q.spillLock.Unlock()
+	if err := os.Remove(filepath.Join(q.cfg.SpillDir, j.file)); err != nil && !os.IsNotExist(err) {
+		q.log.Warn("could not remove spill file", "file", j.file, "err", err)
+	} else {
+		q.spilled--
+	}
+	delete(q.inflight, j.file)
+}
+
+// spill writes the job to disk, to be queued no earlier than notBefore. Files are named by notBefore, so they are
+// read back in order. New jobs are refused once MaxSpill jobs are on disk; jobs already accepted are not.
+func (q *Queue) spill(j Job, notBefore time.Time, isNew bool) error {
+	q.spillLock.Lock()
+	defer q.spillLock.Unlock()
+	if isNew && q.spilled >= q.cfg.MaxSpill {
+		return ErrFull
+	}
+	b, err := json.Marshal(j)
+	if err != nil {
+		return errors.Wrap(err, "could not encode spilled report")
+	}
+	q.seq++
+	name := fmt.Sprintf("%020d-%06d%v", notBefore.UnixNano(), q.seq%1000000, spillSuffix)
+	tmp := filepath.Join(q.cfg.SpillDir, "."+name)
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "could not spill report")
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(q.cfg.SpillDir, name)) // complete files only
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrap(err, "could not spill report")
+	}
+	q.spilled++
+	return nil
+}
+
+// spillFiles lists the spilled jobs, oldest first
+func (q *Queue) spillFiles() ([]string, error) {
+	infos, err := ioutil.ReadDir(q.cfg.SpillDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read ingestion spill directory")
+	}
+	files := make([]string, 0, len(infos))
+	for _, fi := range infos {
+		if !fi.IsDir() && strings.HasSuffix(fi.Name(), spillSuffix) && !strings.HasPrefix(fi.Name(), ".") {
+			files = append(files, fi.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (q *Queue) refillLoop() {
+	defer q.refill.Done()
+	t := time.NewTicker(refillEvery)
+	defer t.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-t.C:
+			if err := q.refillOnce(); err != nil {
+				q.log.Warn("could not refill ingestion queue", "err", err)
+			}
+		}
+	}
+}
+
+// refillOnce moves due spilled jobs into the queue, until it is full
+func (q *Queue) refillOnce() error {
+	files, err := q.spillFiles()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano()
+	for _, name := range files {
+		if due, err := strconv.ParseInt(strings.SplitN(name, "-", 2)[0], 10, 64); err == nil && due > now {
+			break // the rest are later still
+		}
+		q.spillLock.Lock()
+		inflight := q.inflight[name]
+		q.spillLock.Unlock()
+		if inflight {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(q.cfg.SpillDir, name))
+		if err != nil {
+			return errors.Wrapf(err, "could not read spilled report %v", name)
+		}
+		j := Job{}
+		if err := json.Unmarshal(b, &j); err != nil {
+			q.log.Error("discarding unreadable spilled report", "file", name, "err", err)
+			q.done(Job{file: name})
+			continue
+		}
+		j.file = name
+		q.spillLock.Lock()
+		q.inflight[name] = true
+		q.spillLock.Unlock()
+		select {
+		case q.jobs <- j:
+		default:
+			q.spillLock.Lock()
+			delete(q.inflight, name)
+			q.spillLock.Unlock()
+			return nil // full, try again later
+		}
+	}
+	return nil
+}
+
+// Shutdown stops accepting jobs and waits for the queued jobs to be stored. Jobs still queued when ctx is done are
+// spilled, to be stored by the next process.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return ErrClosed
+	}
+	q.closed = true
+	q.lock.Unlock()
+	close(q.stop)
+	q.refill.Wait() // nothing else sends to jobs
+	close(q.jobs)
+
+	finished := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		close(q.abort)
+		<-finished // workers only spill from now on, or finish the job they are processing
+		return errors.Wrap(ctx.Err(), "ingestion queue did not drain, remaining reports were spilled")
+	}
+}
+
+// Stats are the queue's counters since the process started
+type Stats struct {
+	Queued    int    `json:"queued"`  // in memory
+	Spilled   int    `json:"spilled"` // on disk
+	Processed uint64 `json:"processed"`
+	Failed    uint64 `json:"failed"`  // attempts, including those retried
+	Dropped   uint64 `json:"dropped"` // after a permanent failure or too many attempts, or when they could not be retried
+}
+
+func (q *Queue) Stats() Stats {
+	q.spillLock.Lock()
+	spilled := q.spilled
+	q.spillLock.Unlock()
+	return Stats{
+		Queued:    len(q.jobs),
+		Spilled:   spilled,
+		Processed: atomic.LoadUint64(&q.processed),
+		Failed:    atomic.LoadUint64(&q.failed),
+		Dropped:   atomic.LoadUint64(&q.dropped),
+	}
+}
@@ -0,0 +1,117 @@
+package ingest
+
+import (
+	"context"
+	"go_report/domain"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func newTestQueue(t *testing.T, dir string, process func(Job) error, onDrop func(Job)) *Queue {
+	q, err := New(Config{Size: 1, Workers: 1, SpillDir: dir, MaxSpill: 2, OnDrop: onDrop}, process, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "ingest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestQueueOutcomes(t *testing.T) {
+	tests := []struct {
+		name      string
+		attempts  int // before the job is queued
+		err       error
+		processed uint64
+		dropped   bool
+	}{
+		{"stored", 0, nil, 1, false},
+		{"stored on last attempt", MaxAttempts - 1, nil, 1, false},
+		{"failed, retried", 0, errors.New("store down"), 0, false},
+		{"failed last attempt, dropped", MaxAttempts - 1, errors.New("store down"), 0, true},
+		{"failed permanently, dropped", 0, errors.Wrap(Permanent(errors.New("invalid report")), "store refused"), 0, true},
+	}
+	for _, tt := range tests {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		done, dropped := make(chan struct{}, 1), make(chan Job, 1)
+		q := newTestQueue(t, dir, func(Job) error { done <- struct{}{}; return tt.err }, func(j Job) { dropped <- j })
+		if err := q.Enqueue(Job{Report: domain.Report{GID: "g", Key: "k"}, Attempts: tt.attempts, IdempotencyKey: "idem"}); err != nil {
+			t.Fatal(err)
+		}
+		<-done
+		if err := q.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		st := q.Stats()
+		if st.Processed != tt.processed || (st.Dropped == 1) != tt.dropped {
+			t.Errorf("%v: got %+v", tt.name, st)
+		}
+		select {
+		case j := <-dropped:
+			if !tt.dropped || j.IdempotencyKey != "idem" {
+				t.Errorf("%v: OnDrop called with %+v", tt.name, j)
+			}
+		default:
+			if tt.dropped {
+				t.Errorf("%v: OnDrop not called", tt.name)
+			}
+		}
+		if wantSpilled := tt.err != nil && !tt.dropped; (st.Spilled == 1) != wantSpilled {
+			t.Errorf("%v: %d spilled", tt.name, st.Spilled)
+		}
+	}
+}
+
+func TestQueueSpillRecovery(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	block, started := make(chan struct{}), make(chan struct{}, 4)
+	q := newTestQueue(t, dir, func(Job) error { started <- struct{}{}; <-block; return nil }, nil)
+	// one job is processed, one waits in memory, two spill, and the spill is then full
+	for i := 0; i < 4; i++ {
+		if err := q.Enqueue(Job{Report: domain.Report{GID: "g"}}); err != nil {
+			t.Fatalf("job %d: %v", i, err)
+		}
+		if i == 0 {
+			<-started
+		}
+	}
+	if err := q.Enqueue(Job{}); err != ErrFull {
+		t.Fatalf("got %v, want ErrFull", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	go func() { <-ctx.Done(); time.Sleep(50 * time.Millisecond); close(block) }() // once Shutdown has aborted
+	_ = q.Shutdown(ctx)                                                           // the job waiting in memory is spilled too
+	if st := q.Stats(); st.Spilled != 3 {
+		t.Fatalf("%d spilled, want 3", st.Spilled)
+	}
+
+	// the next process stores them
+	stored := make(chan Job, 4)
+	q = newTestQueue(t, dir, func(j Job) error { stored <- j; return nil }, nil)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-stored:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("recovered %d of 3 spilled jobs", i)
+		}
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if st := q.Stats(); st.Spilled != 0 || st.Processed != 3 {
+		t.Errorf("got %+v after recovery", st)
+	}
+}
@@ -0,0 +1,204 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel reads a level name: debug, info, warn or error
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return Level(i), nil
+		}
+	}
+	return Info, errors.Errorf("unknown log level %q (debug, info, warn or error)", s)
+}
+
+// output is shared by a logger and every logger derived from it by With
+type output struct {
+	lock  sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+// Logger writes one json object per line, with the time, level & message, then its fields and the line's own
+// key/value pairs (i.e. logger.Warn("could not regress group", "gid", gid, "err", err)). A nil Logger discards.
+type Logger struct {
+	out    *output
+	fields []interface{} // key/value pairs, added by With
+}
+
+func New(w io.Writer, level Level) *Logger {
+	return &Logger{out: &output{w: w, level: level}}
+}
+
+// With returns a logger which adds the key/value pairs to every line
+func (l *Logger) With(kv ...interface{}) *Logger {
+	if l == nil {
+		return nil
+	}
+	return &Logger{out: l.out, fields: append(l.fields[:len(l.fields):len(l.fields)], kv...)}
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level >= l.out.level
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.write(Debug, msg, kv) }
+func (l *Logger) Info(msg string, kv ...interface{})  { l.write(Info, msg, kv) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { l.write(Warn, msg, kv) }
+func (l *Logger) Error(msg string, kv ...interface{}) { l.write(Error, msg, kv) }
+
+// Fatal logs at the error level, then exits
+func (l *Logger) Fatal(msg string, kv ...interface{}) {
+	l.write(Error, msg, kv)
+	os.Exit(1)
+}
+
+func (l *Logger) write(level Level, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	var b bytes.Buffer
+	b.WriteString(`{"time":`)
+	appendJSON(&b, time.Now().UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	appendJSON(&b, level.String())
+	b.WriteString(`,"msg":`)
+	appendJSON(&b, msg)
+	appendPairs(&b, l.fields)
+	appendPairs(&b, kv)
+	b.WriteString("}\n")
+
+	l.out.lock.Lock()
+	defer l.out.lock.Unlock()
+	_, _ = l.out.w.Write(b.Bytes())
+}
+
+func appendPairs(b *bytes.Buffer, kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		var v interface{} = "(missing)"
+		if i+1 < len(kv) {
+			v = kv[i+1]
+		}
+		b.WriteByte(',')
+		appendJSON(b, key)
+		b.WriteByte(':')
+		appendJSON(b, v)
+	}
+}
+
+func appendJSON(b *bytes.Buffer, v interface{}) {
+	switch val := v.(type) {
+	case error:
+		v = val.Error()
+	case fmt.Stringer:
+		v = val.String()
+	}
+	enc, err := json.Marshal(v)
+	if err != nil {
+		enc, _ = json.Marshal(fmt.Sprintf("%+v", v))
+	}
+	b.Write(enc)
+}
+
+// StdLogger adapts the logger for packages which take a *log.Logger (i.e. http.Server.ErrorLog), logging each of
+// their lines at the level
+func (l *Logger) StdLogger(level Level) *log.Logger {
+	return log.New(stdWriter{l, level}, "", 0)
+}
+
+type stdWriter struct {
+	l     *Logger
+	level Level
+}
+
+func (w stdWriter) Write(p []byte) (int, error) {
+	w.l.write(w.level, strings.TrimSpace(string(p)), nil)
+	return len(p), nil
+}
+
+var (
+	defaultLock sync.RWMutex
+	std         = New(os.Stderr, Info)
+)
+
+// SetDefault sets the logger of contexts without one (see FromContext)
+func SetDefault(l *Logger) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	std = l
+}
+
+func Default() *Logger {
+	defaultLock.RLock()
+	defer defaultLock.RUnlock()
+	return std
+}
+
+type ctxKey struct{}
+
+// holder lets a handler add fields (i.e. the authenticated subject) which the middleware which made the context
+// also sees, for its access log line
+type holder struct {
+	lock sync.Mutex
+	l    *Logger
+}
+
+// NewContext returns a context carrying the logger, for FromContext
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &holder{l: l})
+}
+
+// FromContext returns the context's logger, or the default logger
+func FromContext(ctx context.Context) *Logger {
+	if h, ok := ctx.Value(ctxKey{}).(*holder); ok {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		return h.l
+	}
+	return Default()
+}
+
+// AddFields adds key/value pairs to the context's logger, for every later FromContext of the context or those
+// derived from it
+func AddFields(ctx context.Context, kv ...interface{}) {
+	if h, ok := ctx.Value(ctxKey{}).(*holder); ok {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		h.l = h.l.With(kv...)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"go_report/auth"
+	"go_report/ingest"
+	"go_report/logging"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+)
+
+// Metrics are the server's prometheus metrics, served on their own port (METRICS_PORT), apart from the api
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestLatency  *prometheus.HistogramVec
+	reports         *prometheus.CounterVec
+	storeLatency    *prometheus.HistogramVec
+	storeErrors     *prometheus.CounterVec
+	githubCalls     *prometheus.CounterVec
+	githubLatency   *prometheus.HistogramVec
+	githubRemaining *prometheus.GaugeVec
+	githubIssues    *prometheus.CounterVec
+	tokenExchanges  *prometheus.CounterVec
+	webhookAttempts *prometheus.CounterVec
+	grpcCalls       *prometheus.CounterVec
+	grpcLatency     *prometheus.HistogramVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry:        prometheus.NewRegistry(),
+		requests:        counter("http_requests_total", "Requests by method, route pattern & status.", "method", "route", "status"),
+		requestLatency:  histogram("http_request_duration_seconds", "Time to handle a request (live tails: until the client leaves).", "method", "route"),
+		reports:         counter("reports_ingested_total", "Reports stored, by severity.", "severity"),
+		storeLatency:    histogram("store_operation_duration_seconds", "DynamoDB calls made by the store, by operation.", "operation"),
+		storeErrors:     counter("store_operation_errors_total", "DynamoDB calls which failed, by operation.", "operation"),
+		githubCalls:     counter("github_api_calls_total", "GitHub api calls, as the app installation or a developer, by status (error if no response).", "client", "method", "status"),
+		githubLatency:   histogram("github_api_duration_seconds", "GitHub api call latency.", "client"),
+		githubRemaining: prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "github_rate_limit_remaining", Help: "GitHub api calls remaining in the rate limit window, as of the last response."}, []string{"client"}),
+		githubIssues:    counter("github_issues_total", "GitHub issues raised for reports, by outcome (created or failed).", "outcome"),
+		tokenExchanges:  counter("token_exchanges_total", "Token exchanges, by audience (mss or github) & outcome (issued, denied, invalid or error).", "audience", "outcome"),
+		webhookAttempts: counter("webhook_delivery_attempts_total", "Webhook delivery attempts, by outcome (delivered, retrying or failed).", "outcome"),
+		grpcCalls:       counter("grpc_calls_total", "gRPC calls by method & status code.", "method", "code"),
+		grpcLatency:     histogram("grpc_call_duration_seconds", "Time to handle a gRPC call (streams: until the client closes them).", "method"),
+	}
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.requests, m.requestLatency, m.reports, m.storeLatency, m.storeErrors, m.githubCalls, m.githubLatency,
+		m.githubRemaining, m.githubIssues, m.tokenExchanges, m.webhookAttempts, m.grpcCalls, m.grpcLatency,
+	)
+	return m
+}
+
+func counter(name, help string, labels ...string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+}
+
+func histogram(name, help string, labels ...string) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: prometheus.DefBuckets}, labels)
+}
+
+// Handler serves the metrics for prometheus to scrape
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ServeMetrics serves the metrics at /metrics on the port, apart from the api so they need not be exposed with it.
+// A port of none serves no metrics. stop closes the listener.
+func ServeMetrics(port string, m *Metrics, t Timeouts, logger *logging.Logger) (stop func()) {
+	if port == "none" {
+		return func() {}
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	srv := NewServer(port, mux, t)
+	srv.ErrorLog = logger.StdLogger(logging.Warn)
+	go func() {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			logger.Error("metrics server failed", "port", port, "err", err)
+		}
+	}()
+	return func() { _ = srv.Close() }
+}
+
+// WatchQueue exposes the ingestion queue's stats
+func (m *Metrics) WatchQueue(q *ingest.Queue) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "ingest_queue_length", Help: "Reports waiting in memory to be stored."}, func() float64 { return float64(q.Stats().Queued) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "ingest_spilled_reports", Help: "Reports waiting on disk to be stored, or retried."}, func() float64 { return float64(q.Stats().Spilled) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{Name: "ingest_processed_total", Help: "Reports the ingestion queue has stored."}, func() float64 { return float64(q.Stats().Processed) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{Name: "ingest_failed_attempts_total", Help: "Attempts to store a report which failed, including those retried."}, func() float64 { return float64(q.Stats().Failed) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{Name: "ingest_dropped_total", Help: "Reports dropped after a permanent failure or too many failed attempts."}, func() float64 { return float64(q.Stats().Dropped) }),
+	)
+}
+
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Middleware counts & times requests by their chi route pattern (i.e. /v1/report/group/{reportsGID}/), so the
+// series do not grow with ids
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route, method := "unmatched", r.Method
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = strings.Replace(rctx.RoutePattern(), "//", "/", -1) // /ping is routed as /ping//
+		}
+		if !knownMethods[method] {
+			method = "other"
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		m.requestLatency.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveStoreCall records a DynamoDB call, see dynamo.Store.OnCall
+func (m *Metrics) ObserveStoreCall(operation string, took time.Duration, err error) {
+	m.storeLatency.WithLabelValues(operation).Observe(took.Seconds())
+	if err != nil {
+		m.storeErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// GitHubTransport records the GitHub api calls of a client ("app" or "user"), see gh.Service.Transport
+func (m *Metrics) GitHubTransport(client string) http.RoundTripper {
+	return roundTripper(func(r *http.Request) (*http.Response, error) {
+		start := time.Now()
+		rsp, err := http.DefaultTransport.RoundTrip(r)
+		m.githubLatency.WithLabelValues(client).Observe(time.Since(start).Seconds())
+		if err != nil {
+			m.githubCalls.WithLabelValues(client, r.Method, "error").Inc()
+			return rsp, err
+		}
+		m.githubCalls.WithLabelValues(client, r.Method, strconv.Itoa(rsp.StatusCode)).Inc()
+		if n, err := strconv.ParseFloat(rsp.Header.Get("X-RateLimit-Remaining"), 64); err == nil {
+			m.githubRemaining.WithLabelValues(client).Set(n)
+		}
+		return rsp, nil
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// ObserveTokenExchange records a token exchange, see auth.Service.OnExchange
+func (m *Metrics) ObserveTokenExchange(audience auth.JwtAudience, outcome string) {
+	m.tokenExchanges.WithLabelValues(string(audience), outcome).Inc()
+}
+
+// ObserveWebhookAttempt records a webhook delivery attempt, see webhook.Dispatcher.OnAttempt
+func (m *Metrics) ObserveWebhookAttempt(outcome string) {
+	m.webhookAttempts.WithLabelValues(outcome).Inc()
+}
+
+// ObserveCall records a gRPC call, see callInterceptors
+func (m *Metrics) ObserveCall(method string, code codes.Code, took time.Duration) {
+	m.grpcCalls.WithLabelValues(method, code.String()).Inc()
+	m.grpcLatency.WithLabelValues(method).Observe(took.Seconds())
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"go_report/auth"
 	"go_report/domain"
 	"go_report/failure"
-	"io/ioutil"
+	"go_report/logging"
+	"go_report/sentry"
+	"go_report/tracing"
 	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"time"
 )
 
 type RequestContextKey string
@@ -23,25 +30,64 @@ const (
 	ReportGIDVar           RequestContextKey = "reportsGID"
 	ReportSeverityLevelVar RequestContextKey = "severityLevel"
 	ReportCtxVar           RequestContextKey = "reportFromRequestBody"
+	ReleaseVar             RequestContextKey = "release"
+	CommentIDVar           RequestContextKey = "commentID"
+	WebhookIDVar           RequestContextKey = "webhookID"
+	DeliveryIDVar          RequestContextKey = "deliveryID"
 )
 
-// ReportCtx returns a middleware which adds a *Report to POST request context
-func ReportCtx(next http.Handler) http.Handler {
+// ReportCtx returns a middleware which adds a *Report to POST request context, reading the body within limits
+func ReportCtx(limits BodyLimits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				next.ServeHTTP(w, r.WithContext(r.Context()))
+				return
+			}
+			_, span := tracing.Start(r.Context(), "read report body")
+			b, err := limits.ReadBody(r)
+			span.SetAttributes("http.request_content_length", len(b))
+			span.EndErr(err)
+			if err != nil {
+				failure.Fail(w, r, err)
+				return
+			}
+			rpt := new(domain.Report)
+			_, span = tracing.Start(r.Context(), "decode report")
+			err = json.Unmarshal(b, rpt)
+			span.EndErr(err)
+			if err != nil {
+				failure.Fail(w, r, failure.New(err, http.StatusBadRequest, "Could not decode Report from request body"))
+				return
+			}
+			if err := rpt.ValidateGID(); err != nil {
+				failure.Fail(w, r, failure.New(err, http.StatusBadRequest, err.Error()))
+				return
+			}
+			if err := rpt.ValidateTags(); err != nil {
+				failure.Fail(w, r, failure.New(err, http.StatusBadRequest, err.Error()))
+				return
+			}
+			ctx := context.WithValue(r.Context(), string(ReportCtxVar), *rpt)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// URLFormat routes a path ending in a format's extension (i.e. /report.csv, see formatExtensions) as the path
+// without it, keeping the extension as middleware.URLFormatCtxKey. Unlike middleware.URLFormat, other dots are
+// left alone, so a release such as 1.2.3 is routed whole.
+func URLFormat(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			next.ServeHTTP(w, r.WithContext(r.Context()))
-			return
+		var format string
+		path := r.URL.Path
+		if i := strings.LastIndex(path, "."); i > strings.LastIndex(path, "/") {
+			if _, ok := formatExtensions[strings.ToLower(path[i+1:])]; ok {
+				format = path[i+1:]
+				chi.RouteContext(r.Context()).RoutePath = path[:i]
+			}
 		}
-		b, _ := ioutil.ReadAll(r.Body)
-		fmt.Println(string(b))
-		bb := bytes.NewBuffer(b)
-		rpt := new(domain.Report)
-		if err := json.NewDecoder(bb).Decode(rpt); err != nil {
-			failure.Fail(w, failure.New(err, http.StatusBadRequest, "Could not decode Report from request body"))
-			return
-		}
-		ctx := context.WithValue(r.Context(), string(ReportCtxVar), *rpt)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middleware.URLFormatCtxKey, format)))
 	})
 }
 
@@ -80,3 +126,148 @@ func ReportSeverityCtx(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+func ReleaseCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rel := chi.URLParam(r, string(ReleaseVar))
+		if rel == "" {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		ctx := context.WithValue(r.Context(), string(ReleaseVar), rel)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func CommentCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, string(CommentIDVar))
+		if id == "" {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		ctx := context.WithValue(r.Context(), string(CommentIDVar), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func WebhookCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, string(WebhookIDVar))
+		if id == "" {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		ctx := context.WithValue(r.Context(), string(WebhookIDVar), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func DeliveryCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, string(DeliveryIDVar))
+		if id == "" {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		ctx := context.WithValue(r.Context(), string(DeliveryIDVar), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Deprecated marks responses as deprecated since the given time, and due to be removed at sunset (RFC 9745 & 8594),
+// linking to the same path under the successor prefix (i.e. "/v1"). From sunset on, requests are answered 410 Gone.
+func Deprecated(since, sunset time.Time, successor string) func(http.Handler) http.Handler {
+	deprecation := fmt.Sprintf("@%d", since.Unix())
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", deprecation)
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunsetDate)
+			}
+			w.Header().Add("Link", fmt.Sprintf(`<%v%v>; rel="successor-version"`, successor, r.URL.Path))
+			if !sunset.IsZero() && !time.Now().Before(sunset) {
+				failure.SendError(w, http.StatusGone, fmt.Sprintf("Unversioned routes were removed on %v, use %v%v", sunset.UTC().Format("2006-01-02"), successor, r.URL.Path))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// streamingRoutes are exempt from HandlerTimeout, as they respond for as long as the client listens
+var streamingRoutes = []string{"/report/stream"}
+
+// HandlerTimeout fails requests which take longer than d to handle with 503 (the http.Server's WriteTimeout would
+// also cut off streaming routes, so it is enforced here instead). A d of 0 does not time out.
+func HandlerTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		// next runs in its own goroutine, out of reach of the router's Recover
+		timeout := http.TimeoutHandler(Recover(next), d, `{"code":503,"status":"Service Unavailable","userMessage":"Request timed out"}`)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, route := range streamingRoutes {
+				if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), route) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			timeout.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequestLogger gives each request a logger tagged with its chi RequestID (see logging.FromContext; the subject is
+// added once authenticated), and logs each request once handled
+func RequestLogger(logger *logging.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx := logging.NewContext(r.Context(), logger.With("request_id", middleware.GetReqID(r.Context())))
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			kv := []interface{}{"method", r.Method, "path", r.URL.Path, "status", status,
+				"bytes", ww.BytesWritten(), "durationMs", float64(time.Since(start)/time.Microsecond) / 1000, "remote", r.RemoteAddr}
+			if r.URL.RawQuery != "" {
+				kv = append(kv, "query", redactedQuery(r.URL))
+			}
+			logging.FromContext(ctx).Info("request", kv...)
+		})
+	}
+}
+
+// Recover responds 500 to a request whose handler panics, logging the panic & stack with the request's logger
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil && p != http.ErrAbortHandler {
+				logging.FromContext(r.Context()).Error("handler panicked", "panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+				failure.SendError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			} else if p != nil {
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// secretParams are query parameters which carry credentials (see auth.URLKey & auth.SentryKey)
+var secretParams = []string{auth.URLKeyParam, sentry.KeyParam}
+
+// redactedQuery is the url's query with the values of secretParams replaced, to be logged or traced
+func redactedQuery(u *url.URL) string {
+	q := u.Query()
+	for _, p := range secretParams {
+		if _, ok := q[p]; ok {
+			q.Set(p, "REDACTED")
+		}
+	}
+	return q.Encode()
+}
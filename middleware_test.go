@@ -0,0 +1,130 @@
+package main
+
+import (
+	"go_report/ratelimit"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+)
+
+func TestURLFormat(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(URLFormat)
+	var release, format string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		release = chi.URLParam(r, string(ReleaseVar))
+		format, _ = r.Context().Value(middleware.URLFormatCtxKey).(string)
+	}
+	r.Route("/v1/release/{"+string(ReleaseVar)+"}", func(r chi.Router) {
+		r.Get("/", record)
+	})
+	r.Get("/v1/report", record)
+
+	tests := []struct {
+		path            string
+		code            int
+		release, format string
+	}{
+		{"/v1/release/1.2.3/", http.StatusOK, "1.2.3", ""},
+		{"/v1/release/1.2.3", http.StatusOK, "1.2.3", ""},
+		{"/v1/release/2.0.0-rc.1/", http.StatusOK, "2.0.0-rc.1", ""},
+		{"/v1/release/v1/", http.StatusOK, "v1", ""},
+		{"/v1/report.csv", http.StatusOK, "", "csv"},
+		{"/v1/report.HTML", http.StatusOK, "", "HTML"},
+		{"/v1/report", http.StatusOK, "", ""},
+		{"/v1/report.xml", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		release, format = "", ""
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code || release != tt.release || format != tt.format {
+			t.Errorf("%v: got %d release %q format %q, want %d %q %q", tt.path, w.Code, release, format, tt.code, tt.release, tt.format)
+		}
+	}
+}
+
+func TestRedactedQuery(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"key=abc.def", "key=REDACTED"},
+		{"sentry_key=abc&sentry_version=7", "sentry_key=REDACTED&sentry_version=7"},
+		{"release=1.2.3", "release=1.2.3"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse("/v1/minidump/?" + tt.query)
+		if got := redactedQuery(u); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestDeprecatedSunset(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	since := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name   string
+		sunset time.Time
+		code   int
+	}{
+		{"before sunset", time.Now().Add(time.Hour), http.StatusOK},
+		{"after sunset", time.Now().Add(-time.Minute), http.StatusGone},
+		{"no sunset", time.Time{}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		Deprecated(since, tt.sunset, "/v1")(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/report/", nil))
+		if w.Code != tt.code {
+			t.Errorf("%v: got %d, want %d", tt.name, w.Code, tt.code)
+		}
+		if link := w.Header().Get("Link"); link != `</v1/report/>; rel="successor-version"` {
+			t.Errorf("%v: got link %q", tt.name, link)
+		}
+	}
+}
+
+func TestTokenRateLimit(t *testing.T) {
+	var body string
+	read := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+	})
+	limit, _ := ratelimit.ParseLimit("1/1h")
+	tests := []struct {
+		name, header, forwarded, body string
+		code                          int
+	}{
+		{"first of a certificate", "", "", `{"mssCert":"a"}`, http.StatusOK},
+		{"same certificate", "", "", `{"mssCert":" a\n"}`, http.StatusTooManyRequests},
+		{"other certificate", "", "", `{"mssCert":"b"}`, http.StatusOK},
+		{"first of an ip", "X-Forwarded-For", "10.0.0.1, 192.0.2.1", `{"mssCert":"a"}`, http.StatusOK},
+		{"spoofed ip", "X-Forwarded-For", "10.0.0.2, 192.0.2.1", `{"mssCert":"c"}`, http.StatusTooManyRequests},
+		{"without the header", "X-Forwarded-For", "", `{"ghUser":"dev"}`, http.StatusOK},
+	}
+	rl := RateLimiters{Token: ratelimit.New("token", limit, nil)}
+	for _, tt := range tests {
+		rl.ProxyHeader = tt.header
+		body = ""
+		r := httptest.NewRequest(http.MethodPost, "/token/", strings.NewReader(tt.body))
+		if tt.forwarded != "" {
+			r.Header.Set(tt.header, tt.forwarded)
+		}
+		w := httptest.NewRecorder()
+		TokenRateLimit(rl)(read).ServeHTTP(w, r)
+		if w.Code != tt.code {
+			t.Errorf("%v: got %d, want %d", tt.name, w.Code, tt.code)
+		}
+		if tt.code == http.StatusOK && body != tt.body {
+			t.Errorf("%v: handler read %q, want %q", tt.name, body, tt.body)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"context"
+	"go_report/auth/msscerts"
+	"go_report/logging"
+)
+
+// Migrate brings records written by older versions up to date, run once as `go_report migrate` before the new
+// version serves. Records already migrated are skipped, so it may be run again.
+func Migrate(ctx context.Context, logger *logging.Logger) error {
+	if err := msscerts.GetManager().Migrate(ctx); err != nil {
+		return err
+	}
+	logger.Info("migrations complete")
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"go_report/auth"
+	"go_report/blob"
+	"go_report/domain"
+	"go_report/failure"
+	"go_report/ingest"
+	"go_report/logging"
+	"go_report/minidump"
+	"go_report/tracing"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/pkg/errors"
+)
+
+// MinidumpIDVar is the id (sha256) of a stored minidump
+const MinidumpIDVar RequestContextKey = "minidumpID"
+
+const (
+	minidumpField     = "upload_file_minidump" // Crashpad's & Breakpad's form field of the dump
+	maxAnnotations    = 64
+	maxAnnotationSize = 1024
+)
+
+var gidPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,128}$`)
+
+// Minidumps stores the minidumps uploaded by Crashpad (or Breakpad) clients, as reports of a single group
+type Minidumps struct {
+	Blobs    *blob.Dir
+	GID      string // of the reports
+	MaxBytes int64  // of a dump, compressed & not
+}
+
+// ParseMinidumps reads the minidump config: the directory dumps are stored in, the gid of their reports, and the
+// largest dump in bytes
+func ParseMinidumps(dir, gid, maxBytes string) (*Minidumps, error) {
+	if !gidPattern.MatchString(gid) || (domain.Report{GID: gid}).ValidateGID() != nil {
+		return nil, errors.Errorf("invalid MINIDUMP_GID %q", gid)
+	}
+	max, err := strconv.ParseInt(maxBytes, 10, 64)
+	if err != nil || max <= 0 {
+		return nil, errors.Errorf("invalid MAX_MINIDUMP_BYTES %q", maxBytes)
+	}
+	blobs, err := blob.NewDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Minidumps{Blobs: blobs, GID: gid, MaxBytes: max}, nil
+}
+
+// minidumpRef links a report to its stored dump
+type minidumpRef struct {
+	ID       string `json:"id"` // sha256, see GetMinidumpHandler
+	Size     int64  `json:"size"`
+	FileName string `json:"fileName,omitempty"`
+}
+
+// MinidumpUploadHandler accepts a minidump as Crashpad uploads it: a (possibly gzip encoded) multipart form of the
+// dump & the client's annotations (prod, ver, plat, ...). The dump is stored, and a report of its metadata queued.
+// Crashpad keeps the plain text response as the report's id.
+func MinidumpUploadHandler(dumps *Minidumps, q *ingest.Queue) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context())
+		if r.ContentLength > dumps.MaxBytes {
+			failure.Fail(w, r, failure.New(errTooLarge, http.StatusRequestEntityTooLarge, "Minidump is too large"))
+			return
+		}
+		mr, err := minidumpForm(w, r, dumps.MaxBytes)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		ref, annotations, f, err := readMinidumpForm(mr, dumps)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		defer f.Close()
+
+		_, span := tracing.Start(r.Context(), "parse minidump", "minidump.size", ref.Size)
+		md, err := minidump.Parse(f, ref.Size)
+		span.EndErr(err)
+		if err == minidump.ErrNotMinidump {
+			if err := dumps.Blobs.Remove(ref.ID); err != nil {
+				logger.Warn("could not remove invalid minidump", "minidump", ref.ID, "err", err)
+			}
+			failure.Fail(w, r, failure.New(err, http.StatusBadRequest, "upload_file_minidump is not a minidump"))
+			return
+		}
+		// a dump which cannot be read is kept, to be read by other tools
+		rpt, err := minidumpReport(dumps.GID, ref, annotations, md, err)
+		if err != nil {
+			failure.Fail(w, r, failure.New(errors.Wrap(err, "failed to build minidump report"), http.StatusInternalServerError, ""))
+			return
+		}
+		rpt.ReceivedOn = time.Now()
+		if rel := auth.ReleaseFromContext(r.Context()); rel != "" {
+			rpt.Release = rel // the certificate's release is trusted over the annotations'
+		}
+		if err := rpt.ValidateSize(); err != nil {
+			failure.Fail(w, r, failure.New(err, http.StatusRequestEntityTooLarge, err.Error()))
+			return
+		}
+		if rpt.Key, err = rpt.ContentKey(); err != nil {
+			failure.Fail(w, r, failure.New(errors.Wrap(err, "failed to key report"), http.StatusInternalServerError, ""))
+			return
+		}
+		if err := q.Enqueue(ingest.Job{Report: rpt, RequestID: middleware.GetReqID(r.Context()), TraceParent: tracing.TraceParentFromContext(r.Context())}); err != nil {
+			w.Header().Set("Retry-After", "30")
+			failure.Fail(w, r, failure.New(errors.Wrap(err, "failed to queue report"), http.StatusServiceUnavailable, "Too many reports are waiting to be stored, retry later"))
+			return
+		}
+		logger.Debug("queued minidump", "minidump", ref.ID, "gid", rpt.GID, "key", rpt.Key)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if _, err := io.WriteString(w, rpt.Key); err != nil {
+			logger.Warn("failed to write minidump response", "err", err)
+		}
+	})
+}
+
+// minidumpForm reads the request as a multipart form of at most max bytes, decompressing a gzip encoded body
+func minidumpForm(w http.ResponseWriter, r *http.Request, max int64) (*multipart.Reader, error) {
+	mt, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/form-data" || params["boundary"] == "" {
+		return nil, failure.New(errors.Errorf("unsupported content type %q", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType, "Content-Type must be multipart/form-data")
+	}
+	var body io.Reader = http.MaxBytesReader(w, r.Body, max)
+	switch enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		if body, err = gzip.NewReader(body); err != nil {
+			return nil, failure.New(err, http.StatusBadRequest, "Could not decompress gzip request body")
+		}
+	default:
+		return nil, failure.New(errors.Errorf("unsupported content encoding %q", enc), http.StatusUnsupportedMediaType, "Content-Encoding must be gzip")
+	}
+	return multipart.NewReader(body, params["boundary"]), nil
+}
+
+// readMinidumpForm stores the form's dump, returning it opened for reading (closed by the caller), and reads the
+// other fields as annotations
+func readMinidumpForm(mr *multipart.Reader, dumps *Minidumps) (ref minidumpRef, annotations map[string]string, dump *os.File, err error) {
+	annotations = map[string]string{}
+	defer func() {
+		if err != nil && dump != nil {
+			dump.Close()
+		}
+	}()
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return ref, nil, dump, formError(err)
+		}
+		name := p.FormName()
+		switch {
+		case name == minidumpField && dump == nil:
+			ref.FileName = p.FileName()
+			body := &bodyReader{r: p}
+			if ref.ID, ref.Size, dump, err = dumps.Blobs.Write(body, dumps.MaxBytes); err == blob.ErrTooLarge {
+				return ref, nil, nil, failure.New(err, http.StatusRequestEntityTooLarge, "Minidump is too large")
+			} else if body.err != nil {
+				return ref, nil, nil, formError(body.err)
+			} else if err != nil {
+				return ref, nil, nil, failure.New(err, http.StatusInternalServerError, "")
+			}
+		case name != "" && p.FileName() == "" && len(annotations) < maxAnnotations:
+			b, err := ioutil.ReadAll(io.LimitReader(p, maxAnnotationSize+1))
+			if err != nil {
+				return ref, nil, dump, formError(err)
+			}
+			if len(b) <= maxAnnotationSize {
+				annotations[name] = string(b)
+			}
+		default: // other attachments & excess annotations are dropped
+			if _, err := io.Copy(ioutil.Discard, p); err != nil {
+				return ref, nil, dump, formError(err)
+			}
+		}
+	}
+	if dump == nil {
+		return ref, nil, nil, failure.New(errors.New("no minidump in form"), http.StatusBadRequest, "Form has no "+minidumpField+" file")
+	}
+	return ref, annotations, dump, nil
+}
+
+// bodyReader keeps the error of reading the request, to tell it from errors of storing the dump
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// formError is the RequestFailure of a request body which could not be read
+func formError(err error) error {
+	if err.Error() == "http: request body too large" { // of http.MaxBytesReader
+		return failure.New(errTooLarge, http.StatusRequestEntityTooLarge, "Minidump is too large")
+	}
+	return failure.New(err, http.StatusBadRequest, "Could not read multipart form")
+}
+
+// minidumpReport is the report of a dump: its metadata (or why it could not be read), annotations and stored file.
+// The release is the ver annotation, tagged with the os, cpu & exception.
+func minidumpReport(gid string, ref minidumpRef, annotations map[string]string, md *minidump.Minidump, parseErr error) (domain.Report, error) {
+	content := map[string]interface{}{}
+	if md != nil {
+		b, err := json.Marshal(md)
+		if err != nil {
+			return domain.Report{}, err
+		}
+		if err := json.Unmarshal(b, &content); err != nil {
+			return domain.Report{}, err
+		}
+	}
+	if parseErr != nil {
+		content["parseError"] = parseErr.Error()
+	}
+	content["minidump"] = map[string]interface{}{"id": ref.ID, "size": ref.Size, "fileName": ref.FileName}
+	content["annotations"] = annotations
+	rpt := domain.Report{
+		GID:      gid,
+		Severity: domain.CrashType,
+		Content:  content,
+		Release:  annotations["ver"],
+	}
+	tags := map[string]string{}
+	add := func(t, v string) {
+		if v != "" && len(v) <= domain.MaxValueLength {
+			tags[t] = v
+		}
+	}
+	add("product", annotations["prod"])
+	if md != nil {
+		add("os", md.OS.Platform)
+		add("osVersion", md.OS.Version)
+		add("arch", md.CPU.Arch)
+		if md.Exception != nil {
+			add("exception", md.Exception.Name)
+		}
+	}
+	if len(tags) > 0 {
+		rpt.Tags = tags
+	}
+	return rpt, nil
+}
+
+// GetMinidumpHandler serves a stored dump, by the id of its report's content.minidump
+func GetMinidumpHandler(dumps *Minidumps) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, string(MinidumpIDVar))
+		f, err := dumps.Blobs.Open(id)
+		if err == blob.ErrNotFound {
+			failure.Fail(w, r, failure.New(err, http.StatusNotFound, "No minidump with this id"))
+			return
+		} else if err != nil {
+			failure.Fail(w, r, failure.New(err, http.StatusInternalServerError, ""))
+			return
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			failure.Fail(w, r, failure.New(errors.Wrap(err, "could not stat minidump"), http.StatusInternalServerError, ""))
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", id+".dmp"))
+		http.ServeContent(w, r, id+".dmp", fi.ModTime(), f)
+	})
+}
@@ -0,0 +1,310 @@
+// Package minidump reads the metadata of a minidump (as written by Crashpad & Breakpad): the system, the exception
+// and the thread which raised it, and the loaded modules. It does not walk stacks.
+package minidump
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	signature = 0x504d444d // "MDMP"
+
+	threadListStream = 3
+	moduleListStream = 4
+	exceptionStream  = 6
+	systemInfoStream = 7
+
+	headerSize    = 32
+	directorySize = 12
+	moduleSize    = 108
+	threadSize    = 48
+
+	// MaxModules are read, of a module list (a process may load thousands)
+	MaxModules = 500
+	maxString  = 1024 // utf-16 bytes of a name
+)
+
+// ErrNotMinidump is returned by Parse for files which do not start with the minidump signature
+var ErrNotMinidump = errors.New("not a minidump")
+
+// Minidump is the metadata of a dump. Streams which are absent or unreadable are left empty; Errors lists why.
+type Minidump struct {
+	OS               OS         `json:"os"`
+	CPU              CPU        `json:"cpu"`
+	Exception        *Exception `json:"exception,omitempty"`
+	CrashingThreadID *uint32    `json:"crashingThreadId,omitempty"`
+	Threads          int        `json:"threads"`
+	Modules          []Module   `json:"modules"`
+	ModulesTotal     int        `json:"modulesTotal"` // more than len(Modules) when there were more than MaxModules
+	Errors           []string   `json:"errors,omitempty"`
+}
+
+type OS struct {
+	Platform string `json:"platform"`        // windows, mac, linux, ...
+	Version  string `json:"version"`         // major.minor.build
+	Build    string `json:"build,omitempty"` // i.e. a service pack, or uname
+}
+
+type CPU struct {
+	Arch  string `json:"arch"`
+	Count int    `json:"count"`
+}
+
+type Exception struct {
+	ThreadID uint32 `json:"threadId"`
+	Code     string `json:"code"`           // hex
+	Name     string `json:"name,omitempty"` // i.e. EXCEPTION_ACCESS_VIOLATION or SIGSEGV
+	Address  string `json:"address"`        // hex
+}
+
+type Module struct {
+	Name    string `json:"name"` // file name, without its directory
+	Base    string `json:"base"` // hex
+	Size    uint32 `json:"size"`
+	DebugID string `json:"debugId,omitempty"` // pdb guid & age, or elf build id
+}
+
+// reader reads the bytes at offsets of the dump, checking they are within it
+type reader struct {
+	r    io.ReaderAt
+	size int64
+}
+
+func (rd reader) bytes(off int64, n int) ([]byte, error) {
+	if off < 0 || n < 0 || off+int64(n) > rd.size {
+		return nil, errors.Errorf("%d bytes at %#x are beyond the end of the dump", n, off)
+	}
+	b := make([]byte, n)
+	if n == 0 { // ReadAt may fail at the end of the dump, even for nothing
+		return b, nil
+	}
+	if _, err := rd.r.ReadAt(b, off); err != nil {
+		return nil, errors.Wrapf(err, "could not read %d bytes at %#x", n, off)
+	}
+	return b, nil
+}
+
+// Parse reads the dump of the given size
+func Parse(r io.ReaderAt, size int64) (*Minidump, error) {
+	rd := reader{r, size}
+	h, err := rd.bytes(0, headerSize)
+	if err != nil || binary.LittleEndian.Uint32(h) != signature {
+		return nil, ErrNotMinidump
+	}
+	streams := binary.LittleEndian.Uint32(h[8:])
+	dirRva := int64(binary.LittleEndian.Uint32(h[12:]))
+	if int64(streams)*directorySize > size {
+		return nil, errors.Errorf("minidump claims %d streams", streams)
+	}
+	dir, err := rd.bytes(dirRva, int(streams)*directorySize)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the stream directory")
+	}
+
+	md := &Minidump{Modules: []Module{}}
+	type parser func(reader, []byte, *Minidump) error
+	parsers := map[uint32]struct {
+		name  string
+		parse parser
+	}{
+		systemInfoStream: {"system info", parseSystemInfo},
+		exceptionStream:  {"exception", parseException},
+		threadListStream: {"thread list", parseThreadList},
+		moduleListStream: {"module list", parseModuleList},
+	}
+	// the system info names exception codes, so it is parsed first
+	order := []uint32{systemInfoStream, exceptionStream, threadListStream, moduleListStream}
+	for _, typ := range order {
+		for i := 0; i < int(streams); i++ {
+			e := dir[i*directorySize:]
+			if binary.LittleEndian.Uint32(e) != typ {
+				continue
+			}
+			p := parsers[typ]
+			data, err := rd.bytes(int64(binary.LittleEndian.Uint32(e[8:])), int(binary.LittleEndian.Uint32(e[4:])))
+			if err == nil {
+				err = p.parse(rd, data, md)
+			}
+			if err != nil {
+				md.Errors = append(md.Errors, fmt.Sprintf("%v stream: %v", p.name, err))
+			}
+			break
+		}
+	}
+	return md, nil
+}
+
+var platforms = map[uint32]string{
+	0: "windows", 1: "windows", 2: "windows", // win32s, 9x, nt
+	0x8000: "unix", 0x8101: "mac", 0x8102: "ios", 0x8201: "linux", 0x8202: "solaris", 0x8203: "android",
+	0x8204: "ps3", 0x8205: "nacl", 0x8206: "fuchsia",
+}
+
+var arches = map[uint16]string{
+	0: "x86", 1: "mips", 2: "alpha", 3: "ppc", 4: "shx", 5: "arm", 6: "ia64", 7: "alpha64", 8: "msil", 9: "amd64",
+	10: "x86_win64", 12: "arm64", 0x8001: "sparc", 0x8002: "ppc64", 0x8003: "arm64", 0x8004: "mips64",
+}
+
+func parseSystemInfo(rd reader, b []byte, md *Minidump) error {
+	if len(b) < 32 {
+		return errors.New("stream is too short")
+	}
+	arch := binary.LittleEndian.Uint16(b)
+	md.CPU = CPU{Arch: arches[arch], Count: int(b[6])}
+	if md.CPU.Arch == "" {
+		md.CPU.Arch = fmt.Sprintf("unknown(%#x)", arch)
+	}
+	platform := binary.LittleEndian.Uint32(b[20:])
+	md.OS.Platform = platforms[platform]
+	if md.OS.Platform == "" {
+		md.OS.Platform = fmt.Sprintf("unknown(%#x)", platform)
+	}
+	md.OS.Version = fmt.Sprintf("%d.%d.%d", binary.LittleEndian.Uint32(b[8:]), binary.LittleEndian.Uint32(b[12:]), binary.LittleEndian.Uint32(b[16:]))
+	if rva := binary.LittleEndian.Uint32(b[24:]); rva != 0 {
+		build, err := readString(rd, int64(rva))
+		if err != nil {
+			return errors.Wrap(err, "could not read the os build")
+		}
+		md.OS.Build = build
+	}
+	return nil
+}
+
+func parseException(rd reader, b []byte, md *Minidump) error {
+	if len(b) < 32 {
+		return errors.New("stream is too short")
+	}
+	thread := binary.LittleEndian.Uint32(b)
+	code := binary.LittleEndian.Uint32(b[8:])
+	md.Exception = &Exception{
+		ThreadID: thread,
+		Code:     fmt.Sprintf("%#x", code),
+		Name:     exceptionName(md.OS.Platform, code),
+		Address:  fmt.Sprintf("%#x", binary.LittleEndian.Uint64(b[24:])),
+	}
+	md.CrashingThreadID = &thread
+	return nil
+}
+
+func parseThreadList(rd reader, b []byte, md *Minidump) error {
+	if len(b) < 4 {
+		return errors.New("stream is too short")
+	}
+	n := int(binary.LittleEndian.Uint32(b))
+	if 4+n*threadSize > len(b) {
+		return errors.Errorf("%d threads overrun the stream", n)
+	}
+	md.Threads = n
+	return nil
+}
+
+func parseModuleList(rd reader, b []byte, md *Minidump) error {
+	if len(b) < 4 {
+		return errors.New("stream is too short")
+	}
+	n := int(binary.LittleEndian.Uint32(b))
+	if 4+n*moduleSize > len(b) {
+		return errors.Errorf("%d modules overrun the stream", n)
+	}
+	md.ModulesTotal = n
+	if n > MaxModules {
+		n = MaxModules
+	}
+	for i := 0; i < n; i++ {
+		m := b[4+i*moduleSize:]
+		mod := Module{
+			Base: fmt.Sprintf("%#x", binary.LittleEndian.Uint64(m)),
+			Size: binary.LittleEndian.Uint32(m[8:]),
+		}
+		name, err := readString(rd, int64(binary.LittleEndian.Uint32(m[20:])))
+		if err != nil {
+			return errors.Wrapf(err, "could not read the name of module %d", i)
+		}
+		// windows paths are named with backslashes
+		mod.Name = path.Base(strings.Replace(name, `\`, "/", -1))
+		cvSize, cvRva := binary.LittleEndian.Uint32(m[76:]), binary.LittleEndian.Uint32(m[80:])
+		if cvSize > 0 && cvSize < 4096 {
+			if cv, err := rd.bytes(int64(cvRva), int(cvSize)); err == nil {
+				mod.DebugID = debugID(cv)
+			}
+		}
+		md.Modules = append(md.Modules, mod)
+	}
+	return nil
+}
+
+// debugID reads a module's CodeView record: a pdb 7 guid & age ("RSDS"), or an elf build id ("BpEL")
+func debugID(cv []byte) string {
+	if len(cv) < 4 {
+		return ""
+	}
+	switch string(cv[:4]) {
+	case "RSDS":
+		if len(cv) < 24 {
+			return ""
+		}
+		g := cv[4:20]
+		return strings.ToUpper(fmt.Sprintf("%08x%04x%04x%x%x", binary.LittleEndian.Uint32(g), binary.LittleEndian.Uint16(g[4:]),
+			binary.LittleEndian.Uint16(g[6:]), g[8:16], binary.LittleEndian.Uint32(cv[20:])))
+	case "BpEL":
+		return fmt.Sprintf("%x", cv[4:])
+	}
+	return ""
+}
+
+// readString reads a MINIDUMP_STRING: its length in bytes, then utf-16
+func readString(rd reader, rva int64) (string, error) {
+	l, err := rd.bytes(rva, 4)
+	if err != nil {
+		return "", err
+	}
+	n := int(binary.LittleEndian.Uint32(l))
+	if n > maxString {
+		n = maxString
+	}
+	b, err := rd.bytes(rva+4, n&^1)
+	if err != nil {
+		return "", err
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u)), nil
+}
+
+var windowsExceptions = map[uint32]string{
+	0x80000003: "EXCEPTION_BREAKPOINT", 0xc0000005: "EXCEPTION_ACCESS_VIOLATION", 0xc000001d: "EXCEPTION_ILLEGAL_INSTRUCTION",
+	0xc0000094: "EXCEPTION_INT_DIVIDE_BY_ZERO", 0xc00000fd: "EXCEPTION_STACK_OVERFLOW", 0xc0000409: "STATUS_STACK_BUFFER_OVERRUN",
+	0xc0000374: "STATUS_HEAP_CORRUPTION", 0xe06d7363: "C++ exception", 0xc0000006: "EXCEPTION_IN_PAGE_ERROR",
+	0xc0000025: "EXCEPTION_NONCONTINUABLE_EXCEPTION", 0xc0000602: "STATUS_FAIL_FAST_EXCEPTION",
+}
+
+var signals = map[uint32]string{
+	4: "SIGILL", 5: "SIGTRAP", 6: "SIGABRT", 7: "SIGBUS", 8: "SIGFPE", 9: "SIGKILL", 11: "SIGSEGV", 13: "SIGPIPE", 15: "SIGTERM",
+}
+
+var machExceptions = map[uint32]string{
+	1: "EXC_BAD_ACCESS", 2: "EXC_BAD_INSTRUCTION", 3: "EXC_ARITHMETIC", 4: "EXC_EMULATION", 5: "EXC_SOFTWARE",
+	6: "EXC_BREAKPOINT", 10: "EXC_CRASH", 11: "EXC_RESOURCE", 12: "EXC_GUARD",
+	0x43507378: "simulated", // Crashpad's kMachExceptionSimulated, a dump without a crash
+}
+
+func exceptionName(platform string, code uint32) string {
+	switch platform {
+	case "windows":
+		return windowsExceptions[code]
+	case "mac", "ios":
+		return machExceptions[code]
+	case "linux", "android", "solaris", "unix", "fuchsia":
+		return signals[code]
+	}
+	return ""
+}
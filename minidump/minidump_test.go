@@ -0,0 +1,228 @@
+package minidump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// dump builds a minidump: the header, then blobs added by rva, then the stream directory
+type dump struct {
+	buf     []byte
+	streams [][3]uint32 // type, size, rva
+}
+
+func newDump() *dump {
+	return &dump{buf: make([]byte, headerSize)}
+}
+
+func (d *dump) add(b []byte) uint32 {
+	rva := uint32(len(d.buf))
+	d.buf = append(d.buf, b...)
+	return rva
+}
+
+func (d *dump) stream(typ uint32, b []byte) {
+	d.streams = append(d.streams, [3]uint32{typ, uint32(len(b)), d.add(b)})
+}
+
+func (d *dump) str(s string) uint32 {
+	u := utf16.Encode([]rune(s))
+	b := le(uint32(2 * len(u)))
+	for _, c := range u {
+		b = append(b, le16(c)...)
+	}
+	return d.add(b)
+}
+
+func (d *dump) bytes() []byte {
+	var dir []byte
+	for _, s := range d.streams {
+		dir = append(dir, le(s[0], s[1], s[2])...)
+	}
+	rva := d.add(dir)
+	b := append([]byte{}, d.buf...)
+	copy(b, le(signature, 0xa793, uint32(len(d.streams)), rva))
+	return b
+}
+
+func le(vs ...uint32) []byte {
+	b := make([]byte, 4*len(vs))
+	for i, v := range vs {
+		binary.LittleEndian.PutUint32(b[4*i:], v)
+	}
+	return b
+}
+
+func le16(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, v)
+	return b
+}
+
+func le64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, v)
+	return b
+}
+
+func systemInfo(arch uint16, cpus byte, platform, build uint32) []byte {
+	b := append(le16(arch), 0, 0, 0, 0, cpus, 0)
+	b = append(b, le(10, 0, 19041, platform, build)...)
+	return append(b, make([]byte, 24)...)
+}
+
+func exception(thread, code uint32, addr uint64) []byte {
+	b := le(thread, 0, code, 0, 0, 0)
+	b = append(b, le64(addr)...)
+	return append(b, make([]byte, 136)...)
+}
+
+func module(base uint64, size, name, cvSize, cvRva uint32) []byte {
+	m := make([]byte, moduleSize)
+	copy(m, le64(base))
+	copy(m[8:], le(size))
+	copy(m[20:], le(name))
+	copy(m[76:], le(cvSize, cvRva))
+	return m
+}
+
+func moduleList(mods ...[]byte) []byte {
+	b := le(uint32(len(mods)))
+	for _, m := range mods {
+		b = append(b, m...)
+	}
+	return b
+}
+
+func u32(v uint32) *uint32 { return &v }
+
+func TestParse(t *testing.T) {
+	rsds := append([]byte("RSDS"), 0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff)
+	rsds = append(rsds, le(2)...)
+
+	tests := []struct {
+		name  string
+		build func(d *dump)
+		want  Minidump
+	}{
+		{"empty", func(d *dump) {}, Minidump{Modules: []Module{}}},
+		{"windows crash", func(d *dump) {
+			d.stream(exceptionStream, exception(7, 0xc0000005, 0xdead))
+			d.stream(systemInfoStream, systemInfo(9, 8, 2, d.str("Service Pack 1")))
+			d.stream(threadListStream, append(le(2), make([]byte, 2*threadSize)...))
+			cv := d.add(rsds)
+			d.stream(moduleListStream, moduleList(
+				module(0x400000, 0x1000, d.str(`C:\app\app.exe`), uint32(len(rsds)), cv),
+				module(0x7ff00000, 0x2000, d.str(`C:\Windows\ntdll.dll`), 0, 0),
+			))
+		}, Minidump{
+			OS:               OS{Platform: "windows", Version: "10.0.19041", Build: "Service Pack 1"},
+			CPU:              CPU{Arch: "amd64", Count: 8},
+			Exception:        &Exception{ThreadID: 7, Code: "0xc0000005", Name: "EXCEPTION_ACCESS_VIOLATION", Address: "0xdead"},
+			CrashingThreadID: u32(7),
+			Threads:          2,
+			Modules: []Module{
+				{Name: "app.exe", Base: "0x400000", Size: 0x1000, DebugID: "0011223344556677" + "8899AABBCCDDEEFF2"},
+				{Name: "ntdll.dll", Base: "0x7ff00000", Size: 0x2000},
+			},
+			ModulesTotal: 2,
+		}},
+		{"linux signal", func(d *dump) {
+			d.stream(systemInfoStream, systemInfo(0x8003, 4, 0x8201, 0))
+			d.stream(exceptionStream, exception(1, 11, 0))
+			elf := d.add(append([]byte("BpEL"), 0xab, 0xcd))
+			d.stream(moduleListStream, moduleList(module(0x1000, 10, d.str("/usr/lib/libc.so.6"), 6, elf)))
+		}, Minidump{
+			OS:               OS{Platform: "linux", Version: "10.0.19041"},
+			CPU:              CPU{Arch: "arm64", Count: 4},
+			Exception:        &Exception{ThreadID: 1, Code: "0xb", Name: "SIGSEGV", Address: "0x0"},
+			CrashingThreadID: u32(1),
+			Modules:          []Module{{Name: "libc.so.6", Base: "0x1000", Size: 10, DebugID: "abcd"}},
+			ModulesTotal:     1,
+		}},
+		{"unknown system", func(d *dump) {
+			d.stream(systemInfoStream, systemInfo(0x77, 1, 0x9999, 0))
+			d.stream(exceptionStream, exception(1, 11, 0))
+		}, Minidump{
+			OS:               OS{Platform: "unknown(0x9999)", Version: "10.0.19041"},
+			CPU:              CPU{Arch: "unknown(0x77)", Count: 1},
+			Exception:        &Exception{ThreadID: 1, Code: "0xb", Address: "0x0"},
+			CrashingThreadID: u32(1),
+			Modules:          []Module{},
+		}},
+		{"unreadable streams", func(d *dump) {
+			d.stream(systemInfoStream, []byte{1, 2})
+			d.stream(threadListStream, le(1000))
+			d.streams = append(d.streams, [3]uint32{exceptionStream, 64, 1 << 20})
+			d.stream(moduleListStream, moduleList(module(0, 0, 1<<20, 0, 0)))
+		}, Minidump{
+			Modules: []Module{},
+			Errors: []string{
+				"system info stream: stream is too short",
+				"exception stream: 64 bytes at 0x100000 are beyond the end of the dump",
+				"thread list stream: 1000 threads overrun the stream",
+				"module list stream: could not read the name of module 0: 4 bytes at 0x100000 are beyond the end of the dump",
+			},
+			ModulesTotal: 1,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDump()
+			tt.build(d)
+			b := d.bytes()
+			md, err := Parse(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*md, tt.want) {
+				t.Errorf("got %+v, want %+v", *md, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMaxModules(t *testing.T) {
+	d := newDump()
+	name := d.str("m")
+	mods := make([][]byte, MaxModules+1)
+	for i := range mods {
+		mods[i] = module(uint64(i), 1, name, 0, 0)
+	}
+	d.stream(moduleListStream, moduleList(mods...))
+	b := d.bytes()
+	md, err := Parse(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(md.Modules) != MaxModules || md.ModulesTotal != MaxModules+1 {
+		t.Errorf("read %d of %d modules, want %d of %d", len(md.Modules), md.ModulesTotal, MaxModules, MaxModules+1)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	valid := newDump().bytes()
+	tests := []struct {
+		name string
+		b    []byte
+		want string
+	}{
+		{"empty", nil, ErrNotMinidump.Error()},
+		{"short", valid[:headerSize-1], ErrNotMinidump.Error()},
+		{"not a minidump", append([]byte("ELF!"), valid[4:]...), ErrNotMinidump.Error()},
+		{"too many streams", append(le(signature, 0, 1<<30, headerSize), make([]byte, 16)...), "claims"},
+		{"directory beyond the end", append(le(signature, 0, 1, 1<<20), make([]byte, 16)...), "stream directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(bytes.NewReader(tt.b), int64(len(tt.b)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
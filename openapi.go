@@ -0,0 +1,515 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_report/auth"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Every route in NewRouter is described by an apiRoute, from which the OpenAPI document is built.
+// TestSpecCoverage fails if the router and documentedRoutes disagree.
+
+type apiAuth int
+
+const (
+	authNone   apiAuth = iota // public
+	authAny                   // app (mss certificate) or developer jwt
+	authDev                   // developer (github) jwt only
+	authSentry                // a sentry DSN's public key which is the dsn key of an mss certificate, or as authAny
+	authURLKey                // an mss certificate's upload token as the ?key= of the url, or as authAny
+)
+
+type apiParam struct {
+	Name, Description string
+	Array             bool // may be repeated
+}
+
+type apiRoute struct {
+	Method, Path, Summary string
+	Tag                   string
+	Auth                  apiAuth
+	Query                 []apiParam
+	Body                  string // request body schema, "" if none
+	BodyType              string // request body content type, json if ""
+	Status                int    // success status
+	Response              string // response schema, "" if no body
+	ContentType           string // response content type, json if ""
+	Deprecated            bool
+	Compressed            bool // body may be gzip/deflate encoded, and is size limited
+	Negotiated            bool // reports may also be served as csv or html, see format.go
+	Idempotent            bool // honors an Idempotency-Key header, see idempotency.go
+	RateLimited           bool // every versioned route, see ratelimit.go
+	Queued                bool // the request is completed in the background, see ingest.go
+	Probe                 bool // responds 503 with the same body when unhealthy, see health.go
+	ClientCert            bool // applications may authenticate with a registered client certificate instead, see tls.go
+}
+
+var pathParamDescriptions = map[string]string{
+	"mssCertificate":         "an mss application certificate",
+	string(ReportGIDVar):     "a report group id",
+	string(ReportKeyVar):     "a report key (md5 of the report)",
+	string(ReleaseVar):       "an application release (version)",
+	string(CommentIDVar):     "a comment id",
+	string(SentryProjectVar): "the project id of a sentry DSN",
+	string(MinidumpIDVar):    "a minidump id (sha256), the content.minidump.id of its report",
+	string(WebhookIDVar):     "a webhook id",
+	string(DeliveryIDVar):    "a webhook delivery id",
+}
+
+// serverRoutes are unversioned
+var serverRoutes = []apiRoute{
+	{Method: http.MethodGet, Path: "/ping/", Summary: "Responds pong while the server is up", Tag: "server", Status: http.StatusOK, Response: "pong", ContentType: "text/plain"},
+	{Method: http.MethodGet, Path: "/healthz/", Summary: "Responds while the process is alive (liveness)", Tag: "server", Status: http.StatusOK, Response: "object"},
+	{Method: http.MethodGet, Path: "/readyz/", Summary: "Checks the store, GitHub app installation & parameter store (readiness)", Tag: "server", Status: http.StatusOK, Response: "Readiness", Probe: true},
+	{Method: http.MethodGet, Path: "/openapi.json", Summary: "This OpenAPI document", Tag: "server", Status: http.StatusOK, Response: "object"},
+	{Method: http.MethodGet, Path: "/docs/", Summary: "Browsable documentation of this API", Tag: "server", Status: http.StatusOK, Response: "html", ContentType: "text/html"},
+	{Method: http.MethodGet, Path: "/dashboard/", Summary: "Developer dashboard for browsing groups & reports, and managing certificates", Tag: "server", Status: http.StatusOK, Response: "html", ContentType: "text/html"},
+	{Method: http.MethodPost, Path: "/api/{sentryProject}/store/", Summary: "Submit a sentry event, to be stored as a report in a group of its fingerprint", Tag: "sentry", Auth: authSentry, Body: "SentryEvent", Status: http.StatusOK, Response: "SentryReceipt", Compressed: true, Queued: true, RateLimited: true},
+	{Method: http.MethodPost, Path: "/api/{sentryProject}/envelope/", Summary: "Submit a sentry envelope, whose events are stored as reports (other items are ignored)", Tag: "sentry", Auth: authSentry, Body: "SentryEnvelope", BodyType: "application/x-sentry-envelope", Status: http.StatusOK, Response: "SentryReceipt", Compressed: true, Queued: true, RateLimited: true},
+}
+
+// v1Docs are the routes of v1Routes, relative to /v1
+var v1Docs = []apiRoute{
+	{Method: http.MethodPost, Path: "/token/", Summary: "Exchange an mss certificate, or github user & oauth token, for a jwt", Tag: "auth", Body: "TokenRequest", Status: http.StatusCreated, Response: "jwt", ContentType: "text/plain"},
+	{Method: http.MethodPut, Path: "/token/", Summary: "Same as POST /token/", Tag: "auth", Body: "TokenRequest", Status: http.StatusCreated, Response: "jwt", ContentType: "text/plain"},
+	{Method: http.MethodPost, Path: "/certificate/{mssCertificate}/", Summary: "Add an mss application certificate", Tag: "auth", Auth: authDev, Status: http.StatusCreated, Response: "CertificateResponse",
+		Query: []apiParam{{Name: "release", Description: "the application release the certificate is minted for"}}},
+	{Method: http.MethodDelete, Path: "/certificate/{mssCertificate}/", Summary: "Remove an mss application certificate", Tag: "auth", Auth: authDev, Status: http.StatusNoContent},
+	{Method: http.MethodPost, Path: "/minidump/", Summary: "Upload a minidump as Crashpad does, to be stored with a report of its metadata", Tag: "minidumps", Auth: authURLKey, Body: "MinidumpUpload", BodyType: "multipart/form-data", Status: http.StatusOK, Response: "the report key", ContentType: "text/plain", Queued: true},
+	{Method: http.MethodGet, Path: "/minidump/{minidumpID}/", Summary: "Download a stored minidump", Tag: "minidumps", Auth: authDev, Status: http.StatusOK, Response: "minidump", ContentType: "application/octet-stream"},
+
+	{Method: http.MethodPost, Path: "/report/", Summary: "Submit a report, to be stored in the background", Tag: "reports", Auth: authAny, Body: "Report", Status: http.StatusAccepted, Response: "Receipt", Compressed: true, Idempotent: true, Queued: true, ClientCert: true},
+	{Method: http.MethodGet, Path: "/report/", Summary: "List all reports", Tag: "reports", Auth: authDev, Status: http.StatusOK, Response: "[]Report", Negotiated: true,
+		Query: []apiParam{
+			{Name: "status", Description: "only reports whose group has this status (open, resolved, ignored, regressed)"},
+			{Name: "tag", Description: "only reports tagged name=value", Array: true},
+		}},
+	{Method: http.MethodGet, Path: "/report/stream", Summary: "Server-sent events of each report as it is stored", Tag: "reports", Auth: authDev, Status: http.StatusOK, Response: "events", ContentType: "text/event-stream",
+		Query: []apiParam{
+			{Name: "gid", Description: "only reports whose group id starts with this prefix"},
+			{Name: "severity", Description: "only reports of this severity (bug, crash)"},
+			{Name: "lastEventID", Description: "resume after this event id (alternative to the Last-Event-ID header)"},
+		}},
+	{Method: http.MethodGet, Path: "/report/group/{reportsGID}/", Summary: "List a group's reports (its comments are listed at /comments/)", Tag: "reports", Auth: authDev, Status: http.StatusOK, Response: "[]Report", Negotiated: true},
+	{Method: http.MethodGet, Path: "/report/group/{reportsGID}/key/{reportsKey}/", Summary: "Get one report", Tag: "reports", Auth: authDev, Status: http.StatusOK, Response: "Report"},
+	{Method: http.MethodDelete, Path: "/report/group/{reportsGID}/key/{reportsKey}/", Summary: "Delete one report", Tag: "reports", Auth: authDev, Status: http.StatusNoContent},
+
+	{Method: http.MethodGet, Path: "/report/group/{reportsGID}/status", Summary: "Get a group's status and summary", Tag: "groups", Auth: authDev, Status: http.StatusOK, Response: "Group"},
+	{Method: http.MethodPost, Path: "/report/group/{reportsGID}/resolve", Summary: "Mark a group resolved", Tag: "groups", Auth: authDev, Status: http.StatusOK, Response: "Group"},
+	{Method: http.MethodPost, Path: "/report/group/{reportsGID}/ignore", Summary: "Mark a group ignored", Tag: "groups", Auth: authDev, Status: http.StatusOK, Response: "Group"},
+	{Method: http.MethodPost, Path: "/report/group/{reportsGID}/reopen", Summary: "Mark a group open", Tag: "groups", Auth: authDev, Status: http.StatusOK, Response: "Group"},
+	{Method: http.MethodGet, Path: "/report/group/{reportsGID}/facets", Summary: "The most frequent values of each tag in a group", Tag: "groups", Auth: authDev, Status: http.StatusOK, Response: "Facets",
+		Query: []apiParam{{Name: "top", Description: "values per tag (default 10)"}}},
+	{Method: http.MethodGet, Path: "/report/group/{reportsGID}/comments/", Summary: "List a group's comments", Tag: "groups", Auth: authDev, Status: http.StatusOK, Response: "[]Comment"},
+	{Method: http.MethodPost, Path: "/report/group/{reportsGID}/comments/", Summary: "Comment on a group", Tag: "groups", Auth: authDev, Body: "CommentRequest", Status: http.StatusCreated, Response: "Comment"},
+	{Method: http.MethodPut, Path: "/report/group/{reportsGID}/comments/{commentID}/", Summary: "Edit your comment", Tag: "groups", Auth: authDev, Body: "CommentRequest", Status: http.StatusOK, Response: "Comment"},
+	{Method: http.MethodGet, Path: "/group/", Summary: "List the status and summary of every group", Tag: "groups", Auth: authDev, Status: http.StatusOK, Response: "[]Group",
+		Query: []apiParam{
+			{Name: "status", Description: "only groups with this status"},
+			{Name: "sort", Description: "lastSeen (default) or count"},
+		}},
+
+	{Method: http.MethodGet, Path: "/release/", Summary: "List every release which has sent reports, newest first", Tag: "releases", Auth: authDev, Status: http.StatusOK, Response: "[]Release"},
+	{Method: http.MethodGet, Path: "/release/{release}/", Summary: "A release's summary, with the groups it introduced or regressed", Tag: "releases", Auth: authDev, Status: http.StatusOK, Response: "ReleaseDetail"},
+	{Method: http.MethodGet, Path: "/stats/", Summary: "Report counts by time bucket, and the fastest growing groups", Tag: "stats", Auth: authDev, Status: http.StatusOK, Response: "Stats",
+		Query: []apiParam{
+			{Name: "resolution", Description: "hour (default) or day"},
+			{Name: "from", Description: "RFC3339 start of the window"},
+			{Name: "to", Description: "RFC3339 end of the window (default now)"},
+			{Name: "top", Description: "groups by growth (default 10)"},
+		}},
+	{Method: http.MethodGet, Path: "/ratelimit/", Summary: "Rate limiter counters, and the most limited clients", Tag: "stats", Auth: authDev, Status: http.StatusOK, Response: "[]RateLimitStats",
+		Query: []apiParam{{Name: "top", Description: "clients by times limited (default 10)"}}},
+
+	{Method: http.MethodGet, Path: "/webhook/", Summary: "List every webhook, without their secrets", Tag: "webhooks", Auth: authDev, Status: http.StatusOK, Response: "[]Webhook"},
+	{Method: http.MethodPost, Path: "/webhook/", Summary: "Register a webhook, responding with its secret (only shown once)", Tag: "webhooks", Auth: authDev, Body: "WebhookRequest", Status: http.StatusCreated, Response: "Webhook"},
+	{Method: http.MethodGet, Path: "/webhook/{webhookID}/", Summary: "Get a webhook, without its secret", Tag: "webhooks", Auth: authDev, Status: http.StatusOK, Response: "Webhook"},
+	{Method: http.MethodDelete, Path: "/webhook/{webhookID}/", Summary: "Remove a webhook", Tag: "webhooks", Auth: authDev, Status: http.StatusNoContent},
+	{Method: http.MethodGet, Path: "/webhook/{webhookID}/deliveries", Summary: "The webhook's delivery log, newest first", Tag: "webhooks", Auth: authDev, Status: http.StatusOK, Response: "[]Delivery"},
+	{Method: http.MethodPost, Path: "/webhook/{webhookID}/deliveries/{deliveryID}/redeliver", Summary: "Send a delivery's payload again, as a new delivery", Tag: "webhooks", Auth: authDev, Status: http.StatusAccepted, Response: "Delivery"},
+}
+
+type jsonObj = map[string]interface{}
+
+func ref(schema string) jsonObj {
+	if strings.HasPrefix(schema, "[]") {
+		return jsonObj{"type": "array", "items": ref(schema[2:])}
+	}
+	return jsonObj{"$ref": "#/components/schemas/" + schema}
+}
+
+func prop(typ, description string) jsonObj {
+	p := jsonObj{"type": typ}
+	if description != "" {
+		p["description"] = description
+	}
+	return p
+}
+
+func timeProp(description string) jsonObj {
+	p := prop("string", description)
+	p["format"] = "date-time"
+	return p
+}
+
+func object(props jsonObj) jsonObj {
+	return jsonObj{"type": "object", "properties": props}
+}
+
+func stringMap(description string, values jsonObj) jsonObj {
+	return jsonObj{"type": "object", "description": description, "additionalProperties": values}
+}
+
+var apiSchemas = jsonObj{
+	"Report": object(jsonObj{
+		"gid":        prop("string", "human readable group id, i.e. the catch block which sent the report"),
+		"severity":   jsonObj{"type": "integer", "enum": []int{0, 1, 2}, "description": "0 unknown, 1 bug, 2 crash"},
+		"content":    jsonObj{"type": "object", "description": "the report", "additionalProperties": true},
+		"key":        prop("string", "md5 of the report, set by the server"),
+		"receivedOn": timeProp("set by the server"),
+		"release":    prop("string", "application version, overridden by the certificate's release"),
+		"tags":       stringMap("up to 8 name: value pairs", prop("string", "")),
+	}),
+	"Receipt": object(jsonObj{
+		"gid": prop("string", ""),
+		"key": prop("string", ""),
+	}),
+	"SentryEvent": object(jsonObj{
+		"event_id":    prop("string", "returned as the receipt's id"),
+		"level":       prop("string", "fatal events (and unhandled exceptions) are crashes, others are bugs"),
+		"fingerprint": jsonObj{"type": "array", "items": prop("string", ""), "description": "groups the event; {{ default }} is its exception type & stack, or message"},
+		"release":     prop("string", ""),
+		"environment": prop("string", "tagged, with level & the first tags by name"),
+		"tags":        stringMap("", prop("string", "")),
+		"exception":   jsonObj{"type": "object", "additionalProperties": true},
+		"message":     prop("string", ""),
+	}),
+	"SentryEnvelope": prop("string", "a sentry envelope: a json header line, then each item's json header line & payload"),
+	"SentryReceipt": object(jsonObj{
+		"id": prop("string", "the event id"),
+	}),
+	"MinidumpUpload": object(jsonObj{
+		"upload_file_minidump": jsonObj{"type": "string", "format": "binary", "description": "the dump; the form may be gzip encoded"},
+		"prod":                 prop("string", "product, tagged"),
+		"ver":                  prop("string", "release, overridden by the certificate's release"),
+	}),
+	"CertificateResponse": object(jsonObj{
+		"uploadToken": prop("string", "the ?key= of uploads made with the certificate (see /minidump/)"),
+		"dsnKey":      prop("string", "the public key of sentry DSNs of the certificate (see /api/{sentryProject}/store/)"),
+	}),
+	"TokenRequest": object(jsonObj{
+		"ghUser":  prop("string", "github username (developers)"),
+		"ghToken": prop("string", "github oauth token (developers)"),
+		"mssCert": prop("string", "mss application certificate (applications)"),
+	}),
+	"Error": object(jsonObj{
+		"code":        prop("integer", "http status code"),
+		"status":      prop("string", "http status text"),
+		"userMessage": prop("string", ""),
+	}),
+	"Group": object(jsonObj{
+		"gid":            prop("string", ""),
+		"status":         jsonObj{"type": "string", "enum": []string{"open", "resolved", "ignored", "regressed"}},
+		"statusBy":       prop("string", "github user who last changed the status"),
+		"statusOn":       timeProp(""),
+		"firstSeen":      timeProp(""),
+		"lastSeen":       timeProp(""),
+		"count":          prop("integer", ""),
+		"severityCounts": stringMap("severity: count", prop("integer", "")),
+		"latestKey":      prop("string", ""),
+		"firstRelease":   prop("string", ""),
+		"lastRelease":    prop("string", ""),
+		"resolvedIn":     prop("string", "last release reported before the group was resolved"),
+		"regressedIn":    prop("string", "release which reported the group after it was resolved"),
+		"issueNumber":    prop("integer", "github issue most recently opened for the group"),
+	}),
+	"Facets": stringMap("tag: most frequent values", ref("[]Facet")),
+	"Facet": object(jsonObj{
+		"tag":   prop("string", ""),
+		"value": prop("string", ""),
+		"count": prop("integer", ""),
+	}),
+	"Comment": object(jsonObj{
+		"id":             prop("string", ""),
+		"gid":            prop("string", ""),
+		"author":         prop("string", "github user"),
+		"body":           prop("string", "markdown"),
+		"createdOn":      timeProp(""),
+		"editedOn":       timeProp(""),
+		"issueCommentID": prop("integer", "id of the mirrored github issue comment"),
+	}),
+	"CommentRequest": object(jsonObj{
+		"body":   prop("string", "markdown"),
+		"mirror": prop("boolean", "also post the comment to the group's github issue"),
+	}),
+	"Release": object(jsonObj{
+		"release":   prop("string", ""),
+		"count":     prop("integer", ""),
+		"firstSeen": timeProp(""),
+		"lastSeen":  timeProp(""),
+	}),
+	"ReleaseDetail": jsonObj{"allOf": []jsonObj{ref("Release"), object(jsonObj{
+		"newGroups":       ref("[]Group"),
+		"regressedGroups": ref("[]Group"),
+	})}},
+	"RateLimitStats": object(jsonObj{
+		"name":    jsonObj{"type": "string", "enum": []string{"apps", "developers", "token"}},
+		"default": object(jsonObj{"rate": prop("number", "requests per second"), "burst": prop("integer", "")}),
+		"allowed": prop("integer", "requests allowed since the server started"),
+		"limited": prop("integer", "requests limited since the server started"),
+		"tracked": prop("integer", "clients currently being limited, or recently active"),
+		"top": jsonObj{"type": "array", "items": object(jsonObj{
+			"key":     prop("string", "client: mss:<certificate md5>, gh:<user>, or ip address"),
+			"limited": prop("integer", ""),
+		})},
+	}),
+	"Webhook": object(jsonObj{
+		"id":          prop("string", ""),
+		"url":         prop("string", ""),
+		"events":      jsonObj{"type": "array", "items": jsonObj{"type": "string", "enum": []string{"report.new", "group.new", "severity.threshold", "group.resolved"}}},
+		"minSeverity": jsonObj{"type": "integer", "enum": []int{1, 2}, "description": "of severity.threshold events: 1 bug, 2 crash"},
+		"secret":      prop("string", "hmac-sha256 key of the X-GoReport-Signature-256 header, only shown when registered"),
+		"createdBy":   prop("string", "github user"),
+		"createdOn":   timeProp(""),
+	}),
+	"WebhookRequest": object(jsonObj{
+		"url":         prop("string", "http(s) url each event is POSTed to"),
+		"events":      jsonObj{"type": "array", "items": jsonObj{"type": "string", "enum": []string{"report.new", "group.new", "severity.threshold", "group.resolved"}}},
+		"minSeverity": prop("string", "of severity.threshold events: bug or crash (default)"),
+		"secret":      prop("string", "generated if empty"),
+	}),
+	"WebhookPayload": object(jsonObj{
+		"event":      prop("string", "also the X-GoReport-Event header"),
+		"occurredOn": timeProp(""),
+		"group":      ref("Group"),
+		"report": object(jsonObj{
+			"gid":        prop("string", ""),
+			"key":        prop("string", "its content is at GET /v1/report/group/{gid}/key/{key}/"),
+			"severity":   prop("string", "bug, crash or unknown"),
+			"receivedOn": timeProp(""),
+			"release":    prop("string", ""),
+			"tags":       stringMap("", prop("string", "")),
+		}),
+		"threshold": prop("string", "the webhook's minSeverity, of severity.threshold events"),
+	}),
+	"Delivery": object(jsonObj{
+		"id":           prop("string", "the X-GoReport-Delivery header"),
+		"webhookId":    prop("string", ""),
+		"event":        prop("string", ""),
+		"payload":      ref("WebhookPayload"),
+		"status":       jsonObj{"type": "string", "enum": []string{"pending", "delivered", "failed"}},
+		"attempts":     prop("integer", ""),
+		"statusCode":   prop("integer", "of the last attempt's response"),
+		"error":        prop("string", "why the last attempt failed"),
+		"createdOn":    timeProp(""),
+		"lastAttempt":  timeProp(""),
+		"nextAttempt":  timeProp("of a pending delivery"),
+		"redeliveryOf": prop("string", "id of the delivery this one repeats"),
+	}),
+	"Readiness": object(jsonObj{
+		"ready": prop("boolean", "every check passed"),
+		"checks": stringMap("check name: result", object(jsonObj{
+			"ok":        prop("boolean", ""),
+			"error":     prop("string", "why the check failed"),
+			"latencyMs": prop("integer", ""),
+			"checkedAt": timeProp("results are cached briefly"),
+		})),
+	}),
+	"Stats": object(jsonObj{
+		"resolution": jsonObj{"type": "string", "enum": []string{"hour", "day"}},
+		"from":       timeProp(""),
+		"to":         timeProp(""),
+		"buckets": jsonObj{"type": "array", "items": object(jsonObj{
+			"start":      timeProp(""),
+			"total":      prop("integer", ""),
+			"bySeverity": stringMap("severity: count", prop("integer", "")),
+			"byGroup":    stringMap("gid: count", prop("integer", "")),
+		})},
+		"top": jsonObj{"type": "array", "items": object(jsonObj{
+			"gid":      prop("string", ""),
+			"count":    prop("integer", "reports in the window"),
+			"previous": prop("integer", "reports in the window before"),
+			"growth":   prop("integer", ""),
+		})},
+	}),
+}
+
+// documentedRoutes are the server routes, every version's routes under its prefix, and the deprecated unversioned
+// aliases of legacyVersion
+func documentedRoutes() []apiRoute {
+	routes := append([]apiRoute{}, serverRoutes...)
+	for _, v := range apiVersions {
+		for _, rt := range v.Docs {
+			rt.Path = v.Prefix + rt.Path
+			rt.RateLimited = true
+			routes = append(routes, rt)
+		}
+	}
+	for _, rt := range legacyVersion.Docs {
+		rt.Summary = fmt.Sprintf("Deprecated alias of %v%v", legacyVersion.Prefix, rt.Path)
+		rt.Deprecated = true
+		rt.RateLimited = true
+		routes = append(routes, rt)
+	}
+	return routes
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// OpenAPISpec builds the OpenAPI 3 document of the routes
+func OpenAPISpec(routes []apiRoute) jsonObj {
+	paths := jsonObj{}
+	for _, rt := range routes {
+		op := jsonObj{
+			"summary":     rt.Summary,
+			"operationId": operationID(rt),
+			"tags":        []string{rt.Tag},
+			"responses":   responses(rt),
+		}
+		params := make([]jsonObj, 0, 4)
+		for _, m := range pathParamPattern.FindAllStringSubmatch(rt.Path, -1) {
+			params = append(params, jsonObj{"name": m[1], "in": "path", "required": true, "description": pathParamDescriptions[m[1]], "schema": prop("string", "")})
+		}
+		for _, q := range rt.Query {
+			schema := prop("string", "")
+			if q.Array {
+				schema = jsonObj{"type": "array", "items": prop("string", "")}
+			}
+			params = append(params, jsonObj{"name": q.Name, "in": "query", "description": q.Description, "schema": schema})
+		}
+		if rt.Compressed {
+			params = append(params, jsonObj{"name": "Content-Encoding", "in": "header", "description": "gzip or deflate, if the body is compressed", "schema": prop("string", "")})
+		}
+		if rt.Idempotent {
+			params = append(params, jsonObj{"name": IdempotencyKeyHeader, "in": "header", "description": "retries sent with the same key are answered with the original response", "schema": prop("string", "")})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if rt.Body != "" {
+			bodyType := rt.BodyType
+			if bodyType == "" {
+				bodyType = "application/json"
+			}
+			op["requestBody"] = jsonObj{"required": true, "content": jsonObj{bodyType: jsonObj{"schema": ref(rt.Body)}}}
+		}
+		switch rt.Auth {
+		case authAny:
+			op["security"] = []jsonObj{{"jwt": []string{}}}
+			op["description"] = "Requires an application or developer jwt (see /token/)."
+		case authDev:
+			op["security"] = []jsonObj{{"jwt": []string{}}}
+			op["description"] = "Requires a developer jwt (see /token/)."
+		case authSentry:
+			op["security"] = []jsonObj{{"sentryKey": []string{}}, {"jwt": []string{}}}
+			op["description"] = "For sentry SDKs: the DSN's public key (X-Sentry-Auth: Sentry sentry_key=..., or ?sentry_key=...) must be the dsn key of a registered mss certificate (the dsnKey of POST /certificate/). An application or developer jwt is also accepted."
+		case authURLKey:
+			op["security"] = []jsonObj{{"urlKey": []string{}}, {"jwt": []string{}}}
+			op["description"] = "For clients configured with only an url (i.e. Crashpad): its ?key= must be the upload token of a registered mss certificate (the uploadToken of POST /certificate/). An application or developer jwt is also accepted."
+		}
+		if rt.ClientCert {
+			op["description"] = op["description"].(string) + " Over mutual tls, an application may instead present a client certificate whose sha256 fingerprint is registered (see /certificate/)."
+		}
+		if rt.Deprecated {
+			op["deprecated"] = true
+		}
+		if _, ok := paths[rt.Path]; !ok {
+			paths[rt.Path] = jsonObj{}
+		}
+		paths[rt.Path].(jsonObj)[strings.ToLower(rt.Method)] = op
+	}
+	return jsonObj{
+		"openapi": "3.0.2",
+		"info": jsonObj{
+			"title":       "go_report",
+			"description": "Automated crash & bug reporting. Reports are grouped by a human readable group id (gid), and keyed by the md5 of their content.",
+			"version":     "1",
+		},
+		"paths": paths,
+		"components": jsonObj{
+			"schemas": apiSchemas,
+			"securitySchemes": jsonObj{
+				"jwt":       jsonObj{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"sentryKey": jsonObj{"type": "apiKey", "in": "header", "name": "X-Sentry-Auth"},
+				"urlKey":    jsonObj{"type": "apiKey", "in": "query", "name": auth.URLKeyParam},
+			},
+		},
+	}
+}
+
+func responses(rt apiRoute) jsonObj {
+	ok := jsonObj{"description": http.StatusText(rt.Status)}
+	switch {
+	case rt.ContentType != "":
+		ok["content"] = jsonObj{rt.ContentType: jsonObj{"schema": prop("string", "")}}
+	case rt.Response == "object":
+		ok["content"] = jsonObj{"application/json": jsonObj{"schema": prop("object", "")}}
+	case rt.Response != "":
+		ok["content"] = jsonObj{"application/json": jsonObj{"schema": ref(rt.Response)}}
+	}
+	if rt.Negotiated {
+		// csv & html list only the reports
+		content := ok["content"].(jsonObj)
+		content[string(formatCSV)] = jsonObj{"schema": prop("string", "one row per report; tags as tag.name columns, content flattened to content.a.b columns")}
+		content[string(formatHTML)] = jsonObj{"schema": prop("string", "a table of the reports")}
+	}
+	errResp := func(desc string) jsonObj {
+		return jsonObj{"description": desc, "content": jsonObj{"application/json": jsonObj{"schema": ref("Error")}}}
+	}
+	rs := jsonObj{fmt.Sprint(rt.Status): ok}
+	if rt.Body != "" || len(rt.Query) > 0 {
+		rs["400"] = errResp("invalid request")
+	}
+	if rt.Idempotent {
+		rs["200"] = jsonObj{"description": "a retry (Idempotent-Replayed: true), with the original response", "content": ok["content"]}
+		rs["409"] = errResp("the original request with this Idempotency-Key is still in progress")
+		rs["422"] = errResp("the Idempotency-Key was used for a different request")
+	}
+	if rt.Negotiated {
+		rs["406"] = errResp("none of json, csv, html is acceptable (by extension or Accept)")
+	}
+	if rt.Compressed {
+		rs["413"] = errResp("body too large, as sent or once decompressed")
+		rs["415"] = errResp("unsupported Content-Encoding")
+	}
+	if rt.Auth != authNone {
+		rs["401"] = jsonObj{"description": "missing, invalid, or insufficient jwt"}
+	}
+	if rt.Probe {
+		rs["503"] = jsonObj{"description": "a check is failing", "content": ok["content"]}
+	}
+	if rt.Queued {
+		rs["503"] = errResp("too many reports are waiting to be stored, see Retry-After")
+	}
+	if rt.RateLimited {
+		rs["429"] = errResp("rate limited (by jwt identity, or for /token/ by client ip or the client exchanged), see Retry-After")
+	}
+	rs["default"] = errResp("error")
+	return rs
+}
+
+func operationID(rt apiRoute) string {
+	id := strings.ToLower(rt.Method)
+	for _, part := range strings.FieldsFunc(rt.Path, func(r rune) bool { return r == '/' || r == '.' }) {
+		part = strings.Trim(part, "{}")
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// OpenAPIHandler serves the OpenAPI document (the router sees /openapi.json as /openapi, see URLFormat)
+func OpenAPIHandler(routes []apiRoute) http.HandlerFunc {
+	b, err := json.MarshalIndent(OpenAPISpec(routes), "", "  ")
+	if err != nil {
+		panic(errors.Wrap(err, "could not encode OpenAPI document"))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	})
+}
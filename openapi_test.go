@@ -0,0 +1,179 @@
+package main
+
+import (
+	"go_report/auth"
+	"go_report/domain"
+	"go_report/ratelimit"
+	"go_report/webhook"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+)
+
+func TestSpecCoverage(t *testing.T) {
+	r := NewRouter(Services{
+		Auth:    auth.New(nil, auth.Secrets{JWTKey: "openapi-test-key"}, nil, nil),
+		Metrics: NewMetrics(),
+	}, time.Now().AddDate(1, 0, 0))
+	if err := checkSpecCoverage(r, documentedRoutes()); err != nil {
+		t.Error(err) // every route must be documented in openapi.go
+	}
+}
+
+// TestSchemaFields checks that the properties of each schema are the json fields of the type it documents
+func TestSchemaFields(t *testing.T) {
+	tests := []struct {
+		schema string
+		value  interface{}
+	}{
+		{"Report", domain.Report{}},
+		{"Receipt", domain.Receipt{}},
+		{"Group", domain.Group{}},
+		{"Facet", domain.Facet{}},
+		{"Comment", domain.Comment{}},
+		{"CommentRequest", CommentRequest{}},
+		{"Release", domain.Release{}},
+		{"Webhook", domain.Webhook{}},
+		{"WebhookRequest", WebhookRequest{}},
+		{"WebhookPayload", webhook.Payload{}},
+		{"Delivery", domain.Delivery{}},
+		{"TokenRequest", auth.TokenRequest{}},
+		{"CertificateResponse", auth.CertificateResponse{}},
+		{"Readiness", Readiness{}},
+		{"Stats", Stats{}},
+		{"RateLimitStats", ratelimit.Stats{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			schema, ok := apiSchemas[tt.schema].(jsonObj)
+			if !ok {
+				t.Fatalf("no schema %v", tt.schema)
+			}
+			for _, diff := range schemaDiff(tt.schema, schema, reflect.TypeOf(tt.value)) {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+// schemaDiff compares the properties of the schema with the json fields of typ, and those of their nested objects.
+// Referenced schemas are compared on their own.
+func schemaDiff(path string, schema jsonObj, typ reflect.Type) []string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array:
+		if items, ok := schema["items"].(jsonObj); ok {
+			return schemaDiff(path+"[]", items, typ.Elem())
+		}
+		return nil
+	case reflect.Map:
+		if values, ok := schema["additionalProperties"].(jsonObj); ok {
+			return schemaDiff(path+"{}", values, typ.Elem())
+		}
+		return nil
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	props, ok := schema["properties"].(jsonObj)
+	if !ok {
+		return nil
+	}
+	fields := jsonFields(typ)
+	var diffs []string
+	for name, f := range fields {
+		prop, ok := props[name].(jsonObj)
+		if !ok {
+			diffs = append(diffs, path+"."+name+" is not documented")
+			continue
+		}
+		diffs = append(diffs, schemaDiff(path+"."+name, prop, f.Type)...)
+	}
+	for name := range props {
+		if _, ok := fields[name]; !ok {
+			diffs = append(diffs, path+"."+name+" is documented, but not a field")
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// jsonFields are the fields of a struct by json name, including those of embedded structs
+func jsonFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for n, ef := range jsonFields(f.Type) {
+				fields[n] = ef
+			}
+			continue
+		}
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+// routePattern is the pattern a route's path is registered with in the router
+func routePattern(path string) string {
+	// URLFormat routes /x.json as /x
+	if i := strings.LastIndex(path, "."); i > strings.LastIndex(path, "/") {
+		if _, ok := formatExtensions[path[i+1:]]; ok {
+			path = path[:i]
+		}
+	}
+	return path
+}
+
+// checkSpecCoverage fails unless every route of the router is described by exactly one apiRoute, and vice versa
+func checkSpecCoverage(r chi.Routes, routes []apiRoute) error {
+	described := map[string]bool{}
+	for _, rt := range routes {
+		k := rt.Method + " " + routePattern(rt.Path)
+		if described[k] {
+			return errors.Errorf("OpenAPI spec describes %v more than once", k)
+		}
+		described[k] = false
+	}
+	missing := make([]string, 0)
+	err := chi.Walk(r, func(method, route string, h http.Handler, mws ...func(http.Handler) http.Handler) error {
+		for strings.Contains(route, "/*/") {
+			route = strings.Replace(route, "/*/", "/", -1)
+		}
+		k := method + " " + strings.TrimSuffix(route, "/*")
+		if _, ok := described[k]; !ok {
+			missing = append(missing, k)
+		}
+		described[k] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for k, routed := range described {
+		if !routed {
+			missing = append(missing, k+" (not routed)")
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return errors.Errorf("OpenAPI spec does not match the router: %v", strings.Join(missing, ", "))
+	}
+	return nil
+}
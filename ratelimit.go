@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go_report/auth"
+	"go_report/auth/msscerts"
+	"go_report/failure"
+	"go_report/ratelimit"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RateLimiters limit apps (by certificate) and developers (by github user) once authenticated, and token
+// exchanges by client (see TokenRateLimit). A nil limiter does not limit.
+type RateLimiters struct {
+	Apps, Devs, Token *ratelimit.Limiter
+	ProxyHeader       string // set by a trusted proxy in front of the api to the client's ip, "" if there is none
+}
+
+// ParseRateLimiters reads limits of the form "<count>/<duration>[,<burst>]" (see ratelimit.ParseLimit). Overrides of
+// the app limit are a json object of certificate md5 (hex) to limit.
+func ParseRateLimiters(apps, devs, token, certOverrides string) (RateLimiters, error) {
+	var rl RateLimiters
+	appLimit, err := ratelimit.ParseLimit(apps)
+	if err != nil {
+		return rl, errors.Wrap(err, "app rate limit")
+	}
+	devLimit, err := ratelimit.ParseLimit(devs)
+	if err != nil {
+		return rl, errors.Wrap(err, "developer rate limit")
+	}
+	tokenLimit, err := ratelimit.ParseLimit(token)
+	if err != nil {
+		return rl, errors.Wrap(err, "token rate limit")
+	}
+	overrides := map[string]string{}
+	if strings.TrimSpace(certOverrides) != "" {
+		if err := json.Unmarshal([]byte(certOverrides), &overrides); err != nil {
+			return rl, errors.Wrap(err, "certificate rate limits must be a json object of certificate md5 to limit")
+		}
+	}
+	certLimits := make(map[string]ratelimit.Limit, len(overrides))
+	for digest, s := range overrides {
+		if certLimits["mss:"+strings.ToLower(digest)], err = ratelimit.ParseLimit(s); err != nil {
+			return rl, errors.Wrapf(err, "rate limit of certificate %v", digest)
+		}
+	}
+	rl.Apps = ratelimit.New("apps", appLimit, certLimits) // keyed like auth.ClientFromContext
+	rl.Devs = ratelimit.New("developers", devLimit, nil)
+	rl.Token = ratelimit.New("token", tokenLimit, nil)
+	return rl, nil
+}
+
+// IdentityRateLimit limits authenticated requests by their jwt's identity (see auth.ClientFromContext)
+func IdentityRateLimit(rl RateLimiters) func(http.Handler) http.Handler {
+	return rateLimit(func(r *http.Request) (*ratelimit.Limiter, string) {
+		return rl.identity(r.Context())
+	})
+}
+
+// identity is the limiter & key of the authenticated client in ctx
+func (rl RateLimiters) identity(ctx context.Context) (*ratelimit.Limiter, string) {
+	client := auth.ClientFromContext(ctx)
+	if strings.HasPrefix(client, "gh:") {
+		return rl.Devs, client
+	}
+	return rl.Apps, client
+}
+
+// allow counts a request of the authenticated client in ctx against its limit, see ratelimit.Limiter.Allow
+func (rl RateLimiters) allow(ctx context.Context) (bool, time.Duration) {
+	l, key := rl.identity(ctx)
+	if l == nil {
+		return true, 0
+	}
+	return l.Allow(key)
+}
+
+// TokenRateLimit limits token exchanges by the client's ip, as set in the ProxyHeader by the proxy in front of the
+// api, or (without a proxy, or for requests without the header) by the certificate or github user exchanged. The
+// connection's address is not used, as behind a load balancer all clients share it.
+func TokenRateLimit(rl RateLimiters) func(http.Handler) http.Handler {
+	return rateLimit(func(r *http.Request) (*ratelimit.Limiter, string) {
+		if ip := forwardedIP(r.Header.Get(rl.ProxyHeader)); rl.ProxyHeader != "" && ip != "" {
+			return rl.Token, "ip:" + ip
+		}
+		return rl.Token, tokenClient(r)
+	})
+}
+
+// forwardedIP is the address a proxy appended last to a forwarding header (i.e. X-Forwarded-For), the only one it
+// vouches for: those before it are as sent by the client
+func forwardedIP(header string) string {
+	addrs := strings.Split(header, ",")
+	return strings.TrimSpace(addrs[len(addrs)-1])
+}
+
+// maxTokenRequest is more than a token request's certificate or github user & token
+const maxTokenRequest = 64 << 10
+
+// tokenClient keys a token request by the client it asks a jwt for, keyed like auth.ClientFromContext, leaving the
+// body to be read again by the handler
+func tokenClient(r *http.Request) string {
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, maxTokenRequest))
+	r.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(b), r.Body))
+	var tr auth.TokenRequest
+	if err != nil || json.Unmarshal(b, &tr) != nil {
+		return "invalid"
+	}
+	if tr.MSSCert != "" {
+		return "mss:" + msscerts.Digest(tr.MSSCert)
+	}
+	return "gh:" + tr.User
+}
+
+func rateLimit(keyOf func(r *http.Request) (*ratelimit.Limiter, string)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l, key := keyOf(r)
+			if l == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if ok, retryAfter := l.Allow(key); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				failure.Fail(w, r, failure.New(errors.Errorf("%v rate limited", key), http.StatusTooManyRequests, "Too many requests, retry later"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetRateLimitsHandler returns each limiter's counters, with the ?top=... (default 10) most limited clients
+func GetRateLimitsHandler(rl RateLimiters) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		top := 10
+		if t := r.URL.Query().Get("top"); t != "" {
+			n, err := strconv.Atoi(t)
+			if err != nil || n < 0 {
+				failure.Fail(w, r, failure.New(errors.Errorf("invalid top %v", t), http.StatusBadRequest, "top must be a positive integer"))
+				return
+			}
+			top = n
+		}
+		stats := make([]ratelimit.Stats, 0, 3)
+		for _, l := range []*ratelimit.Limiter{rl.Apps, rl.Devs, rl.Token} {
+			if l != nil {
+				stats = append(stats, l.Stats(top))
+			}
+		}
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			failure.Fail(w, r, err)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const sweepEvery = time.Minute // how often idle (full) buckets are forgotten
+
+// Limit is a token bucket: Burst requests may be made at once, refilled at Rate per second. A zero Limit is unlimited.
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// ParseLimit reads a limit of the form "<count>/<duration>[,<burst>]", i.e. "60/1m" or "60/1m,10" ("none" is unlimited).
+// The burst defaults to the count.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "none" {
+		return Limit{}, nil
+	}
+	parts := strings.SplitN(s, ",", 2)
+	rate := strings.SplitN(parts[0], "/", 2)
+	if len(rate) != 2 {
+		return Limit{}, errors.Errorf("invalid rate limit %q, expected <count>/<duration>[,<burst>]", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(rate[0]))
+	if err != nil || n < 1 {
+		return Limit{}, errors.Errorf("invalid rate limit count in %q", s)
+	}
+	per, err := time.ParseDuration(strings.TrimSpace(rate[1]))
+	if err != nil || per <= 0 {
+		return Limit{}, errors.Errorf("invalid rate limit duration in %q", s)
+	}
+	l := Limit{Rate: float64(n) / per.Seconds(), Burst: n}
+	if len(parts) == 2 {
+		if l.Burst, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil || l.Burst < 1 {
+			return Limit{}, errors.Errorf("invalid rate limit burst in %q", s)
+		}
+	}
+	return l, nil
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limited uint64
+}
+
+// Limiter rate limits requests by key (i.e. a client's identity or address), in this process
+type Limiter struct {
+	lock      sync.Mutex
+	name      string
+	def       Limit
+	overrides map[string]Limit
+	buckets   map[string]*bucket
+	allowed   uint64
+	limited   uint64
+	swept     time.Time
+	now       func() time.Time
+}
+
+// New limits every key to def, except those with overrides
+func New(name string, def Limit, overrides map[string]Limit) *Limiter {
+	if overrides == nil {
+		overrides = map[string]Limit{}
+	}
+	return &Limiter{
+		name:      name,
+		def:       def,
+		overrides: overrides,
+		buckets:   map[string]*bucket{},
+		now:       time.Now,
+	}
+}
+
+func (l *Limiter) limit(key string) Limit {
+	if o, ok := l.overrides[key]; ok {
+		return o
+	}
+	return l.def
+}
+
+// Allow takes a token from the key's bucket. If it is empty, ok is false and retryAfter is when a token is next available.
+func (l *Limiter) Allow(key string) (ok bool, retryAfter time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	if now.Sub(l.swept) > sweepEvery {
+		l.sweep(now)
+	}
+	lim := l.limit(key)
+	if lim.Unlimited() {
+		l.allowed++
+		return true, 0
+	}
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: float64(lim.Burst), updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(lim.Burst), b.tokens+now.Sub(b.updated).Seconds()*lim.Rate)
+	b.updated = now
+	if b.tokens < 1 {
+		b.limited++
+		l.limited++
+		return false, time.Duration((1 - b.tokens) / lim.Rate * float64(time.Second))
+	}
+	b.tokens--
+	l.allowed++
+	return true, 0
+}
+
+// sweep forgets buckets which have refilled, as they are the same as new ones
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		lim := l.limit(key)
+		if lim.Unlimited() || b.tokens+now.Sub(b.updated).Seconds()*lim.Rate >= float64(lim.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
+
+// Stats are a limiter's counters since the process started
+type Stats struct {
+	Name    string     `json:"name"`
+	Default Limit      `json:"default"`
+	Allowed uint64     `json:"allowed"`
+	Limited uint64     `json:"limited"`
+	Tracked int        `json:"tracked"`       // keys with a bucket which is not full
+	Top     []KeyCount `json:"top,omitempty"` // the tracked keys limited most often
+}
+
+type KeyCount struct {
+	Key     string `json:"key"`
+	Limited uint64 `json:"limited"`
+}
+
+// Stats returns the counters, with up to top of the most limited keys
+func (l *Limiter) Stats(top int) Stats {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	st := Stats{Name: l.name, Default: l.def, Allowed: l.allowed, Limited: l.limited, Tracked: len(l.buckets)}
+	for key, b := range l.buckets {
+		if b.limited > 0 {
+			st.Top = append(st.Top, KeyCount{Key: key, Limited: b.limited})
+		}
+	}
+	sort.Slice(st.Top, func(i, j int) bool { return st.Top[i].Limited > st.Top[j].Limited })
+	if len(st.Top) > top {
+		st.Top = st.Top[:top]
+	}
+	return st
+}
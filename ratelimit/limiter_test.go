@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{"", Limit{}, false},
+		{"none", Limit{}, false},
+		{"60/1m", Limit{Rate: 1, Burst: 60}, false},
+		{" 10/1s , 5 ", Limit{Rate: 10, Burst: 5}, false},
+		{"60", Limit{}, true},
+		{"0/1m", Limit{}, true},
+		{"x/1m", Limit{}, true},
+		{"60/0s", Limit{}, true},
+		{"60/soon", Limit{}, true},
+		{"60/1m,0", Limit{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q) error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// clock is a limiter's time, moved by hand
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func TestAllow(t *testing.T) {
+	type call struct {
+		after      time.Duration // since the previous call
+		key        string
+		ok         bool
+		retryAfter time.Duration
+	}
+	tests := []struct {
+		name      string
+		def       Limit
+		overrides map[string]Limit
+		calls     []call
+	}{
+		{"unlimited", Limit{}, nil, []call{
+			{0, "a", true, 0},
+			{0, "a", true, 0},
+			{0, "a", true, 0},
+		}},
+		{"burst then limited", Limit{Rate: 1, Burst: 2}, nil, []call{
+			{0, "a", true, 0},
+			{0, "a", true, 0},
+			{0, "a", false, time.Second},
+			{500 * time.Millisecond, "a", false, 500 * time.Millisecond},
+			{500 * time.Millisecond, "a", true, 0},
+		}},
+		{"keys have their own buckets", Limit{Rate: 1, Burst: 1}, nil, []call{
+			{0, "a", true, 0},
+			{0, "a", false, time.Second},
+			{0, "b", true, 0},
+		}},
+		{"refills up to the burst", Limit{Rate: 1, Burst: 2}, nil, []call{
+			{0, "a", true, 0},
+			{time.Hour, "a", true, 0},
+			{0, "a", true, 0},
+			{0, "a", false, time.Second},
+		}},
+		{"override", Limit{Rate: 1, Burst: 1}, map[string]Limit{"vip": {Rate: 1, Burst: 3}, "free": {}}, []call{
+			{0, "vip", true, 0},
+			{0, "vip", true, 0},
+			{0, "vip", true, 0},
+			{0, "vip", false, time.Second},
+			{0, "free", true, 0},
+			{0, "free", true, 0},
+			{0, "other", true, 0},
+			{0, "other", false, time.Second},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &clock{t: time.Unix(1e9, 0)}
+			l := New("test", tt.def, tt.overrides)
+			l.now = c.now
+			for i, call := range tt.calls {
+				c.t = c.t.Add(call.after)
+				ok, retryAfter := l.Allow(call.key)
+				if ok != call.ok || retryAfter != call.retryAfter {
+					t.Errorf("call %d (%s): got %v, %v, want %v, %v", i, call.key, ok, retryAfter, call.ok, call.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := &clock{t: time.Unix(1e9, 0)}
+	def := Limit{Rate: 1, Burst: 1}
+	l := New("test", def, nil)
+	l.now = c.now
+	for key, n := range map[string]int{"a": 4, "b": 3, "c": 2, "d": 1} {
+		for i := 0; i < n; i++ {
+			l.Allow(key)
+		}
+	}
+	want := Stats{Name: "test", Default: def, Allowed: 4, Limited: 6, Tracked: 4, Top: []KeyCount{{"a", 3}, {"b", 2}}}
+	if got := l.Stats(2); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// once refilled, buckets are swept on the next call
+	c.t = c.t.Add(2 * sweepEvery)
+	l.Allow("e")
+	if got := l.Stats(2); got.Tracked != 1 || len(got.Top) != 0 {
+		t.Errorf("after sweeping got %+v, want only e tracked", got)
+	}
+}
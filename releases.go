@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"go_report/domain"
+	"go_report/failure"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ReleaseDetail is a release's summary, with the groups it introduced or brought back
+type ReleaseDetail struct {
+	domain.Release
+	NewGroups       []domain.Group `json:"newGroups"`       // groups first reported by this release
+	RegressedGroups []domain.Group `json:"regressedGroups"` // resolved groups which this release reported again
+}
+
+// GetReleasesHandler lists the summary of every release, newest first
+func GetReleasesHandler(s domain.ReleaseStorer) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rels, err := s.SelectReleases(r.Context())
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		domain.SortReleases(rels)
+		if err := json.NewEncoder(w).Encode(rels); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode releases json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// releaseBrowser reads a release with the groups it resolved and regressed
+type releaseBrowser interface {
+	domain.ReleaseStorer
+	domain.GroupStorer
+}
+
+// GetReleaseHandler returns the detail of the release in context
+func GetReleaseHandler(s releaseBrowser) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.Context().Value(string(ReleaseVar)).(string)
+		rels, err := s.SelectReleases(r.Context())
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		detail := ReleaseDetail{NewGroups: []domain.Group{}, RegressedGroups: []domain.Group{}}
+		found := false
+		for _, rel := range rels {
+			if rel.Name == name {
+				detail.Release, found = rel, true
+				break
+			}
+		}
+		if !found {
+			failure.Fail(w, r, failure.New(errors.Errorf("no reports from release %v", name), http.StatusNotFound, "no reports have been received from this release"))
+			return
+		}
+		groups, err := s.SelectAllGroupInfo(r.Context())
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		for _, g := range groups {
+			if g.FirstRelease == name {
+				detail.NewGroups = append(detail.NewGroups, g)
+			}
+			if g.RegressedIn == name {
+				detail.RegressedGroups = append(detail.RegressedGroups, g)
+			}
+		}
+		if err := json.NewEncoder(w).Encode(detail); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode release json to http writer response stream"))
+			return
+		}
+	})
+}
@@ -4,81 +4,242 @@ import (
 	"go_report/auth"
 	"go_report/domain"
 	"go_report/gh"
-	"log"
+	"go_report/ingest"
+	"go_report/logging"
+	"go_report/stream"
+	"go_report/webhook"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	chiCors "github.com/go-chi/cors"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func NewRouter(issCreateThreshold int, s domain.Storer, a *auth.Service, ghs *gh.Service, logger *log.Logger) *chi.Mux {
+// Services are the dependencies of the handlers, shared by every version of the API
+type Services struct {
+	Store             domain.Storer
+	Auth              *auth.Service
+	GitHub            *gh.Service
+	Broker            *stream.Broker
+	Queue             *ingest.Queue // stores posted reports
+	Logger            *logging.Logger
+	Limits            BodyLimits    // of uploaded reports
+	IdempotencyWindow time.Duration // how long a report's Idempotency-Key is remembered, 0 to ignore the header
+	RateLimits        RateLimiters
+	HandlerTimeout    time.Duration // of every request but live tails, 0 for none
+	Health            *Health       // dependency checks for /readyz
+	Metrics           *Metrics
+	Tracer            trace.Tracer // nil to trace nothing
+	Minidumps         *Minidumps
+	Webhooks          *webhook.Dispatcher // sends the events of stored reports & resolved groups
+}
+
+// apiVersion is one version of the API, mounted under its prefix. Each version registers its own routes &
+// handlers over the shared Services, so a new version (i.e. /v2) may change request and response shapes
+// while clients of older versions keep working.
+type apiVersion struct {
+	Prefix string
+	Routes func(svc Services) func(r chi.Router)
+	Docs   []apiRoute // documentation of Routes, with paths relative to Prefix (see openapi.go)
+}
+
+var apiVersions = []apiVersion{
+	{Prefix: "/v1", Routes: v1Routes, Docs: v1Docs},
+}
+
+// legacyVersion is also mounted without a prefix, for clients which predate versioning
+var legacyVersion = apiVersions[0]
+
+// the unversioned routes were deprecated on this date
+var unversionedDeprecatedOn = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// NewRouter mounts every API version, and the deprecated unversioned aliases which answer 410 Gone from
+// unversionedSunset
+func NewRouter(svc Services, unversionedSunset time.Time) *chi.Mux {
 	r := chi.NewRouter()
 	// init cors middleware
 	cors := chiCors.New(chiCors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-ReportType", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-ReportType", "X-CSRF-Token", "Last-Event-ID", "Content-Encoding", "Idempotency-Key", "traceparent", "tracestate", "X-Sentry-Auth"},
+		ExposedHeaders:   []string{"Link", "Deprecation", "Sunset", "Idempotent-Replayed", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	})
 	// add middlewares
 	r.Use(cors.Handler)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.URLFormat)
-	r.Use(middleware.Logger)
+	r.Use(RequestLogger(svc.Logger))
+	r.Use(Trace(svc.Tracer))
+	r.Use(svc.Metrics.Middleware) // outside Recover, to count panics as 500s
+	r.Use(Recover)
+	r.Use(URLFormat)
+	r.Use(HandlerTimeout(svc.HandlerTimeout))
 
 	r.Route("/ping", func(r chi.Router) {
 		r.Get("/", PingHandler())
 	})
+	// probes for load balancers & on-call, see health.go
+	r.Route("/healthz", func(r chi.Router) {
+		r.Get("/", HealthzHandler())
+	})
+	r.Route("/readyz", func(r chi.Router) {
+		r.Get("/", ReadyzHandler(svc.Health))
+	})
 
-	// public route for getting jwt
-	r.Route("/token", func(r chi.Router) {
-		r.Put("/", a.TokenExchangeHandler())
-		r.Post("/", a.TokenExchangeHandler())
+	// public api documentation, see openapi.go
+	r.Get("/openapi", OpenAPIHandler(documentedRoutes())) // served as /openapi.json
+	r.Route("/docs", func(r chi.Router) {
+		r.Get("/", DocsHandler())
+	})
+	r.Route("/dashboard", func(r chi.Router) {
+		r.Get("/", DashboardHandler()) // signs in & calls the api from the browser
 	})
 
-	// private (dev only) routes for add/remove certificates
-	r.Group(func(r chi.Router) {
-		r.Use(a.Verifier)
-		r.Use(a.Authenticate)
-		r.Group(func(r chi.Router) {
-			r.Use(a.MSSCertificateCtx)
-			r.Use(a.OnlyDevsAuthenticate)
-			r.Route("/certificate/{"+string(auth.CertCtxVar)+"}", func(r chi.Router) {
-				r.Post("/", a.AddCertificateHandler())
-				r.Delete("/", a.RemoveCertificateHandler())
-			})
+	// sentry SDKs, whose DSN's public key is the DSN key of an mss certificate (see sentry.go)
+	r.Route("/api/{"+string(SentryProjectVar)+"}", func(r chi.Router) {
+		r.Use(svc.Auth.Verifier)
+		r.Use(svc.Auth.SentryKey)
+		r.Use(svc.Auth.Authenticate)
+		r.Use(IdentityRateLimit(svc.RateLimits))
+		r.Use(SentryProjectCtx)
+		r.Route("/store", func(r chi.Router) {
+			r.Post("/", SentryStoreHandler(svc.Limits, svc.Queue))
+		})
+		r.Route("/envelope", func(r chi.Router) {
+			r.Post("/", SentryEnvelopeHandler(svc.Limits, svc.Queue))
 		})
 	})
 
-	// Private routes for actual service -- requires JWT
+	for _, v := range apiVersions {
+		r.Route(v.Prefix, v.Routes(svc))
+	}
 	r.Group(func(r chi.Router) {
-		r.Use(a.Verifier)
-		r.Use(a.Authenticate)
-		r.Route("/report", func(r chi.Router) {
+		r.Use(Deprecated(unversionedDeprecatedOn, unversionedSunset, legacyVersion.Prefix))
+		legacyVersion.Routes(svc)(r)
+	})
+	return r
+}
+
+func v1Routes(svc Services) func(r chi.Router) {
+	a, s := svc.Auth, svc.Store
+	return func(r chi.Router) {
+		// public route for getting jwt
+		r.Route("/token", func(r chi.Router) {
+			r.Use(TokenRateLimit(svc.RateLimits))
+			r.Put("/", a.TokenExchangeHandler())
+			r.Post("/", a.TokenExchangeHandler())
+		})
+
+		// private (dev only) routes for add/remove certificates
+		r.Group(func(r chi.Router) {
+			r.Use(a.Verifier)
+			r.Use(a.Authenticate)
+			r.Use(IdentityRateLimit(svc.RateLimits))
 			r.Group(func(r chi.Router) {
-				// Application authorization scheme
-				r.Use(ReportCtx)
-				r.Post("/", PostHandler(issCreateThreshold, s, ghs, logger))
+				r.Use(a.MSSCertificateCtx)
+				r.Use(a.OnlyDevsAuthenticate)
+				r.Route("/certificate/{"+string(auth.CertCtxVar)+"}", func(r chi.Router) {
+					r.Post("/", a.AddCertificateHandler())
+					r.Delete("/", a.RemoveCertificateHandler())
+				})
 			})
-			r.Group(func(r chi.Router) {
+		})
+
+		// Crashpad & Breakpad uploads, authenticated by an url key as they send no headers (see minidump.go)
+		r.Route("/minidump", func(r chi.Router) {
+			r.Use(a.Verifier)
+			r.Use(a.URLKey)
+			r.Use(a.Authenticate)
+			r.Use(IdentityRateLimit(svc.RateLimits))
+			r.Post("/", MinidumpUploadHandler(svc.Minidumps, svc.Queue))
+			r.Route("/{"+string(MinidumpIDVar)+"}", func(r chi.Router) {
 				r.Use(a.OnlyDevsAuthenticate)
-				// Require GitHub Repository access scope (developers only)
-				r.Get("/", GetAllHandler(s))
-				r.Route("/group/{"+string(ReportGIDVar)+"}", func(r chi.Router) {
-					r.Use(ReportGroupCtx)
-					r.Get("/", GetGroupHandler(s))
+				r.Get("/", GetMinidumpHandler(svc.Minidumps))
+			})
+		})
+
+		// private (dev only) group & release summaries, statistics, and webhooks
+		r.Group(func(r chi.Router) {
+			r.Use(a.Verifier)
+			r.Use(a.Authenticate)
+			r.Use(IdentityRateLimit(svc.RateLimits))
+			r.Use(a.OnlyDevsAuthenticate)
+			r.Route("/group", func(r chi.Router) {
+				r.Get("/", GetGroupsHandler(s))
+			})
+			r.Route("/stats", func(r chi.Router) {
+				r.Get("/", GetStatsHandler(s))
+			})
+			r.Route("/ratelimit", func(r chi.Router) {
+				r.Get("/", GetRateLimitsHandler(svc.RateLimits))
+			})
+			r.Route("/release", func(r chi.Router) {
+				r.Get("/", GetReleasesHandler(s))
+				r.Route("/{"+string(ReleaseVar)+"}", func(r chi.Router) {
+					r.Use(ReleaseCtx)
+					r.Get("/", GetReleaseHandler(s))
 				})
-				r.Route("/group/{"+string(ReportGIDVar)+"}"+"/key/{"+string(ReportKeyVar)+"}", func(r chi.Router) { // "/group/{gid}/key/{key}/...
-					r.Use(ReportGroupCtx)
-					r.Use(ReportKeyCtx)
-					r.Get("/", GetReportHandler(s))
-					r.Delete("/", DeleteReportHandler(s))
+			})
+			r.Route("/webhook", func(r chi.Router) {
+				r.Get("/", GetWebhooksHandler(s))
+				r.Post("/", PostWebhookHandler(s, svc.Webhooks))
+				r.Route("/{"+string(WebhookIDVar)+"}", func(r chi.Router) {
+					r.Use(WebhookCtx)
+					r.Get("/", GetWebhookHandler(s))
+					r.Delete("/", DeleteWebhookHandler(s, svc.Webhooks))
+					r.Get("/deliveries", GetDeliveriesHandler(s))
+					r.Route("/deliveries/{"+string(DeliveryIDVar)+"}", func(r chi.Router) {
+						r.Use(DeliveryCtx)
+						r.Post("/redeliver", RedeliverHandler(s, svc.Webhooks))
+					})
 				})
 			})
 		})
-	})
-	return r
+
+		// Private routes for actual service -- requires JWT, or an app's client certificate (see tls.go)
+		r.Group(func(r chi.Router) {
+			r.Use(a.Verifier)
+			r.Use(a.ClientCertificate)
+			r.Use(a.Authenticate)
+			r.Use(IdentityRateLimit(svc.RateLimits))
+			r.Route("/report", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					// Application authorization scheme
+					r.Use(ReportCtx(svc.Limits))
+					r.Post("/", PostHandler(svc.IdempotencyWindow, s, svc.Queue))
+				})
+				r.Group(func(r chi.Router) {
+					r.Use(a.OnlyDevsAuthenticate)
+					// Require GitHub Repository access scope (developers only)
+					r.Get("/", GetAllHandler(s))
+					r.Get("/stream", StreamHandler(svc.Broker))
+					r.Route("/group/{"+string(ReportGIDVar)+"}", func(r chi.Router) {
+						r.Use(ReportGroupCtx)
+						r.Get("/", GetGroupHandler(s))
+						r.Get("/status", GetGroupStatusHandler(s))
+						r.Get("/facets", GetFacetsHandler(s))
+						r.Route("/comments", func(r chi.Router) {
+							r.Get("/", GetCommentsHandler(s))
+							r.Post("/", PostCommentHandler(s, svc.GitHub))
+							r.Route("/{"+string(CommentIDVar)+"}", func(r chi.Router) {
+								r.Use(CommentCtx)
+								r.Put("/", EditCommentHandler(s, svc.GitHub))
+							})
+						})
+						r.Post("/resolve", SetGroupStatusHandler(s, svc.Webhooks, domain.StatusResolved))
+						r.Post("/ignore", SetGroupStatusHandler(s, svc.Webhooks, domain.StatusIgnored))
+						r.Post("/reopen", SetGroupStatusHandler(s, svc.Webhooks, domain.StatusOpen))
+					})
+					r.Route("/group/{"+string(ReportGIDVar)+"}"+"/key/{"+string(ReportKeyVar)+"}", func(r chi.Router) { // "/group/{gid}/key/{key}/...
+						r.Use(ReportGroupCtx)
+						r.Use(ReportKeyCtx)
+						r.Get("/", GetReportHandler(s))
+						r.Delete("/", DeleteReportHandler(s))
+					})
+				})
+			})
+		})
+	}
 }
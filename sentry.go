@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"go_report/auth"
+	"go_report/failure"
+	"go_report/ingest"
+	"go_report/logging"
+	"go_report/sentry"
+	"go_report/tracing"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/pkg/errors"
+)
+
+// SentryProjectVar is the project id of a sentry DSN (https://<key>@<host>/<project>), which prefixes its groups
+const SentryProjectVar RequestContextKey = "sentryProject"
+
+var sentryProjectPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// SentryProjectCtx adds the project of the request's path to its context
+func SentryProjectCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		project := chi.URLParam(r, string(SentryProjectVar))
+		if !sentryProjectPattern.MatchString(project) {
+			failure.Fail(w, r, failure.New(errors.Errorf("invalid sentry project %q", project), http.StatusBadRequest, "project must be 1-64 letters, digits, - or _"))
+			return
+		}
+		ctx := context.WithValue(r.Context(), string(SentryProjectVar), project)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// SentryStoreHandler accepts an event from a sentry SDK (the store endpoint), queueing it for storage as a report,
+// and responds with its event id as sentry does
+func SentryStoreHandler(limits BodyLimits, q *ingest.Queue) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := limits.ReadRaw(r)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		if b, err = sentry.StoreBody(b, limits.MaxReport); err != nil {
+			failure.Fail(w, r, failure.New(err, http.StatusBadRequest, "Could not decode sentry event from request body"))
+			return
+		}
+		if err := limits.CheckJSON(b); err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		ev, err := sentry.DecodeEvent(b)
+		if err != nil {
+			failure.Fail(w, r, failure.New(err, http.StatusBadRequest, "Could not decode sentry event from request body"))
+			return
+		}
+		if err := enqueueSentryEvent(w, r, q, ev); err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		sentryResponse(w, r, ev.ID())
+	})
+}
+
+// SentryEnvelopeHandler accepts an envelope from a sentry SDK, queueing its events for storage as reports. Its other
+// items (sessions, transactions, attachments, ...) are ignored.
+func SentryEnvelopeHandler(limits BodyLimits, q *ingest.Queue) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context())
+		b, err := limits.ReadRaw(r)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		header, items, err := sentry.ParseEnvelope(b)
+		if err != nil {
+			failure.Fail(w, r, failure.New(err, http.StatusBadRequest, "Could not parse sentry envelope"))
+			return
+		}
+		id, _ := header["event_id"].(string)
+		for _, it := range items {
+			if it.Type != "event" {
+				logger.Debug("ignored sentry envelope item", "type", it.Type)
+				continue
+			}
+			if err := limits.CheckJSON(it.Payload); err != nil {
+				failure.Fail(w, r, err)
+				return
+			}
+			ev, err := sentry.DecodeEvent(it.Payload)
+			if err != nil {
+				failure.Fail(w, r, failure.New(err, http.StatusBadRequest, "Could not decode sentry event in envelope"))
+				return
+			}
+			if err := enqueueSentryEvent(w, r, q, ev); err != nil {
+				failure.Fail(w, r, err)
+				return
+			}
+			if id == "" {
+				id = ev.ID()
+			}
+		}
+		sentryResponse(w, r, id)
+	})
+}
+
+// enqueueSentryEvent queues the event's report, as PostHandler does
+func enqueueSentryEvent(w http.ResponseWriter, r *http.Request, q *ingest.Queue, ev sentry.Event) error {
+	rpt := ev.Report(r.Context().Value(string(SentryProjectVar)).(string))
+	if err := rpt.ValidateGID(); err != nil {
+		return failure.New(err, http.StatusBadRequest, err.Error())
+	}
+	rpt.ReceivedOn = time.Now()
+	if rel := auth.ReleaseFromContext(r.Context()); rel != "" {
+		rpt.Release = rel // the certificate's release is trusted over the payload's
+	}
+	if err := rpt.ValidateSize(); err != nil {
+		return failure.New(err, http.StatusRequestEntityTooLarge, err.Error())
+	}
+	key, err := rpt.ContentKey()
+	if err != nil {
+		return failure.New(errors.Wrap(err, "failed to key report"), http.StatusInternalServerError, "")
+	}
+	rpt.Key = key
+	if err := q.Enqueue(ingest.Job{Report: rpt, RequestID: middleware.GetReqID(r.Context()), TraceParent: tracing.TraceParentFromContext(r.Context())}); err != nil {
+		w.Header().Set("Retry-After", "30") // sentry SDKs back off for it
+		return failure.New(errors.Wrap(err, "failed to queue report"), http.StatusServiceUnavailable, "Too many reports are waiting to be stored, retry later")
+	}
+	logging.FromContext(r.Context()).Debug("queued sentry event", "eventId", ev.ID(), "gid", rpt.GID, "key", rpt.Key)
+	return nil
+}
+
+func sentryResponse(w http.ResponseWriter, r *http.Request, id string) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"id": id}); err != nil {
+		logging.FromContext(r.Context()).Warn("failed to encode sentry response", "err", err)
+	}
+}
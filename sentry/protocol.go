@@ -0,0 +1,119 @@
+package sentry
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// KeyParam is the query parameter of the DSN's public key, sent by browser SDKs
+const KeyParam = "sentry_key"
+
+// KeyFromRequest returns the public key of the client's DSN, sent in the X-Sentry-Auth (or Authorization) header as
+// "Sentry sentry_key=<key>, sentry_version=7, ...", or by browser SDKs as ?sentry_key=<key>. It is "" if not sent.
+func KeyFromRequest(r *http.Request) string {
+	for _, h := range []string{r.Header.Get("X-Sentry-Auth"), r.Header.Get("Authorization")} {
+		if !strings.HasPrefix(strings.ToLower(h), "sentry ") {
+			continue
+		}
+		for _, kv := range strings.Split(h[len("sentry "):], ",") {
+			if i := strings.Index(kv, "="); i > 0 && strings.TrimSpace(kv[:i]) == "sentry_key" {
+				return strings.TrimSpace(kv[i+1:])
+			}
+		}
+	}
+	return r.URL.Query().Get(KeyParam)
+}
+
+// Event is a sentry event payload, kept as sent so reports lose nothing of it
+type Event map[string]interface{}
+
+// StoreBody returns the event json of a store request's body: the body, or (from older SDKs) its base64 encoded,
+// zlib compressed json, decompressed to at most max bytes
+func StoreBody(b []byte, max int64) ([]byte, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || b[0] == '{' {
+		return b, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(string(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "event is neither json nor base64")
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decompress base64 event")
+	}
+	if b, err = ioutil.ReadAll(io.LimitReader(zr, max+1)); err != nil {
+		return nil, errors.Wrap(err, "could not decompress base64 event")
+	} else if int64(len(b)) > max {
+		return nil, errors.Errorf("event is larger than %d bytes", max)
+	}
+	return b, nil
+}
+
+// DecodeEvent reads an event's json
+func DecodeEvent(b []byte) (Event, error) {
+	var e Event
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, errors.Wrap(err, "could not decode event")
+	}
+	if e == nil {
+		return nil, errors.New("event is not a json object")
+	}
+	return e, nil
+}
+
+// Item is one item of an envelope, i.e. an event, attachment or session
+type Item struct {
+	Type    string
+	Payload []byte
+}
+
+// ParseEnvelope splits an envelope into its items: a json header line, then for each item a json header line and
+// its payload, of the header's length or up to the next newline
+func ParseEnvelope(b []byte) (header map[string]interface{}, items []Item, err error) {
+	line, rest := nextLine(b)
+	if err := json.Unmarshal(line, &header); err != nil {
+		return nil, nil, errors.Wrap(err, "could not decode envelope header")
+	}
+	for len(bytes.TrimSpace(rest)) > 0 {
+		line, rest = nextLine(rest)
+		var ih struct {
+			Type   string       `json:"type"`
+			Length *json.Number `json:"length"`
+		}
+		if err := json.Unmarshal(line, &ih); err != nil {
+			return nil, nil, errors.Wrapf(err, "could not decode header of envelope item %d", len(items))
+		}
+		var payload []byte
+		if ih.Length != nil {
+			n, err := strconv.Atoi(ih.Length.String())
+			if err != nil || n < 0 || n > len(rest) {
+				return nil, nil, errors.Errorf("envelope item %d has invalid length %v", len(items), ih.Length)
+			}
+			payload, rest = rest[:n], rest[n:]
+			if len(rest) > 0 && rest[0] == '\n' {
+				rest = rest[1:]
+			}
+		} else {
+			payload, rest = nextLine(rest)
+		}
+		items = append(items, Item{Type: ih.Type, Payload: payload})
+	}
+	return header, items, nil
+}
+
+func nextLine(b []byte) (line, rest []byte) {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i], b[i+1:]
+	}
+	return b, nil
+}
@@ -0,0 +1,129 @@
+package sentry
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeyFromRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string // header name
+		value  string
+		url    string
+		want   string
+	}{
+		{"x-sentry-auth", "X-Sentry-Auth", "Sentry sentry_version=7, sentry_key=abc, sentry_client=go", "/", "abc"},
+		{"authorization", "Authorization", "sentry sentry_key = abc", "/", "abc"},
+		{"bearer authorization", "Authorization", "Bearer sentry_key=abc", "/", ""},
+		{"query", "", "", "/?sentry_key=abc", "abc"},
+		{"header before query", "X-Sentry-Auth", "Sentry sentry_key=abc", "/?sentry_key=def", "abc"},
+		{"none", "", "", "/", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", tt.url, nil)
+			if tt.header != "" {
+				r.Header.Set(tt.header, tt.value)
+			}
+			if got := KeyFromRequest(r); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func deflated(t *testing.T, s string) string {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestStoreBody(t *testing.T) {
+	event := `{"event_id":"abc"}`
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr bool
+	}{
+		{"json", " " + event + "\n", event, false},
+		{"empty", "", "", false},
+		{"base64 zlib", deflated(t, event), event, false},
+		{"too large once decompressed", deflated(t, `{"a":"`+strings.Repeat("x", 100)+`"}`), "", true},
+		{"not base64", "not an event", "", true},
+		{"base64, not zlib", base64.StdEncoding.EncodeToString([]byte("plain")), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StoreBody([]byte(tt.body), 64)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeEvent(t *testing.T) {
+	tests := []struct {
+		body    string
+		wantErr bool
+	}{
+		{`{"event_id":"abc"}`, false},
+		{`null`, true},
+		{`[1]`, true},
+		{`{"event_id":`, true},
+	}
+	for _, tt := range tests {
+		if _, err := DecodeEvent([]byte(tt.body)); (err != nil) != tt.wantErr {
+			t.Errorf("DecodeEvent(%s) error %v, want error %v", tt.body, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseEnvelope(t *testing.T) {
+	tests := []struct {
+		name     string
+		envelope string
+		want     []Item
+		wantErr  bool
+	}{
+		{"header only", `{"event_id":"abc"}`, nil, false},
+		{"header and newline", "{\"event_id\":\"abc\"}\n", nil, false},
+		{"item up to newline", "{}\n{\"type\":\"event\"}\n{\"a\":1}\n", []Item{{"event", []byte(`{"a":1}`)}}, false},
+		{"last item without newline", "{}\n{\"type\":\"event\"}\n{\"a\":1}", []Item{{"event", []byte(`{"a":1}`)}}, false},
+		{"item of length", "{}\n{\"type\":\"attachment\",\"length\":3}\na\nb\n{\"type\":\"event\"}\n{}\n",
+			[]Item{{"attachment", []byte("a\nb")}, {"event", []byte("{}")}}, false},
+		{"empty item of length", "{}\n{\"type\":\"session\",\"length\":0}\n\n", []Item{{"session", []byte{}}}, false},
+		{"invalid header", "{\n", nil, true},
+		{"invalid item header", "{}\nevent\n{}\n", nil, true},
+		{"length past the end", "{}\n{\"type\":\"attachment\",\"length\":10}\nabc\n", nil, true},
+		{"negative length", "{}\n{\"type\":\"attachment\",\"length\":-1}\nabc\n", nil, true},
+		{"fractional length", "{}\n{\"type\":\"attachment\",\"length\":1.5}\nabc\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, items, err := ParseEnvelope([]byte(tt.envelope))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(items, tt.want) {
+				t.Errorf("got %q, want %q", items, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package sentry
+
+import (
+	"crypto/md5"
+	"fmt"
+	"go_report/domain"
+	"sort"
+	"strings"
+)
+
+const defaultFingerprint = "{{ default }}"
+
+// ID is the event's event_id, which the SDK expects back
+func (e Event) ID() string {
+	id, _ := e["event_id"].(string)
+	return id
+}
+
+// Report translates the event into a report of the project: grouped by the event's fingerprint (or by its
+// exception & stack, or message, as sentry groups by default), with the event as its content. Fatal and unhandled
+// events are crashes, others are bugs.
+func (e Event) Report(project string) domain.Report {
+	rpt := domain.Report{
+		GID:      GID(project, e.fingerprint()),
+		Severity: domain.BugType,
+		Content:  e,
+		Tags:     e.tags(),
+	}
+	if e.str("level") == "fatal" || e.unhandled() {
+		rpt.Severity = domain.CrashType
+	}
+	rpt.Release = e.str("release")
+	return rpt
+}
+
+// GID is the group of a project's events with the fingerprint
+func GID(project string, fingerprint []string) string {
+	return fmt.Sprintf("sentry-%v-%x", project, md5.Sum([]byte(strings.Join(fingerprint, "\n"))))
+}
+
+func (e Event) str(key string) string {
+	s, _ := e[key].(string)
+	return s
+}
+
+// fingerprint is the event's own fingerprint, with {{ default }} replaced by the default grouping
+func (e Event) fingerprint() []string {
+	var fp []string
+	if given, ok := e["fingerprint"].([]interface{}); ok {
+		for _, v := range given {
+			if s := fmt.Sprint(v); s == defaultFingerprint {
+				fp = append(fp, e.defaultFingerprint()...)
+			} else {
+				fp = append(fp, s)
+			}
+		}
+	}
+	if len(fp) == 0 {
+		return e.defaultFingerprint()
+	}
+	return fp
+}
+
+// defaultFingerprint is the type & stack frames of the last (outermost) exception, or its type & value if it has no
+// stack, or else the event's unformatted message
+func (e Event) defaultFingerprint() []string {
+	if exs := e.exceptions(); len(exs) > 0 {
+		ex := exs[len(exs)-1]
+		typ, _ := ex["type"].(string)
+		fp := append([]string{typ}, frames(ex)...)
+		if len(fp) == 1 {
+			val, _ := ex["value"].(string)
+			fp = append(fp, val)
+		}
+		return fp
+	}
+	if le, ok := e["logentry"].(map[string]interface{}); ok {
+		if msg, _ := le["message"].(string); msg != "" {
+			return []string{msg}
+		}
+	}
+	switch msg := e["message"].(type) {
+	case string:
+		return []string{msg}
+	case map[string]interface{}: // older SDKs send the logentry as message
+		if s, _ := msg["message"].(string); s != "" {
+			return []string{s}
+		}
+	}
+	return []string{e.str("platform"), e.str("logger"), e.str("transaction")}
+}
+
+// exceptions are the event's exceptions, innermost first, sent as {"values": [...]} or (by older SDKs) a list
+func (e Event) exceptions() []map[string]interface{} {
+	raw, ok := e["exception"].([]interface{})
+	if m, isMap := e["exception"].(map[string]interface{}); isMap {
+		raw, ok = m["values"].([]interface{})
+	}
+	if !ok {
+		return nil
+	}
+	var exs []map[string]interface{}
+	for _, v := range raw {
+		if ex, ok := v.(map[string]interface{}); ok {
+			exs = append(exs, ex)
+		}
+	}
+	return exs
+}
+
+// frames names the exception's stack frames, only those of the application if any are marked in_app
+func frames(ex map[string]interface{}) []string {
+	st, _ := ex["stacktrace"].(map[string]interface{})
+	raw, _ := st["frames"].([]interface{})
+	var all, inApp []string
+	for _, v := range raw {
+		f, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := fmt.Sprint(first(f, "module", "filename", "package"), ":", first(f, "function", "symbol"))
+		all = append(all, name)
+		if b, _ := f["in_app"].(bool); b {
+			inApp = append(inApp, name)
+		}
+	}
+	if len(inApp) > 0 {
+		return inApp
+	}
+	return all
+}
+
+func first(m map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s, _ := m[k].(string); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// unhandled is true if an exception's mechanism says it was not handled (i.e. it crashed the application)
+func (e Event) unhandled() bool {
+	for _, ex := range e.exceptions() {
+		if mech, ok := ex["mechanism"].(map[string]interface{}); ok {
+			if handled, ok := mech["handled"].(bool); ok && !handled {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tags are the event's environment & level, then its own tags by name, within the limits of report tags; tags
+// which do not fit are left in the content
+func (e Event) tags() map[string]string {
+	tags := map[string]string{}
+	add := func(t, v string) {
+		if len(tags) < domain.MaxTags && t != "" && v != "" && len(t) <= domain.MaxTagLength &&
+			len(v) <= domain.MaxValueLength && !strings.Contains(t, "=") {
+			tags[t] = v
+		}
+	}
+	add("environment", e.str("environment"))
+	add("level", e.str("level"))
+	own := map[string]string{}
+	switch ts := e["tags"].(type) {
+	case map[string]interface{}:
+		for t, v := range ts {
+			own[t] = fmt.Sprint(v)
+		}
+	case []interface{}: // [[tag, value], ...]
+		for _, pair := range ts {
+			if p, ok := pair.([]interface{}); ok && len(p) == 2 {
+				own[fmt.Sprint(p[0])] = fmt.Sprint(p[1])
+			}
+		}
+	}
+	names := make([]string, 0, len(own))
+	for t := range own {
+		names = append(names, t)
+	}
+	sort.Strings(names)
+	for _, t := range names {
+		if _, ok := tags[t]; !ok {
+			add(t, own[t])
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
@@ -0,0 +1,101 @@
+package sentry
+
+import (
+	"encoding/json"
+	"go_report/domain"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func event(t *testing.T, s string) Event {
+	e, err := DecodeEvent([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestFingerprint(t *testing.T) {
+	stack := `"stacktrace":{"frames":[{"module":"lib","function":"f"},{"filename":"main.go","function":"g","in_app":true}]}`
+	tests := []struct {
+		name  string
+		event string
+		want  []string
+	}{
+		{"own fingerprint", `{"fingerprint":["a",1]}`, []string{"a", "1"}},
+		{"own fingerprint with default", `{"fingerprint":["{{ default }}","a"],"message":"m"}`, []string{"m", "a"}},
+		{"empty fingerprint", `{"fingerprint":[],"message":"m"}`, []string{"m"}},
+		{"exception stack, in app", `{"exception":{"values":[{"type":"E",` + stack + `}]}}`, []string{"E", "main.go:g"}},
+		{"exception stack", `{"exception":[{"type":"E","stacktrace":{"frames":[{"package":"p","symbol":"s"}]}}]}`, []string{"E", "p:s"}},
+		{"outermost exception", `{"exception":{"values":[{"type":"Inner","value":"i"},{"type":"Outer","value":"o"}]}}`, []string{"Outer", "o"}},
+		{"logentry", `{"logentry":{"message":"m %s"},"message":"formatted"}`, []string{"m %s"}},
+		{"message", `{"message":"m"}`, []string{"m"}},
+		{"message as logentry", `{"message":{"message":"m"}}`, []string{"m"}},
+		{"nothing", `{"platform":"go","transaction":"/x"}`, []string{"go", "", "/x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := event(t, tt.event).fingerprint(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    string
+		severity domain.ReportType
+		release  string
+		tags     map[string]string
+	}{
+		{"bug", `{"message":"m","release":"1.0"}`, domain.BugType, "1.0", nil},
+		{"fatal", `{"message":"m","level":"fatal"}`, domain.CrashType, "", map[string]string{"level": "fatal"}},
+		{"handled exception", `{"exception":{"values":[{"type":"E","mechanism":{"handled":true}}]}}`, domain.BugType, "", nil},
+		{"unhandled exception", `{"exception":{"values":[{"type":"E","mechanism":{"handled":false}}]}}`, domain.CrashType, "", nil},
+		{"tags", `{"environment":"prod","level":"error","tags":{"os":"linux","level":"ignored","a=b":"x","n":1}}`, domain.BugType, "",
+			map[string]string{"environment": "prod", "level": "error", "os": "linux", "n": "1"}},
+		{"tag pairs", `{"tags":[["os","linux"],["bad"]]}`, domain.BugType, "", map[string]string{"os": "linux"}},
+		{"long tag value", `{"tags":{"os":"` + strings.Repeat("x", domain.MaxValueLength+1) + `"}}`, domain.BugType, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := event(t, tt.event)
+			rpt := e.Report("p")
+			if rpt.GID != GID("p", e.fingerprint()) {
+				t.Errorf("gid %v, want the fingerprint's", rpt.GID)
+			}
+			if rpt.Severity != tt.severity || rpt.Release != tt.release || !reflect.DeepEqual(rpt.Tags, tt.tags) {
+				t.Errorf("got %v, %q, %v, want %v, %q, %v", rpt.Severity, rpt.Release, rpt.Tags, tt.severity, tt.release, tt.tags)
+			}
+		})
+	}
+}
+
+func TestTagsLimit(t *testing.T) {
+	own := map[string]string{}
+	for i := 0; i < domain.MaxTags+2; i++ {
+		own[string(rune('a'+i))] = "v"
+	}
+	b, err := json.Marshal(map[string]interface{}{"environment": "prod", "tags": own})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := event(t, string(b)).tags()
+	if len(tags) != domain.MaxTags || tags["environment"] != "prod" || tags["a"] != "v" {
+		t.Errorf("got %v, want environment then the first of the event's own tags", tags)
+	}
+}
+
+func TestGIDIsStable(t *testing.T) {
+	a := `{"exception":{"values":[{"type":"E","value":"1"}]},"event_id":"x"}`
+	b := `{"exception":{"values":[{"type":"E","value":"1"}]},"event_id":"y","level":"fatal"}`
+	if event(t, a).Report("p").GID != event(t, b).Report("p").GID {
+		t.Error("events with the same fingerprint are in different groups")
+	}
+	if event(t, a).Report("p").GID == event(t, a).Report("q").GID {
+		t.Error("projects share a group")
+	}
+}
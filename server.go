@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"go_report/domain"
-	"log"
+	"go_report/ingest"
+	"go_report/stream"
+	"go_report/tracing"
+	"go_report/webhook"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 	aws "github.com/aws/aws-sdk-go/aws"
 	seshman "github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
 func main() {
@@ -23,24 +30,126 @@ func main() {
 	cfg, shh, ghs, store, logger, err := LoadFromParamStore(sesh)
 	if err != nil {
 		if logger != nil {
-			log.Fatal(err.Error())
+			logger.Fatal("could not load config", "err", err)
 		} else {
 			panic(err)
 		}
 	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := Migrate(context.Background(), logger); err != nil {
+			logger.Fatal("migration failed", "err", err)
+		}
+		return
+	}
 	ict, err := strconv.Atoi(cfg.IssueCreationThreshold)
 	if err != nil {
 		ict = domain.DisableIssueCreation// default to disabling issue creation if non-int passed
 	}
-	r := NewRouter(ict, store, shh, ghs, logger)
-	logger.Println("Router created, starting server...")
+	sunset, err := time.Parse("2006-01-02", cfg.UnversionedSunset)
+	if err != nil {
+		logger.Fatal("invalid UNVERSIONED_SUNSET", "value", cfg.UnversionedSunset, "err", err)
+	}
+	idemWindow, err := time.ParseDuration(cfg.IdempotencyWindow)
+	if err != nil {
+		logger.Fatal("invalid IDEMPOTENCY_WINDOW", "value", cfg.IdempotencyWindow, "err", err)
+	}
+	limits, err := ParseRateLimiters(cfg.RateLimitApps, cfg.RateLimitDevs, cfg.RateLimitToken, cfg.RateLimitCertificates)
+	if err != nil {
+		logger.Fatal("invalid rate limits", "err", err)
+	}
+	if cfg.TrustedProxyHeader != "none" {
+		limits.ProxyHeader = cfg.TrustedProxyHeader
+	}
+	qcfg, err := ParseIngestConfig(cfg.IngestQueueSize, cfg.IngestWorkers, cfg.IngestSpillDir, cfg.IngestMaxSpill)
+	if err != nil {
+		logger.Fatal("invalid ingestion queue config", "err", err)
+	}
+	bodyLimits, err := ParseBodyLimits(cfg.MaxUploadBytes, cfg.MaxReportBytes, cfg.MaxJSONDepth)
+	if err != nil {
+		logger.Fatal("invalid body limits", "err", err)
+	}
+	m := NewMetrics()
+	tracer, traces, err := NewTracer(cfg.OTLPEndpoint, cfg.TraceSampleRatio, logger)
+	if err != nil {
+		logger.Fatal("invalid tracing config", "err", err)
+	}
+	store.OnCall(m.ObserveStoreCall)
+	ghs.Transport = func(client string) http.RoundTripper {
+		return tracing.Transport(m.GitHubTransport(client), "github")
+	}
+	shh.OnExchange = m.ObserveTokenExchange
+	broker := stream.NewBroker(stream.DefaultHistory)
+	hcfg, err := ParseWebhookConfig(cfg.WebhookMaxAttempts, cfg.WebhookBackoff, cfg.WebhookTimeout)
+	if err != nil {
+		logger.Fatal("invalid webhook config", "err", err)
+	}
+	hooks, err := webhook.New(hcfg, store, logger)
+	if err != nil {
+		logger.Fatal("could not start webhook dispatcher", "err", err)
+	}
+	hooks.OnAttempt = m.ObserveWebhookAttempt
+	qcfg.OnDrop = ReleaseDropped(store, logger)
+	queue, err := ingest.New(qcfg, StoreReport(ict, store, ghs, broker, hooks, m, tracer, logger), logger)
+	if err != nil {
+		logger.Fatal("could not start ingestion queue", "err", err)
+	}
+	m.WatchQueue(queue)
+	timeouts, err := ParseTimeouts(cfg.ReadTimeout, cfg.WriteTimeout, cfg.IdleTimeout, cfg.ShutdownTimeout)
+	if err != nil {
+		logger.Fatal("invalid timeouts", "err", err)
+	}
+	stopMetrics := ServeMetrics(cfg.MetricsPort, m, timeouts, logger)
+	tlsCfg, stopTLS, err := StartTLS(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile, cfg.TLSReloadInterval, logger)
+	if err != nil {
+		logger.Fatal("invalid tls config", "err", err)
+	}
+	dumps, err := ParseMinidumps(cfg.MinidumpDir, cfg.MinidumpGID, cfg.MaxMinidumpBytes)
+	if err != nil {
+		logger.Fatal("invalid minidump config", "err", err)
+	}
+	readyCacheFor, err := time.ParseDuration(cfg.ReadyCacheFor)
+	if err != nil {
+		logger.Fatal("invalid READY_CACHE_FOR", "value", cfg.ReadyCacheFor, "err", err)
+	}
+	checkTimeout, err := time.ParseDuration(cfg.ReadyCheckTimeout)
+	if err != nil {
+		logger.Fatal("invalid READY_CHECK_TIMEOUT", "value", cfg.ReadyCheckTimeout, "err", err)
+	}
+	health := NewHealth(readyCacheFor,
+		Check{Name: "store", Timeout: checkTimeout, Run: store.Ping},
+		Check{Name: "github", Timeout: checkTimeout, Run: ghs.CheckInstallation},
+		Check{Name: "paramStore", Timeout: checkTimeout, Run: paramStoreCheck(ssm.New(sesh))},
+	)
+	svc := Services{
+		Store:             store,
+		Auth:              shh,
+		GitHub:            ghs,
+		Broker:            broker,
+		Queue:             queue,
+		Logger:            logger,
+		Limits:            bodyLimits,
+		IdempotencyWindow: idemWindow,
+		RateLimits:        limits,
+		HandlerTimeout:    timeouts.Write,
+		Health:            health,
+		Metrics:           m,
+		Tracer:            tracer,
+		Minidumps:         dumps,
+		Webhooks:          hooks,
+	}
+	r := NewRouter(svc, sunset)
+	stopRPC, err := ServeGRPC(cfg.GRPCPort, NewGRPCServer(svc, tlsCfg), logger)
+	if err != nil {
+		logger.Fatal("could not start gRPC server", "err", err)
+	}
+	logger.Info("Router created, starting server...", "port", cfg.Port, "grpcPort", cfg.GRPCPort, "tls", tlsCfg != nil, "clientCertificates", tlsCfg != nil && tlsCfg.ClientCAs != nil)
 
-	// Start serving
-	if err := http.ListenAndServe(":"+cfg.Port, r); err != nil {
-		if err != http.ErrServerClosed {
-			logger.Panic(err)
-		} else {
-			logger.Println("server shutdown complete.")
-		}
+	// Start serving, until SIGINT/SIGTERM
+	srv := NewServer(cfg.Port, r, timeouts)
+	srv.TLSConfig = tlsCfg
+	srv.RegisterOnShutdown(stopTLS)
+	if err := Serve(srv, timeouts.Shutdown, stopRPC, queue, hooks, broker, traces, logger); err != nil {
+		logger.Fatal("server failed", "err", err)
 	}
+	stopMetrics() // once the queue has drained
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
@@ -9,10 +10,10 @@ import (
 	"github.com/pkg/errors"
 	"go_report/auth"
 	"go_report/gh"
+	"go_report/logging"
 	"go_report/store/dynamo"
-	"log"
+	"io"
 	"os"
-	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
@@ -21,8 +22,44 @@ import (
 type Config struct {
 	Port    string `json:"port" paramName:"BRS_PORT" paramDefault:"8080"`       // Port on which to connect the server
 	LogFile string `json:"logFile" paramName:"BRS_LOGFILE" paramDefault:"stderr"` // File location for log
+	LogLevel string `json:"logLevel" paramName:"LOG_LEVEL" paramDefault:"info"` // Least severe level logged: debug, info, warn or error
 	TableName string `json:"tableName" paramName:"TABLE_NAME" paramDefault:"BugReports"`
+	MetaTableName string `json:"metaTableName" paramName:"META_TABLE_NAME" paramDefault:"BugReportsMeta"`
 	IssueCreationThreshold string `json:"issueCreationThreshold" paramName:"ISSUE_CREATION_THRESHOLD" paramDefault:"x"`
+	MaxUploadBytes string `json:"maxUploadBytes" paramName:"MAX_UPLOAD_BYTES" paramDefault:"1048576"` // Largest report upload, as sent (compressed)
+	MaxReportBytes string `json:"maxReportBytes" paramName:"MAX_REPORT_BYTES" paramDefault:"358400"` // Largest report, once decompressed (at most 358400, see domain.MaxStoredReport)
+	MaxJSONDepth string `json:"maxJSONDepth" paramName:"MAX_JSON_DEPTH" paramDefault:"32"` // Deepest nesting of a report's json
+	IdempotencyWindow string `json:"idempotencyWindow" paramName:"IDEMPOTENCY_WINDOW" paramDefault:"24h"` // How long report Idempotency-Keys are remembered (a go duration, 0 to disable)
+	RateLimitApps string `json:"rateLimitApps" paramName:"RATE_LIMIT_APPS" paramDefault:"60/1m,20"` // Requests per app certificate: <count>/<duration>[,<burst>], or none
+	RateLimitDevs string `json:"rateLimitDevs" paramName:"RATE_LIMIT_DEVS" paramDefault:"600/1m"` // Requests per developer
+	RateLimitToken string `json:"rateLimitToken" paramName:"RATE_LIMIT_TOKEN" paramDefault:"10/1m"` // Token exchanges per client ip (see TRUSTED_PROXY_HEADER), or per certificate / github user
+	TrustedProxyHeader string `json:"trustedProxyHeader" paramName:"TRUSTED_PROXY_HEADER" paramDefault:"none"` // Header the proxy in front of the api sets to the client's ip (i.e. X-Forwarded-For), or none
+	RateLimitCertificates string `json:"rateLimitCertificates" paramName:"RATE_LIMIT_CERTIFICATES" paramDefault:"{}"` // json object of certificate md5 to its own app limit
+	IngestQueueSize string `json:"ingestQueueSize" paramName:"INGEST_QUEUE_SIZE" paramDefault:"1000"` // Reports waiting in memory to be stored
+	IngestWorkers string `json:"ingestWorkers" paramName:"INGEST_WORKERS" paramDefault:"4"` // Reports stored at once
+	IngestSpillDir string `json:"ingestSpillDir" paramName:"INGEST_SPILL_DIR" paramDefault:"ingest-spill"` // Where reports wait on disk when the queue is full (survives restarts)
+	IngestMaxSpill string `json:"ingestMaxSpill" paramName:"INGEST_MAX_SPILL" paramDefault:"100000"` // Reports waiting on disk, beyond which reports are refused (503)
+	ReadTimeout string `json:"readTimeout" paramName:"READ_TIMEOUT" paramDefault:"30s"` // To read a request
+	WriteTimeout string `json:"writeTimeout" paramName:"WRITE_TIMEOUT" paramDefault:"60s"` // To handle a request (except live tails)
+	IdleTimeout string `json:"idleTimeout" paramName:"IDLE_TIMEOUT" paramDefault:"120s"` // Between requests on a keep-alive connection
+	ReadyCacheFor string `json:"readyCacheFor" paramName:"READY_CACHE_FOR" paramDefault:"15s"` // How long /readyz reuses each check's result
+	ReadyCheckTimeout string `json:"readyCheckTimeout" paramName:"READY_CHECK_TIMEOUT" paramDefault:"5s"` // Before a /readyz check fails
+	ShutdownTimeout string `json:"shutdownTimeout" paramName:"SHUTDOWN_TIMEOUT" paramDefault:"30s"` // For in-flight requests & queued reports at shutdown
+	TLSCertFile string `json:"tlsCertFile" paramName:"TLS_CERT_FILE" paramDefault:"none"` // pem certificate (chain) to serve https with, or none for http
+	TLSKeyFile string `json:"tlsKeyFile" paramName:"TLS_KEY_FILE" paramDefault:"none"` // pem key of TLS_CERT_FILE
+	TLSClientCAFile string `json:"tlsClientCAFile" paramName:"TLS_CLIENT_CA_FILE" paramDefault:"none"` // pem CAs whose client certificates apps may post reports with, or none
+	TLSReloadInterval string `json:"tlsReloadInterval" paramName:"TLS_RELOAD_INTERVAL" paramDefault:"30s"` // How often the certificate & key files are checked for changes
+	MinidumpDir string `json:"minidumpDir" paramName:"MINIDUMP_DIR" paramDefault:"minidumps"` // Where uploaded minidumps are stored (shared by every instance)
+	MinidumpGID string `json:"minidumpGID" paramName:"MINIDUMP_GID" paramDefault:"minidump"` // Group of the reports of uploaded minidumps
+	MaxMinidumpBytes string `json:"maxMinidumpBytes" paramName:"MAX_MINIDUMP_BYTES" paramDefault:"33554432"` // Largest minidump upload
+	WebhookMaxAttempts string `json:"webhookMaxAttempts" paramName:"WEBHOOK_MAX_ATTEMPTS" paramDefault:"8"` // Attempts to deliver each webhook event
+	WebhookBackoff string `json:"webhookBackoff" paramName:"WEBHOOK_BACKOFF" paramDefault:"30s"` // Before a delivery's first retry, doubled before each next one (up to 1h)
+	WebhookTimeout string `json:"webhookTimeout" paramName:"WEBHOOK_TIMEOUT" paramDefault:"10s"` // Of each delivery attempt
+	GRPCPort string `json:"grpcPort" paramName:"GRPC_PORT" paramDefault:"50051"` // Port on which the gRPC api is served (over tls if the http api is), or none
+	MetricsPort string `json:"metricsPort" paramName:"METRICS_PORT" paramDefault:"9090"` // Port on which prometheus metrics are served at /metrics, apart from the api, or none
+	OTLPEndpoint string `json:"otlpEndpoint" paramName:"OTLP_ENDPOINT" paramDefault:"none"` // OTLP/HTTP collector spans are exported to (i.e. http://localhost:4318), or none
+	TraceSampleRatio string `json:"traceSampleRatio" paramName:"TRACE_SAMPLE_RATIO" paramDefault:"1"` // Share of traces (0..1) recorded, unless a client's traceparent decides
+	UnversionedSunset string `json:"unversionedSunset" paramName:"UNVERSIONED_SUNSET" paramDefault:"2027-04-19"` // date (YYYY-MM-DD) the unversioned api routes are removed
 }
 
 //ReadConfigFromFile reads a cfg.json file into a Config struct
@@ -41,24 +78,31 @@ func ReadConfigFromFile(fp string) (c Config, err error) {
 	return cfg, nil
 }
 
-func StartLogger(fp string) (*log.Logger, error) {
-	var logger *log.Logger
+// StartLogger writes json lines of level and above to fp: a file, or stderr/stdout (2/1)
+func StartLogger(fp, level string) (*logging.Logger, error) {
+	lvl, err := logging.ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	var w io.Writer
 	if strings.ToLower(fp) == "stderr" || fp == "2" {
-		logger = log.New(os.Stderr, "Status: ", log.LstdFlags|log.Lshortfile)
+		w = os.Stderr
 	} else if strings.ToLower(fp) == "stdout" || fp == "1" {
-		logger = log.New(os.Stdout, "Status: ", log.LstdFlags|log.Lshortfile)
+		w = os.Stdout
 	} else {
 		logFile, err := os.OpenFile(fp, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 		if err != nil {
 			return nil, err
 		}
-		logger = log.New(logFile, filepath.Base(fp)+": ", log.LstdFlags|log.Lshortfile)
+		w = logFile
 	}
-	logger.Println("Logger started successfully.")
+	logger := logging.New(w, lvl)
+	logging.SetDefault(logger)
+	logger.Info("Logger started successfully.", "level", lvl)
 	return logger, nil
 }
 
-func startGHService(svc *ssm.SSM) (*gh.Service, error) {
+func startGHService(svc *ssm.SSM, logger *logging.Logger) (*gh.Service, error) {
 	var repo gh.Repo
 	if err := LoadParams(svc, &repo); err != nil {
 		return nil, err
@@ -90,10 +134,10 @@ func startGHService(svc *ssm.SSM) (*gh.Service, error) {
 		return nil, err
 	}
 	ghshh.InstallID = i
-	return gh.New(repo, ghshh), nil
+	return gh.New(repo, ghshh, logger), nil
 }
 
-func startAuthService(svc *ssm.SSM, store *dynamo.Store, ghs *gh.Service, logger *log.Logger) (*auth.Service, error) {
+func startAuthService(svc *ssm.SSM, store *dynamo.Store, ghs *gh.Service, logger *logging.Logger) (*auth.Service, error) {
 	var shh auth.Secrets
 	if err := LoadParams(svc, &shh); err != nil {
 		return nil, err
@@ -101,6 +145,14 @@ func startAuthService(svc *ssm.SSM, store *dynamo.Store, ghs *gh.Service, logger
 	return auth.New(store, shh, ghs, logger), nil
 }
 
+// paramStoreCheck confirms the parameter store can be reached with the session's credentials
+func paramStoreCheck(svc *ssm.SSM) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := svc.DescribeParametersWithContext(ctx, &ssm.DescribeParametersInput{MaxResults: aws.Int64(1)})
+		return errors.Wrap(err, "could not describe parameters")
+	}
+}
+
 func DescribeParametersAvailable(svc *ssm.SSM) {
 	dpo, err := svc.DescribeParameters(&ssm.DescribeParametersInput{MaxResults: aws.Int64(15)})
 	if err != nil {
@@ -109,7 +161,7 @@ func DescribeParametersAvailable(svc *ssm.SSM) {
 	fmt.Printf("Found Parameters: %+v", dpo.String())
 }
 
-func LoadFromParamStore(sesh *awsesh.Session) (cfg Config, auth *auth.Service, ghs *gh.Service, store *dynamo.Store, logger *log.Logger, err error) {
+func LoadFromParamStore(sesh *awsesh.Session) (cfg Config, auth *auth.Service, ghs *gh.Service, store *dynamo.Store, logger *logging.Logger, err error) {
 	svc := ssm.New(sesh)
 
 	//DescribeParametersAvailable(svc)
@@ -117,12 +169,12 @@ func LoadFromParamStore(sesh *awsesh.Session) (cfg Config, auth *auth.Service, g
 	if err = LoadParams(svc, &cfg); err != nil {
 		return
 	}
-	if logger, err = StartLogger(cfg.LogFile); err != nil {
+	if logger, err = StartLogger(cfg.LogFile, cfg.LogLevel); err != nil {
 		return
 	}
-	store = dynamo.New(sesh, cfg.TableName, logger)
-	fmt.Printf("%+v %+v", store, cfg)
-	if ghs, err = startGHService(svc); err != nil {
+	store = dynamo.New(sesh, cfg.TableName, cfg.MetaTableName, logger)
+	logger.Debug("loaded config", "config", fmt.Sprintf("%+v", cfg))
+	if ghs, err = startGHService(svc, logger); err != nil {
 		return
 	}
 	if auth, err = startAuthService(svc, store, ghs, logger); err != nil {
@@ -167,8 +219,7 @@ func LoadParams(svc *ssm.SSM, v interface{}) (err error) {
 		if err != nil {
 			dv := field.Tag.Get(defaultValueTagName)
 			if dv == "" {
-				fmt.Printf("param: %+v\tsecret: %+v", pn, isSecret)
-				return err
+				return errors.Wrapf(err, "could not get param %v (secret: %v)", pn, isSecret)
 			}
 			// sets default value of param
 			param = &ssm.GetParameterOutput{
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"go_report/ingest"
+	"go_report/logging"
+	"go_report/stream"
+	"go_report/webhook"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Timeouts of the http server. Write is enforced per request by HandlerTimeout, except on streaming routes.
+type Timeouts struct {
+	Read, Write, Idle time.Duration
+	Shutdown          time.Duration // for in-flight requests, then queued reports, to finish
+}
+
+// ParseTimeouts reads go durations (i.e. "30s")
+func ParseTimeouts(read, write, idle, shutdown string) (t Timeouts, err error) {
+	for _, d := range []struct {
+		name, value string
+		to          *time.Duration
+	}{
+		{"read", read, &t.Read},
+		{"write", write, &t.Write},
+		{"idle", idle, &t.Idle},
+		{"shutdown", shutdown, &t.Shutdown},
+	} {
+		if *d.to, err = time.ParseDuration(d.value); err != nil {
+			return t, errors.Wrapf(err, "invalid %v timeout", d.name)
+		}
+	}
+	return t, nil
+}
+
+// NewServer serves h on the port, with the timeouts
+func NewServer(port string, h http.Handler, t Timeouts) *http.Server {
+	return &http.Server{
+		Addr:              ":" + port,
+		Handler:           h,
+		ReadTimeout:       t.Read,
+		ReadHeaderTimeout: t.Read,
+		IdleTimeout:       t.Idle,
+		// WriteTimeout would end live tails, see HandlerTimeout
+	}
+}
+
+// Serve runs the server until SIGINT or SIGTERM. It then stops accepting connections, ends live tails, and waits
+// for in-flight requests & gRPC calls (see ServeGRPC), then the ingestion queue, then webhook deliveries to finish, for
+// up to the shutdown timeout.
+func Serve(srv *http.Server, shutdown time.Duration, stopRPC func(context.Context) error, queue *ingest.Queue, hooks *webhook.Dispatcher, broker *stream.Broker, traces *sdktrace.TracerProvider, logger *logging.Logger) error {
+	srv.ErrorLog = logger.StdLogger(logging.Warn)
+	srv.RegisterOnShutdown(broker.Close)
+	served := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			served <- srv.ListenAndServeTLS("", "") // the config has the certificate
+			return
+		}
+		served <- srv.ListenAndServe()
+	}()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	select {
+	case err := <-served:
+		return err // could not listen
+	case s := <-sig:
+		logger.Info("shutting down...", "signal", s)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdown)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Warn("in-flight requests did not finish", "err", err)
+	} else {
+		logger.Info("in-flight requests finished.")
+	}
+	if err := stopRPC(ctx); err != nil {
+		logger.Warn("in-flight gRPC calls did not finish", "err", err)
+	}
+	if err := queue.Shutdown(ctx); err != nil {
+		logger.Warn("ingestion queue did not drain", "err", err)
+	}
+	if err := hooks.Shutdown(ctx); err != nil { // after the queue, whose jobs send events
+		logger.Warn("webhook deliveries did not finish", "err", err)
+	}
+	if traces != nil {
+		if err := traces.Shutdown(ctx); err != nil { // after the queue, whose jobs are spans too
+			logger.Warn("could not flush traces", "err", err)
+		}
+	}
+	st := queue.Stats()
+	logger.Info("server shutdown complete.", "stored", st.Processed, "dropped", st.Dropped, "spilledForNextStart", st.Spilled)
+	return nil
+}
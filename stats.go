@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"go_report/domain"
+	"go_report/failure"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultTopGroups = 10
+	maxStatsBuckets  = 24 * 31 // bounds the window of a single stats request
+)
+
+// Stats are the report counts of a window of time buckets, and the groups which grew the most over the window
+type Stats struct {
+	Resolution domain.Resolution `json:"resolution"`
+	From       time.Time         `json:"from"`
+	To         time.Time         `json:"to"`
+	Buckets    []StatsBucket     `json:"buckets"`
+	Top        []GroupGrowth     `json:"top"`
+}
+
+type StatsBucket struct {
+	Start      time.Time      `json:"start"`
+	Total      int            `json:"total"`
+	BySeverity map[string]int `json:"bySeverity"`
+	ByGroup    map[string]int `json:"byGroup"`
+}
+
+// GroupGrowth compares a group's reports in the window to those in the window of equal length before it
+type GroupGrowth struct {
+	GID      string `json:"gid"`
+	Count    int    `json:"count"`
+	Previous int    `json:"previous"`
+	Growth   int    `json:"growth"`
+}
+
+// GetStatsHandler returns report counts bucketed by ?resolution=hour|day between ?from= and ?to= (RFC3339),
+// with the ?top=N groups by growth. Defaults to the last day by hour, or the last 30 days by day.
+func GetStatsHandler(s domain.StatsStorer) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st, top, err := statsWindow(r)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		current, err := s.SelectStats(r.Context(), st.Resolution, st.From, st.To)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		window := st.To.Sub(st.From) + st.Resolution.Duration()
+		previous, err := s.SelectStats(r.Context(), st.Resolution, st.From.Add(-window), st.From.Add(-st.Resolution.Duration()))
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		st.Buckets, st.Top = bucketStats(st, current), topGrowth(current, previous, top)
+		if err := json.NewEncoder(w).Encode(st); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode stats json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// statsWindow reads the resolution, window (aligned to buckets) and top-N of a stats request
+func statsWindow(r *http.Request) (Stats, int, error) {
+	q := r.URL.Query()
+	st := Stats{Resolution: domain.Hourly}
+	if res := q.Get("resolution"); res != "" {
+		var ok bool
+		if st.Resolution, ok = domain.ConvertResolutionString(res); !ok {
+			return st, 0, failure.New(errors.Errorf("unknown resolution %v", res), http.StatusBadRequest, "resolution must be one of hour, day")
+		}
+	}
+	st.To = time.Now()
+	if to := q.Get("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return st, 0, failure.New(err, http.StatusBadRequest, "to must be an RFC3339 time")
+		}
+		st.To = t
+	}
+	if from := q.Get("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return st, 0, failure.New(err, http.StatusBadRequest, "from must be an RFC3339 time")
+		}
+		st.From = t
+	} else if st.Resolution == domain.Daily {
+		st.From = st.To.AddDate(0, 0, -29)
+	} else {
+		st.From = st.To.Add(-23 * time.Hour)
+	}
+	st.From, st.To = st.Resolution.Bucket(st.From), st.Resolution.Bucket(st.To)
+	if st.From.After(st.To) {
+		return st, 0, failure.New(errors.New("stats from after to"), http.StatusBadRequest, "from must not be after to")
+	} else if n := int(st.To.Sub(st.From)/st.Resolution.Duration()) + 1; n > maxStatsBuckets {
+		return st, 0, failure.New(errors.Errorf("stats window of %v buckets", n), http.StatusBadRequest, "window too large, use a coarser resolution")
+	}
+	top := defaultTopGroups
+	if t := q.Get("top"); t != "" {
+		n, err := strconv.Atoi(t)
+		if err != nil || n < 1 {
+			return st, 0, failure.New(errors.Errorf("invalid top %v", t), http.StatusBadRequest, "top must be a positive integer")
+		}
+		top = n
+	}
+	return st, top, nil
+}
+
+// bucketStats totals the counts of each bucket in the window, including empty buckets
+func bucketStats(st Stats, counts []domain.StatCount) []StatsBucket {
+	buckets := make([]StatsBucket, 0, int(st.To.Sub(st.From)/st.Resolution.Duration())+1)
+	index := map[time.Time]int{}
+	for t := st.From; !t.After(st.To); t = t.Add(st.Resolution.Duration()) {
+		index[t] = len(buckets)
+		buckets = append(buckets, StatsBucket{Start: t, BySeverity: map[string]int{}, ByGroup: map[string]int{}})
+	}
+	for _, c := range counts {
+		i, ok := index[c.Bucket.UTC()]
+		if !ok {
+			continue
+		}
+		buckets[i].Total += c.Count
+		buckets[i].BySeverity[c.Severity.String()] += c.Count
+		buckets[i].ByGroup[c.GID] += c.Count
+	}
+	return buckets
+}
+
+// topGrowth orders groups by how many more reports they had in the current window than the previous one
+func topGrowth(current, previous []domain.StatCount, n int) []GroupGrowth {
+	byGID := map[string]*GroupGrowth{}
+	get := func(gid string) *GroupGrowth {
+		if g, ok := byGID[gid]; ok {
+			return g
+		}
+		byGID[gid] = &GroupGrowth{GID: gid}
+		return byGID[gid]
+	}
+	for _, c := range current {
+		get(c.GID).Count += c.Count
+	}
+	for _, c := range previous {
+		get(c.GID).Previous += c.Count
+	}
+	growth := make([]GroupGrowth, 0, len(byGID))
+	for _, g := range byGID {
+		g.Growth = g.Count - g.Previous
+		growth = append(growth, *g)
+	}
+	sort.Slice(growth, func(i, j int) bool {
+		if growth[i].Growth == growth[j].Growth {
+			return growth[i].GID < growth[j].GID
+		}
+		return growth[i].Growth > growth[j].Growth
+	})
+	if len(growth) > n {
+		growth = growth[:n]
+	}
+	return growth
+}
@@ -0,0 +1,80 @@
+package dynamo
+
+import (
+	"context"
+	"go_report/domain"
+	"go_report/failure"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/pkg/errors"
+)
+
+type commentItem struct {
+	metaKey
+	domain.Comment
+}
+
+func commentKey(gid, id string) metaKey {
+	return metaKey{PK: "comment#" + gid, SK: id}
+}
+
+func (s *Store) NewComment(ctx context.Context, c domain.Comment) (domain.Comment, error) {
+	c.ID = domain.NewCommentID(c.CreatedOn)
+	av, err := dynamodbattribute.MarshalMap(commentItem{metaKey: commentKey(c.GID, c.ID), Comment: c})
+	if err != nil {
+		return domain.Comment{}, errToFailure(err)
+	}
+	cond, err := expression.NewBuilder().WithCondition(expression.AttributeNotExists(expression.Name("pk"))).Build()
+	if err != nil {
+		return domain.Comment{}, errToFailure(err)
+	}
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item:                      av,
+		TableName:                 aws.String(s.MetaTable),
+		ConditionExpression:       cond.Condition(),
+		ExpressionAttributeNames:  cond.Names(),
+		ExpressionAttributeValues: cond.Values(),
+	})
+	if err != nil {
+		return domain.Comment{}, errToFailure(err)
+	}
+	return c, nil
+}
+
+func (s *Store) UpdateComment(ctx context.Context, c domain.Comment) (domain.Comment, error) {
+	upd := expression.Set(expression.Name("body"), expression.Value(c.Body)).
+		Set(expression.Name("editedOn"), expression.Value(c.EditedOn)).
+		Set(expression.Name("issueCommentID"), expression.Value(c.IssueCommentID))
+	cond := expression.AttributeExists(expression.Name("pk"))
+	item := new(commentItem)
+	if ok, err := s.updateMeta(ctx, commentKey(c.GID, c.ID), upd, &cond, item); err != nil {
+		return domain.Comment{}, err
+	} else if !ok {
+		return domain.Comment{}, failure.New(errors.Errorf("no comment %v on group %v", c.ID, c.GID), http.StatusNotFound, "comment not found")
+	}
+	return item.Comment, nil
+}
+
+func (s *Store) SelectComment(ctx context.Context, gid, id string) (*domain.Comment, error) {
+	item := new(commentItem)
+	if found, err := s.getMeta(ctx, commentKey(gid, id), item); err != nil || !found {
+		return nil, err
+	}
+	return &item.Comment, nil
+}
+
+func (s *Store) SelectComments(ctx context.Context, gid string) ([]domain.Comment, error) {
+	items := make([]commentItem, 0, 8)
+	if err := s.queryMeta(ctx, commentKey(gid, "").PK, &items); err != nil {
+		return nil, err
+	}
+	comments := make([]domain.Comment, 0, len(items))
+	for _, item := range items {
+		comments = append(comments, item.Comment)
+	}
+	return comments, nil
+}
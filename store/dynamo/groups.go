@@ -0,0 +1,143 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"go_report/domain"
+	"go_report/failure"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/pkg/errors"
+)
+
+// Group records are spread over groupShards partitions, as every stored report updates its group's record
+const groupShards = 16
+
+type groupItem struct {
+	metaKey
+	domain.Group
+	// severity counts are kept as top level attributes, so they can be incremented without the map existing first
+	UnknownCount int `json:"unknownCount"`
+	BugCount     int `json:"bugCount"`
+	CrashCount   int `json:"crashCount"`
+}
+
+func (item groupItem) toGroup() domain.Group {
+	g := item.Group
+	g.SeverityCounts = map[string]int{
+		domain.UnknownType.String(): item.UnknownCount,
+		domain.BugType.String():     item.BugCount,
+		domain.CrashType.String():   item.CrashCount,
+	}
+	if g.Status == "" {
+		g.Status = domain.StatusOpen
+	}
+	return g
+}
+
+func groupsPK(shard int) string {
+	return fmt.Sprintf("group#%d", shard)
+}
+
+func groupKey(gid string) metaKey {
+	return metaKey{PK: groupsPK(shardOf(gid, groupShards)), SK: gid}
+}
+
+func severityCountAttr(t domain.ReportType) string {
+	return t.String() + "Count"
+}
+
+// countInGroup applies the stored report to its group's summary, true if it is the group's first report. The record
+// is updated on its own rather than in a transaction, so concurrent reports of the group are applied one after the
+// other instead of conflicting; the count each update returns is its own, so exactly one report is the first.
+func (s *Store) countInGroup(ctx context.Context, r domain.Report) (first bool, err error) {
+	upd := expression.Set(expression.Name("gid"), expression.Value(r.GID)).
+		Set(expression.Name("status"), expression.IfNotExists(expression.Name("status"), expression.Value(domain.StatusOpen))).
+		Set(expression.Name("firstSeen"), expression.IfNotExists(expression.Name("firstSeen"), expression.Value(r.ReceivedOn))).
+		Set(expression.Name("lastSeen"), expression.Value(r.ReceivedOn)).
+		Set(expression.Name("latestKey"), expression.Value(r.Key)).
+		Add(expression.Name("count"), expression.Value(1)).
+		Add(expression.Name(severityCountAttr(r.Severity)), expression.Value(1))
+	if r.Release != "" {
+		upd = upd.Set(expression.Name("firstRelease"), expression.IfNotExists(expression.Name("firstRelease"), expression.Value(r.Release))).
+			Set(expression.Name("lastRelease"), expression.Value(r.Release))
+	}
+	item := new(groupItem)
+	if _, err := s.updateMeta(ctx, groupKey(r.GID), upd, nil, item); err != nil {
+		return false, err
+	}
+	return item.Count == 1, nil
+}
+
+func (s *Store) SelectGroupInfo(ctx context.Context, gid string) (*domain.Group, error) {
+	item := new(groupItem)
+	found, err := s.getMeta(ctx, groupKey(gid), item)
+	if err != nil {
+		return nil, err
+	} else if !found {
+		g := domain.NewGroup(gid)
+		return &g, nil
+	}
+	g := item.toGroup()
+	return &g, nil
+}
+
+func (s *Store) SelectAllGroupInfo(ctx context.Context) ([]domain.Group, error) {
+	groups := make([]domain.Group, 0, 32)
+	for shard := 0; shard < groupShards; shard++ {
+		items := make([]groupItem, 0, 32)
+		if err := s.queryMeta(ctx, groupsPK(shard), &items); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			groups = append(groups, item.toGroup())
+		}
+	}
+	return groups, nil
+}
+
+func (s *Store) SetGroupStatus(ctx context.Context, gid string, status domain.GroupStatus, by string) (*domain.Group, error) {
+	upd := expression.Set(expression.Name("gid"), expression.Value(gid)).
+		Set(expression.Name("status"), expression.Value(status)).
+		Set(expression.Name("statusBy"), expression.Value(by)).
+		Set(expression.Name("statusOn"), expression.Value(time.Now()))
+	if status == domain.StatusResolved {
+		upd = upd.Set(expression.Name("resolvedIn"), expression.IfNotExists(expression.Name("lastRelease"), expression.Value(""))).
+			Remove(expression.Name("regressedIn"))
+	}
+	// only groups which have been reported, so an unknown gid does not become a listed group
+	cond := expression.AttributeExists(expression.Name("pk"))
+	item := new(groupItem)
+	if ok, err := s.updateMeta(ctx, groupKey(gid), upd, &cond, item); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, failure.New(errors.Errorf("no group %v", gid), http.StatusNotFound, "group not found")
+	}
+	g := item.toGroup()
+	return &g, nil
+}
+
+func (s *Store) RegressGroup(ctx context.Context, gid string, release string) (*domain.Group, error) {
+	upd := expression.Set(expression.Name("status"), expression.Value(domain.StatusRegressed)).
+		Set(expression.Name("statusOn"), expression.Value(time.Now())).
+		Remove(expression.Name("statusBy"))
+	if release != "" {
+		upd = upd.Set(expression.Name("regressedIn"), expression.Value(release))
+	}
+	cond := expression.Name("status").Equal(expression.Value(domain.StatusResolved))
+	item := new(groupItem)
+	if ok, err := s.updateMeta(ctx, groupKey(gid), upd, &cond, item); err != nil || !ok {
+		return nil, err
+	}
+	g := item.toGroup()
+	return &g, nil
+}
+
+func (s *Store) SetGroupIssue(ctx context.Context, gid string, number int) error {
+	upd := expression.Set(expression.Name("gid"), expression.Value(gid)).
+		Set(expression.Name("issueNumber"), expression.Value(number))
+	_, err := s.updateMeta(ctx, groupKey(gid), upd, nil, nil)
+	return err
+}
@@ -0,0 +1,33 @@
+package dynamo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGroupKey(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		gid := strings.Repeat("g", i%20) + string(rune('a'+i%26))
+		k := groupKey(gid)
+		if k != groupKey(gid) {
+			t.Fatalf("%v: key is not stable", gid)
+		}
+		if k.SK != gid || !strings.HasPrefix(k.PK, "group#") {
+			t.Fatalf("%v: got key %+v", gid, k)
+		}
+		seen[k.PK] = true
+	}
+	if len(seen) < groupShards/2 {
+		t.Errorf("groups fell in only %d of %d shards", len(seen), groupShards)
+	}
+	for pk := range seen {
+		found := false
+		for shard := 0; shard < groupShards; shard++ {
+			found = found || pk == groupsPK(shard)
+		}
+		if !found {
+			t.Errorf("partition %v is not listed by SelectAllGroupInfo", pk)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package dynamo
+
+import (
+	"context"
+	"go_report/domain"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+// idempotencyItem expires by the meta table's TTL attribute "ttl" (once enabled on the table). As TTL deletion
+// is lazy, claims also check expiry themselves.
+type idempotencyItem struct {
+	metaKey
+	domain.IdempotencyRecord
+	TTL int64 `json:"ttl"` // unix seconds
+}
+
+// each key is its own partition, as keys are only ever looked up one at a time
+func idempotencyKey(key string) metaKey {
+	return metaKey{PK: "idempotency#" + key, SK: "request"}
+}
+
+func (s *Store) ClaimIdempotencyKey(ctx context.Context, rec domain.IdempotencyRecord) (bool, domain.IdempotencyRecord, error) {
+	k := idempotencyKey(rec.Key)
+	rec.Receipt = nil
+	av, err := dynamodbattribute.MarshalMap(idempotencyItem{metaKey: k, IdempotencyRecord: rec, TTL: rec.Expires.Unix()})
+	if err != nil {
+		return false, domain.IdempotencyRecord{}, errToFailure(err)
+	}
+	cond, err := expression.NewBuilder().WithCondition(
+		expression.AttributeNotExists(expression.Name("pk")).Or(
+			expression.Name("ttl").LessThanEqual(expression.Value(time.Now().Unix())),
+		),
+	).Build()
+	if err != nil {
+		return false, domain.IdempotencyRecord{}, errToFailure(err)
+	}
+	// the claim may expire (and be deleted) between a failed put and the get, so try twice
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			Item:                      av,
+			TableName:                 aws.String(s.MetaTable),
+			ConditionExpression:       cond.Condition(),
+			ExpressionAttributeNames:  cond.Names(),
+			ExpressionAttributeValues: cond.Values(),
+		})
+		if aerr, isAWS := err.(awserr.Error); !isAWS || aerr.Code() != dynamodb.ErrCodeConditionalCheckFailedException {
+			break
+		}
+		item := new(idempotencyItem)
+		if found, err := s.getMeta(ctx, k, item); err != nil {
+			return false, domain.IdempotencyRecord{}, err
+		} else if found {
+			return false, item.IdempotencyRecord, nil
+		}
+	}
+	if err != nil {
+		return false, domain.IdempotencyRecord{}, errToFailure(err)
+	}
+	return true, rec, nil
+}
+
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, key string, rcpt domain.Receipt) error {
+	upd := expression.Set(expression.Name("receipt"), expression.Value(rcpt))
+	cond := expression.AttributeExists(expression.Name("pk"))
+	_, err := s.updateMeta(ctx, idempotencyKey(key), upd, &cond, nil)
+	return err
+}
+
+func (s *Store) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	av, err := dynamodbattribute.MarshalMap(idempotencyKey(key))
+	if err != nil {
+		return errToFailure(err)
+	}
+	_, err = s.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.MetaTable),
+		Key:       av,
+	})
+	if err != nil {
+		return errToFailure(err)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package dynamo
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"hash/fnv"
+)
+
+// The meta table holds every record which is not a report. Records are partitioned by kind (pk),
+// and identified within their kind by sk, so all records of one kind are listed by a single query.
+type metaKey struct {
+	PK string `json:"pk"` // record kind - PARTITION KEY
+	SK string `json:"sk"` // record id within its kind - SORT KEY
+}
+
+func (s *Store) getMeta(ctx context.Context, k metaKey, out interface{}) (found bool, err error) {
+	av, err := dynamodbattribute.MarshalMap(k)
+	if err != nil {
+		return false, errToFailure(err)
+	}
+	res, err := s.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.MetaTable),
+		Key:       av,
+	})
+	if err != nil {
+		return false, errToFailure(err)
+	}
+	if len(res.Item) == 0 {
+		return false, nil
+	}
+	if err = dynamodbattribute.UnmarshalMap(res.Item, out); err != nil {
+		return false, errToFailure(err)
+	}
+	return true, nil
+}
+
+// updateMeta applies the update to the record at k, creating it if absent, and unmarshals the updated record into out.
+// If cond is non-nil and fails, ok is false and out is untouched.
+func (s *Store) updateMeta(ctx context.Context, k metaKey, upd expression.UpdateBuilder, cond *expression.ConditionBuilder, out interface{}) (ok bool, err error) {
+	av, err := dynamodbattribute.MarshalMap(k)
+	if err != nil {
+		return false, errToFailure(err)
+	}
+	b := expression.NewBuilder().WithUpdate(upd)
+	if cond != nil {
+		b = b.WithCondition(*cond)
+	}
+	expr, err := b.Build()
+	if err != nil {
+		return false, errToFailure(err)
+	}
+	res, err := s.db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(s.MetaTable),
+		Key:                       av,
+		ConditionExpression:       expr.Condition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+		UpdateExpression:          expr.Update(),
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if aerr, isAWS := err.(awserr.Error); isAWS && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	} else if err != nil {
+		return false, errToFailure(err)
+	}
+	if out == nil {
+		return true, nil
+	}
+	if err = dynamodbattribute.UnmarshalMap(res.Attributes, out); err != nil {
+		return false, errToFailure(err)
+	}
+	return true, nil
+}
+
+// queryMeta reads every record of the kind pk into out, which must be a pointer to a slice
+func (s *Store) queryMeta(ctx context.Context, pk string, out interface{}) error {
+	items, err := s.queryMetaItems(ctx, pk)
+	if err != nil {
+		return err
+	}
+	if err = dynamodbattribute.UnmarshalListOfMaps(items, out); err != nil {
+		return errToFailure(err)
+	}
+	return nil
+}
+
+// queryMetaItems reads every record of the kind pk as it is stored
+func (s *Store) queryMetaItems(ctx context.Context, pk string) ([]map[string]*dynamodb.AttributeValue, error) {
+	expr, err := expression.NewBuilder().WithKeyCondition(
+		expression.Key("pk").Equal(expression.Value(pk)),
+	).Build()
+	if err != nil {
+		return nil, errToFailure(err)
+	}
+	items := make([]map[string]*dynamodb.AttributeValue, 0, 32)
+	err = s.db.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.MetaTable),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}, func(page *dynamodb.QueryOutput, last bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return nil, errToFailure(err)
+	}
+	return items, nil
+}
+
+// shardOf spreads records of a kind which are written with every stored report over n partitions by their id,
+// so no single partition takes every write
+func shardOf(id string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % uint32(n))
+}
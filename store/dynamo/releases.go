@@ -0,0 +1,49 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"go_report/domain"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+// Release records are spread over releaseShards partitions, as every stored report with a release updates its record
+const releaseShards = 16
+
+type releaseItem struct {
+	metaKey
+	domain.Release
+}
+
+func releasesPK(shard int) string {
+	return fmt.Sprintf("release#%d", shard)
+}
+
+func releaseKey(release string) metaKey {
+	return metaKey{PK: releasesPK(shardOf(release, releaseShards)), SK: release}
+}
+
+// countInRelease applies the stored report to its release's record
+func (s *Store) countInRelease(ctx context.Context, r domain.Report) error {
+	upd := expression.Set(expression.Name("release"), expression.Value(r.Release)).
+		Set(expression.Name("firstSeen"), expression.IfNotExists(expression.Name("firstSeen"), expression.Value(r.ReceivedOn))).
+		Set(expression.Name("lastSeen"), expression.Value(r.ReceivedOn)).
+		Add(expression.Name("count"), expression.Value(1))
+	_, err := s.updateMeta(ctx, releaseKey(r.Release), upd, nil, nil)
+	return err
+}
+
+func (s *Store) SelectReleases(ctx context.Context) ([]domain.Release, error) {
+	rels := make([]domain.Release, 0, 32)
+	for shard := 0; shard < releaseShards; shard++ {
+		items := make([]releaseItem, 0, 32)
+		if err := s.queryMeta(ctx, releasesPK(shard), &items); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			rels = append(rels, item.Release)
+		}
+	}
+	return rels, nil
+}
@@ -0,0 +1,128 @@
+package dynamo
+
+import (
+	"context"
+	"fmt"
+	"go_report/domain"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+// Rollups are partitioned by resolution and a shard of the group, and sorted by bucket so a window of buckets is
+// read with one query per shard. The sort key is "<bucket>#<gid>#<severity>", bucket formatted so that keys sort in
+// time order.
+const bucketLayout = "2006-01-02T15"
+
+// statShards spreads each resolution's counters over partitions, as every stored report increments them. A group's
+// counters are always in the same shard.
+const statShards = 16
+
+type statItem struct {
+	metaKey
+	domain.StatCount
+}
+
+func statsPK(res domain.Resolution, shard int) string {
+	return fmt.Sprintf("stats#%v#%d", res, shard)
+}
+
+func statShard(gid string) int {
+	return shardOf(gid, statShards)
+}
+
+func statKey(res domain.Resolution, bucket time.Time, gid string, sev domain.ReportType) metaKey {
+	return metaKey{PK: statsPK(res, statShard(gid)), SK: fmt.Sprintf("%v#%v#%d", bucket.Format(bucketLayout), gid, sev)}
+}
+
+// statUpdates count the report in each resolution's bucket, as the report is stored
+func (s *Store) statUpdates(r domain.Report) ([]*dynamodb.UpdateItemInput, error) {
+	items := make([]*dynamodb.UpdateItemInput, 0, len(domain.Resolutions))
+	for _, res := range domain.Resolutions {
+		bucket := res.Bucket(r.ReceivedOn)
+		av, err := dynamodbattribute.MarshalMap(statKey(res, bucket, r.GID, r.Severity))
+		if err != nil {
+			return nil, err
+		}
+		expr, err := expression.NewBuilder().WithUpdate(
+			expression.Set(expression.Name("bucket"), expression.Value(bucket)).
+				Set(expression.Name("gid"), expression.Value(r.GID)).
+				Set(expression.Name("severity"), expression.Value(r.Severity)).
+				Add(expression.Name("count"), expression.Value(1)),
+		).Build()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(s.MetaTable),
+			Key:                       av,
+			ExpressionAttributeNames:  expr.Names(),
+			ExpressionAttributeValues: expr.Values(),
+			UpdateExpression:          expr.Update(),
+		})
+	}
+	return items, nil
+}
+
+// countInStats applies the stored report to its counters, each on its own so reports never conflict on them
+func (s *Store) countInStats(ctx context.Context, r domain.Report) error {
+	upds, err := s.statUpdates(r)
+	if err != nil {
+		return errToFailure(err)
+	}
+	for _, upd := range upds {
+		if _, err := s.db.UpdateItemWithContext(ctx, upd); err != nil {
+			return errToFailure(err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) SelectStats(ctx context.Context, res domain.Resolution, from, to time.Time) ([]domain.StatCount, error) {
+	counts := make([]domain.StatCount, 0, 64)
+	for shard := 0; shard < statShards; shard++ {
+		c, err := s.selectStatShard(ctx, statsPK(res, shard), res, from, to)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, c...)
+	}
+	return counts, nil
+}
+
+func (s *Store) selectStatShard(ctx context.Context, pk string, res domain.Resolution, from, to time.Time) ([]domain.StatCount, error) {
+	// every key in the bucket `to` sorts before the bare start of the following bucket
+	lo, hi := res.Bucket(from).Format(bucketLayout), res.Bucket(to).Add(res.Duration()).Format(bucketLayout)
+	expr, err := expression.NewBuilder().WithKeyCondition(
+		expression.Key("pk").Equal(expression.Value(pk)).
+			And(expression.Key("sk").Between(expression.Value(lo), expression.Value(hi))),
+	).Build()
+	if err != nil {
+		return nil, errToFailure(err)
+	}
+	items := make([]map[string]*dynamodb.AttributeValue, 0, 64)
+	err = s.db.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:                 aws.String(s.MetaTable),
+		KeyConditionExpression:    expr.KeyCondition(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	}, func(page *dynamodb.QueryOutput, last bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		return nil, errToFailure(err)
+	}
+	stats := make([]statItem, 0, len(items))
+	if err = dynamodbattribute.UnmarshalListOfMaps(items, &stats); err != nil {
+		return nil, errToFailure(err)
+	}
+	counts := make([]domain.StatCount, 0, len(stats))
+	for _, item := range stats {
+		counts = append(counts, item.StatCount)
+	}
+	return counts, nil
+}
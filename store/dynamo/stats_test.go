@@ -0,0 +1,59 @@
+package dynamo
+
+import (
+	"go_report/domain"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatKey(t *testing.T) {
+	at := time.Date(2019, 8, 1, 13, 45, 0, 0, time.UTC)
+	tests := []struct {
+		res domain.Resolution
+		gid string
+		sk  string
+	}{
+		{domain.Hourly, "app", "2019-08-01T13#app#2"},
+		{domain.Daily, "app", "2019-08-01T00#app#2"},
+		{domain.Hourly, "sentry-web-0a1b", "2019-08-01T13#sentry-web-0a1b#2"},
+	}
+	for _, tt := range tests {
+		k := statKey(tt.res, tt.res.Bucket(at), tt.gid, domain.CrashType)
+		if k.SK != tt.sk {
+			t.Errorf("%v %v: got sk %q, want %q", tt.res, tt.gid, k.SK, tt.sk)
+		}
+		if want := statsPK(tt.res, statShard(tt.gid)); k.PK != want || !strings.HasPrefix(k.PK, "stats#"+string(tt.res)+"#") {
+			t.Errorf("%v %v: got pk %q, want %q", tt.res, tt.gid, k.PK, want)
+		}
+	}
+}
+
+func TestStatShardSpread(t *testing.T) {
+	seen := map[int]bool{}
+	for i := 0; i < 1000; i++ {
+		shard := statShard(strings.Repeat("g", i%50) + string(rune('a'+i%26)))
+		if shard < 0 || shard >= statShards {
+			t.Fatalf("shard %d out of range", shard)
+		}
+		seen[shard] = true
+	}
+	if len(seen) < statShards/2 {
+		t.Errorf("groups fell in only %d of %d shards", len(seen), statShards)
+	}
+}
+
+func TestStatUpdates(t *testing.T) {
+	items, err := new(Store).statUpdates(domain.Report{GID: "app", Severity: domain.BugType, ReceivedOn: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != len(domain.Resolutions) {
+		t.Fatalf("got %d updates, want one per resolution", len(items))
+	}
+	for _, item := range items {
+		if !strings.Contains(*item.UpdateExpression, "ADD") {
+			t.Errorf("update %v does not add to the count", item)
+		}
+	}
+}
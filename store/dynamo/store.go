@@ -1,46 +1,100 @@
 package dynamo
 
 import (
-	"bytes"
-	"crypto/md5"
-	"encoding/hex"
-	"encoding/json"
+	"context"
 	"go_report/domain"
 	"go_report/failure"
-	"log"
+	"go_report/logging"
+	"go_report/tracing"
 	"net/http"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/pkg/errors"
 )
 
 type Store struct {
-	db    *dynamodb.DynamoDB
-	log   *log.Logger
-	Table string
+	db        *dynamodb.DynamoDB
+	log       *logging.Logger
+	Table     string
+	MetaTable string // group metadata & other non-report records (see meta.go)
 }
 
-func New(sesh *session.Session, tableName string, logger *log.Logger) (s *Store) {
+func New(sesh *session.Session, tableName string, metaTableName string, logger *logging.Logger) (s *Store) {
 	s = new(Store)
-	s.Table, s.db, s.log = tableName, dynamodb.New(sesh), logger
+	s.Table, s.MetaTable, s.db, s.log = tableName, metaTableName, dynamodb.New(sesh), logger
+	s.trace()
 	return s
 }
 
-func (s *Store) NewEntry(r domain.Report) (rr domain.Receipt, err error) {
-	buf := new(bytes.Buffer)
-	if err = json.NewEncoder(buf).Encode(r); err != nil {
-		s.log.Fatal(err)
+type spanKey struct{}
+
+// trace makes each DynamoDB call, retries included, a client span of its context's trace (see tracing.StartKind).
+// Calls made with an untraced context record nothing.
+func (s *Store) trace() {
+	s.db.Handlers.Validate.PushFront(func(r *request.Request) {
+		ctx, span := tracing.StartKind(r.Context(), "dynamodb."+r.Operation.Name, tracing.Client,
+			"db.system", "dynamodb", "db.operation", r.Operation.Name)
+		if span != nil {
+			r.SetContext(context.WithValue(ctx, spanKey{}, span))
+		}
+	})
+	s.db.Handlers.Complete.PushBack(func(r *request.Request) {
+		if span, ok := r.Context().Value(spanKey{}).(*tracing.Span); ok {
+			span.EndErr(r.Error)
+		}
+	})
+}
+
+// NewEntry stores the report under its key (its content key if none is given), then counts it in the summaries of
+// its group, release & tags, and its stats. Each summary is updated on its own, so concurrent reports of the same
+// group or release never conflict. Only storing the report and counting it in its group fail the entry, as a retry
+// applies either once; the other counts are logged if they fail, rather than counted again by a retry.
+func (s *Store) NewEntry(ctx context.Context, r domain.Report) (rr domain.Receipt, err error) {
+	if err := r.ValidateGID(); err != nil { // i.e. a report spilled by an older version
+		return domain.Receipt{}, failure.New(err, http.StatusBadRequest, err.Error())
+	}
+	if rr, err = s.PutRecord(ctx, r); err != nil {
 		return domain.Receipt{}, err
 	}
-	r.Key = getMD5HashString(buf.Bytes())
+	r.Key = rr.Key
+	if rr.NewGroup, err = s.countInGroup(ctx, r); err != nil {
+		return domain.Receipt{}, err
+	}
+	logger := s.log.With("gid", r.GID, "key", r.Key)
+	if r.Release != "" {
+		if err := s.countInRelease(ctx, r); err != nil {
+			logger.Warn("failed to count report in its release", "release", r.Release, "err", err)
+		}
+	}
+	if err := s.countTags(ctx, r); err != nil {
+		logger.Warn("failed to index report's tags", "err", err)
+	}
+	if err := s.countInStats(ctx, r); err != nil {
+		logger.Warn("failed to count report in stats", "err", err)
+	}
+	return rr, nil
+}
+
+// PutRecord stores the report under its given key (or its content key if none is given) without any group
+// bookkeeping, for records which are not crash reports (i.e. certificates)
+func (s *Store) PutRecord(ctx context.Context, r domain.Report) (rr domain.Receipt, err error) {
+	if r.Key == "" {
+		if r.Key, err = s.contentKey(r); err != nil {
+			return domain.Receipt{}, err
+		}
+	}
 	av, err := dynamodbattribute.MarshalMap(r)
 	if err != nil {
 		return domain.Receipt{}, errToFailure(err)
 	}
-	_, err = s.db.PutItem(&dynamodb.PutItemInput{
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
 		Item:      av,
 		TableName: aws.String(s.Table),
 	})
@@ -50,12 +104,21 @@ func (s *Store) NewEntry(r domain.Report) (rr domain.Receipt, err error) {
 	return domain.Receipt{GID: r.GID, Key: r.Key}, nil
 }
 
-func (s *Store) Select(rr domain.Receipt) (*domain.Report, error) {
+// contentKey is the md5 hash of the report's json (see domain.Report.ContentKey)
+func (s *Store) contentKey(r domain.Report) (string, error) {
+	key, err := r.ContentKey()
+	if err != nil {
+		return "", errToFailure(err)
+	}
+	return key, nil
+}
+
+func (s *Store) Select(ctx context.Context, rr domain.Receipt) (*domain.Report, error) {
 	av, err := dynamodbattribute.MarshalMap(rr)
 	if err != nil {
 		return nil, errToFailure(err)
 	}
-	res, err := s.db.GetItem(&dynamodb.GetItemInput{
+	res, err := s.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(s.Table),
 		Key:       av,
 	})
@@ -69,11 +132,11 @@ func (s *Store) Select(rr domain.Receipt) (*domain.Report, error) {
 	return rpt, nil
 }
 
-func (s *Store) SelectAll() ([]domain.Report, error) {
+func (s *Store) SelectAll(ctx context.Context) ([]domain.Report, error) {
 	params := &dynamodb.ScanInput{
 		TableName: aws.String(s.Table),
 	}
-	res, err := s.db.Scan(params)
+	res, err := s.db.ScanWithContext(ctx, params)
 	if err != nil {
 		return nil, errToFailure(err)
 	}
@@ -90,12 +153,12 @@ func createGroupScanExpr(gid string) (expression.Expression, error) {
 	return expr, nil
 }
 
-func (s *Store) SelectGroup(gid string) ([]domain.Report, error) {
+func (s *Store) SelectGroup(ctx context.Context, gid string) ([]domain.Report, error) {
 	expr, err := createGroupScanExpr(gid)
 	if err != nil {
 		return nil, err
 	}
-	res, err := s.db.Scan(&dynamodb.ScanInput{
+	res, err := s.db.ScanWithContext(ctx, &dynamodb.ScanInput{
 		ExpressionAttributeNames:  expr.Names(),
 		ExpressionAttributeValues: expr.Values(),
 		FilterExpression:          expr.Filter(),
@@ -107,12 +170,12 @@ func (s *Store) SelectGroup(gid string) ([]domain.Report, error) {
 	return unmarshalListOfMapsResult(res)
 }
 
-func (s *Store) RemoveEntry(rr domain.Receipt) error {
+func (s *Store) RemoveEntry(ctx context.Context, rr domain.Receipt) error {
 	av, err := dynamodbattribute.MarshalMap(rr)
 	if err != nil {
 		return errToFailure(err)
 	}
-	_, err = s.db.DeleteItem(&dynamodb.DeleteItemInput{
+	_, err = s.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
 		Key:       av,
 		TableName: aws.String(s.Table),
 	})
@@ -122,10 +185,32 @@ func (s *Store) RemoveEntry(rr domain.Receipt) error {
 	return nil
 }
 
+// OnCall calls fn after every DynamoDB call the store makes (i.e. to record its latency)
+func (s *Store) OnCall(fn func(operation string, took time.Duration, err error)) {
+	s.db.Handlers.Complete.PushBack(func(r *request.Request) {
+		fn(r.Operation.Name, time.Since(r.Time), r.Error)
+	})
+}
+
+// Ping confirms both tables can be reached with the session's credentials
+func (s *Store) Ping(ctx context.Context) error {
+	for _, t := range []string{s.Table, s.MetaTable} {
+		if _, err := s.db.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(t)}); err != nil {
+			return errors.Wrapf(err, "could not describe table %v", t)
+		}
+	}
+	return nil
+}
+
 func errToFailure(err error) *failure.RequestFailure {
 	switch err.(type) {
 	case *dynamodbattribute.InvalidMarshalError:
 		return failure.New(err, http.StatusBadRequest, "")
+	case awserr.Error:
+		if err.(awserr.Error).Code() == "ValidationException" { // i.e. an item over the size limit, retrying cannot store it
+			return failure.New(err, http.StatusBadRequest, "")
+		}
+		return failure.New(err, http.StatusInternalServerError, "")
 	default:
 		switch code := err.Error(); code {
 		case dynamodb.ErrCodeIndexNotFoundException:
@@ -135,12 +220,6 @@ func errToFailure(err error) *failure.RequestFailure {
 	}
 }
 
-func getMD5HashString(text []byte) string {
-	hasher := md5.New()
-	hasher.Write(text)
-	return hex.EncodeToString(hasher.Sum(nil))
-}
-
 func unmarshalListOfMapsResult(res *dynamodb.ScanOutput) ([]domain.Report, error) {
 	rpts := make([]domain.Report, 0, 32)
 	if err := dynamodbattribute.UnmarshalListOfMaps(res.Items, &rpts); err != nil {
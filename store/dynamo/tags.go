@@ -0,0 +1,103 @@
+package dynamo
+
+import (
+	"context"
+	"go_report/domain"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+)
+
+const batchGetLimit = 100 // max keys per BatchGetItem request
+
+// each tag=value is indexed by a record pointing at the report, and counted by a facet record of the report's group
+type tagIndexItem struct {
+	metaKey
+	domain.Receipt
+}
+
+type facetItem struct {
+	metaKey
+	domain.Facet
+}
+
+func tagIndexKey(tag, value string, rr domain.Receipt) metaKey {
+	return metaKey{PK: "tag#" + tag + "=" + value, SK: rr.GID + "#" + rr.Key}
+}
+
+func facetKey(gid, tag, value string) metaKey {
+	return metaKey{PK: "facet#" + gid, SK: tag + "=" + value}
+}
+
+// countTags indexes and counts each of the stored report's tags
+func (s *Store) countTags(ctx context.Context, r domain.Report) error {
+	rr := domain.Receipt{GID: r.GID, Key: r.Key}
+	for t, v := range r.Tags {
+		idx, err := dynamodbattribute.MarshalMap(tagIndexItem{metaKey: tagIndexKey(t, v, rr), Receipt: rr})
+		if err != nil {
+			return errToFailure(err)
+		}
+		if _, err := s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{Item: idx, TableName: aws.String(s.MetaTable)}); err != nil {
+			return errToFailure(err)
+		}
+		upd := expression.Set(expression.Name("tag"), expression.Value(t)).
+			Set(expression.Name("value"), expression.Value(v)).
+			Add(expression.Name("count"), expression.Value(1))
+		if _, err := s.updateMeta(ctx, facetKey(r.GID, t, v), upd, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) SelectTagged(ctx context.Context, tag, value string) ([]domain.Report, error) {
+	idx := make([]tagIndexItem, 0, 32)
+	if err := s.queryMeta(ctx, tagIndexKey(tag, value, domain.Receipt{}).PK, &idx); err != nil {
+		return nil, err
+	}
+	rpts := make([]domain.Report, 0, len(idx))
+	for start := 0; start < len(idx); start += batchGetLimit {
+		end := start + batchGetLimit
+		if end > len(idx) {
+			end = len(idx)
+		}
+		keys := make([]map[string]*dynamodb.AttributeValue, 0, end-start)
+		for _, item := range idx[start:end] {
+			av, err := dynamodbattribute.MarshalMap(item.Receipt)
+			if err != nil {
+				return nil, errToFailure(err)
+			}
+			keys = append(keys, av)
+		}
+		items := make([]map[string]*dynamodb.AttributeValue, 0, len(keys))
+		err := s.db.BatchGetItemPagesWithContext(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]*dynamodb.KeysAndAttributes{s.Table: {Keys: keys}},
+		}, func(page *dynamodb.BatchGetItemOutput, last bool) bool {
+			items = append(items, page.Responses[s.Table]...)
+			return true
+		})
+		if err != nil {
+			return nil, errToFailure(err)
+		}
+		batch := make([]domain.Report, 0, len(items))
+		if err = dynamodbattribute.UnmarshalListOfMaps(items, &batch); err != nil {
+			return nil, errToFailure(err)
+		}
+		rpts = append(rpts, batch...) // reports removed since they were indexed are simply absent
+	}
+	return rpts, nil
+}
+
+func (s *Store) SelectFacets(ctx context.Context, gid string) ([]domain.Facet, error) {
+	items := make([]facetItem, 0, 32)
+	if err := s.queryMeta(ctx, facetKey(gid, "", "").PK, &items); err != nil {
+		return nil, err
+	}
+	facets := make([]domain.Facet, 0, len(items))
+	for _, item := range items {
+		facets = append(facets, item.Facet)
+	}
+	return facets, nil
+}
@@ -0,0 +1,138 @@
+package dynamo
+
+import (
+	"context"
+	"go_report/domain"
+	"go_report/failure"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/expression"
+	"github.com/pkg/errors"
+)
+
+type webhookItem struct {
+	metaKey
+	domain.Webhook
+}
+
+// deliveryItem expires by the meta table's TTL attribute "ttl", see idempotencyItem
+type deliveryItem struct {
+	metaKey
+	domain.Delivery
+	TTL int64 `json:"ttl"` // unix seconds
+}
+
+func webhookKey(id string) metaKey {
+	return metaKey{PK: "webhook", SK: id}
+}
+
+func deliveryKey(hookID, id string) metaKey {
+	return metaKey{PK: "delivery#" + hookID, SK: id}
+}
+
+func (s *Store) NewWebhook(ctx context.Context, h domain.Webhook) (domain.Webhook, error) {
+	h.ID = domain.NewCommentID(h.CreatedOn)
+	av, err := dynamodbattribute.MarshalMap(webhookItem{metaKey: webhookKey(h.ID), Webhook: h})
+	if err != nil {
+		return domain.Webhook{}, errToFailure(err)
+	}
+	cond, err := expression.NewBuilder().WithCondition(expression.AttributeNotExists(expression.Name("pk"))).Build()
+	if err != nil {
+		return domain.Webhook{}, errToFailure(err)
+	}
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item:                      av,
+		TableName:                 aws.String(s.MetaTable),
+		ConditionExpression:       cond.Condition(),
+		ExpressionAttributeNames:  cond.Names(),
+		ExpressionAttributeValues: cond.Values(),
+	})
+	if err != nil {
+		return domain.Webhook{}, errToFailure(err)
+	}
+	return h, nil
+}
+
+func (s *Store) SelectWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	item := new(webhookItem)
+	if found, err := s.getMeta(ctx, webhookKey(id), item); err != nil || !found {
+		return nil, err
+	}
+	return &item.Webhook, nil
+}
+
+func (s *Store) SelectWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	items := make([]webhookItem, 0, 8)
+	if err := s.queryMeta(ctx, webhookKey("").PK, &items); err != nil {
+		return nil, err
+	}
+	hooks := make([]domain.Webhook, 0, len(items))
+	for _, item := range items {
+		hooks = append(hooks, item.Webhook)
+	}
+	return hooks, nil
+}
+
+func (s *Store) RemoveWebhook(ctx context.Context, id string) error {
+	av, err := dynamodbattribute.MarshalMap(webhookKey(id))
+	if err != nil {
+		return errToFailure(err)
+	}
+	cond, err := expression.NewBuilder().WithCondition(expression.AttributeExists(expression.Name("pk"))).Build()
+	if err != nil {
+		return errToFailure(err)
+	}
+	_, err = s.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(s.MetaTable),
+		Key:                       av,
+		ConditionExpression:       cond.Condition(),
+		ExpressionAttributeNames:  cond.Names(),
+		ExpressionAttributeValues: cond.Values(),
+	})
+	if aerr, isAWS := err.(awserr.Error); isAWS && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return failure.New(errors.Errorf("no webhook %v", id), http.StatusNotFound, "webhook not found")
+	} else if err != nil {
+		return errToFailure(err)
+	}
+	return nil
+}
+
+func (s *Store) SaveDelivery(ctx context.Context, d domain.Delivery) error {
+	item := deliveryItem{metaKey: deliveryKey(d.WebhookID, d.ID), Delivery: d, TTL: d.CreatedOn.Add(domain.DeliveryRetention).Unix()}
+	av, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return errToFailure(err)
+	}
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item:      av,
+		TableName: aws.String(s.MetaTable),
+	})
+	if err != nil {
+		return errToFailure(err)
+	}
+	return nil
+}
+
+func (s *Store) SelectDelivery(ctx context.Context, hookID, id string) (*domain.Delivery, error) {
+	item := new(deliveryItem)
+	if found, err := s.getMeta(ctx, deliveryKey(hookID, id), item); err != nil || !found {
+		return nil, err
+	}
+	return &item.Delivery, nil
+}
+
+func (s *Store) SelectDeliveries(ctx context.Context, hookID string) ([]domain.Delivery, error) {
+	items := make([]deliveryItem, 0, 32)
+	if err := s.queryMeta(ctx, deliveryKey(hookID, "").PK, &items); err != nil {
+		return nil, err
+	}
+	deliveries := make([]domain.Delivery, 0, len(items))
+	for _, item := range items {
+		deliveries = append(deliveries, item.Delivery)
+	}
+	return deliveries, nil
+}
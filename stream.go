@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go_report/domain"
+	"go_report/failure"
+	"go_report/stream"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	streamHeartbeat = 15 * time.Second
+	streamRetry     = 3 * time.Second // reconnection delay suggested to clients
+)
+
+// StreamHandler pushes each newly stored report as a server-sent event, optionally only those whose GID starts
+// with ?gid=... and whose severity is ?severity=.... Clients resume from the Last-Event-ID header (or ?lastEventID=...);
+// if that is no longer possible (the server restarted, the client reconnected to another instance, or it was gone
+// too long) they are sent a reset event first, and should reload the reports they show.
+func StreamHandler(b *stream.Broker) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			failure.Fail(w, r, failure.New(errors.New("response writer cannot flush"), http.StatusInternalServerError, "streaming unsupported"))
+			return
+		}
+		prefix, severity := r.URL.Query().Get("gid"), r.URL.Query().Get("severity")
+		match := func(rpt domain.Report) bool {
+			if !strings.HasPrefix(rpt.GID, prefix) {
+				return false
+			}
+			return severity == "" || rpt.Severity == domain.ConvertSeverityLevelString(severity)
+		}
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("lastEventID")
+		}
+
+		missed, events, cancel := b.Subscribe(lastID)
+		defer cancel()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no") // disable proxy buffering
+		w.WriteHeader(http.StatusOK)
+		if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry/time.Millisecond); err != nil {
+			return
+		}
+		for _, e := range missed {
+			if e.Reset || match(e.Report) {
+				if err := writeEvent(w, b, e); err != nil {
+					return
+				}
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, open := <-events:
+				if !open {
+					return // fell behind, the client resumes from its last event
+				}
+				if !match(e.Report) {
+					continue
+				}
+				if err := writeEvent(w, b, e); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+func writeEvent(w http.ResponseWriter, b *stream.Broker, e stream.Event) error {
+	if e.Reset {
+		_, err := fmt.Fprintf(w, "id: %v\nevent: reset\ndata: {}\n\n", b.EventID(e))
+		return err
+	}
+	data, err := json.Marshal(e.Report)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %v\nevent: report\ndata: %s\n\n", b.EventID(e), data)
+	return err
+}
@@ -0,0 +1,136 @@
+package stream
+
+import (
+	"fmt"
+	"go_report/domain"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultHistory    = 256 // events kept for subscribers resuming with a Last-Event-ID
+	subscriberBacklog = 64  // events buffered per subscriber before it is considered too slow
+)
+
+// Event is a newly stored report, numbered in order of publication
+type Event struct {
+	ID     uint64
+	Report domain.Report
+	Reset  bool // not a report: the subscriber could not resume, so it may have missed reports (see Subscribe)
+}
+
+// Broker broadcasts newly stored reports to every subscriber (i.e. live tails) in this process. Only the reports
+// stored by this process are published, so with several instances each tail follows the instance it is connected to.
+// Event ids are "<epoch>-<number>", the epoch being the broker's start, so an id from an earlier start of the
+// process or from another instance is never mistaken for a position in this one.
+type Broker struct {
+	epoch   string
+	lock    sync.Mutex
+	subs    map[chan Event]struct{}
+	history []Event // ring buffer of the most recent events
+	next    int     // index in history of the next event
+	lastID  uint64
+	closed  bool
+}
+
+func NewBroker(history int) *Broker {
+	if history < 1 {
+		history = DefaultHistory
+	}
+	return &Broker{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		subs:    map[chan Event]struct{}{},
+		history: make([]Event, 0, history),
+	}
+}
+
+// Publish numbers the report and sends it to all subscribers. Subscribers which have fallen too far behind are
+// dropped (their channel closed) rather than blocking ingestion; they may resubscribe from the last event they saw.
+func (b *Broker) Publish(rpt domain.Report) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.lastID++
+	e := Event{ID: b.lastID, Report: rpt}
+	if len(b.history) < cap(b.history) {
+		b.history = append(b.history, e)
+	} else {
+		b.history[b.next] = e
+	}
+	b.next = (b.next + 1) % cap(b.history)
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// EventID is the id of the event given to subscribers, to resume after it
+func (b *Broker) EventID(e Event) string {
+	return fmt.Sprintf("%v-%d", b.epoch, e.ID)
+}
+
+// Subscribe returns the retained events published after lastID (an EventID, or "" for none), and a channel of events
+// published from now on. If lastID cannot be resumed from (it is from another epoch, or older than every retained
+// event) missed is a single Reset event, numbered as the last event published. The channel is closed if the
+// subscriber falls behind; cancel must be called once the subscriber is done.
+func (b *Broker) Subscribe(lastID string) (missed []Event, events <-chan Event, cancel func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if lastID != "" {
+		missed = b.since(lastID)
+	}
+	ch := make(chan Event, subscriberBacklog)
+	if b.closed {
+		close(ch)
+		return missed, ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+	return missed, ch, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// since returns the retained events after lastID, or a Reset event if some may no longer be retained
+func (b *Broker) since(lastID string) []Event {
+	reset := []Event{{ID: b.lastID, Reset: true}}
+	epoch, n := "", ""
+	if i := strings.LastIndex(lastID, "-"); i >= 0 {
+		epoch, n = lastID[:i], lastID[i+1:]
+	}
+	id, err := strconv.ParseUint(n, 10, 64)
+	if err != nil || epoch != b.epoch || id > b.lastID {
+		return reset
+	}
+	var missed []Event
+	for i := 0; i < len(b.history); i++ {
+		e := b.history[(b.next+i)%len(b.history)] // oldest first
+		if i == 0 && e.ID > id+1 {
+			return reset // the events after lastID have been overwritten
+		}
+		if e.ID > id {
+			missed = append(missed, e)
+		}
+	}
+	return missed
+}
+
+// Close ends every subscription (i.e. at shutdown, so live tails do not hold the server open), and any made later
+func (b *Broker) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
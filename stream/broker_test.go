@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"go_report/domain"
+	"reflect"
+	"testing"
+)
+
+func TestSubscribeMissed(t *testing.T) {
+	tests := []struct {
+		name      string
+		history   int
+		published int
+		lastID    uint64
+		want      []uint64
+	}{
+		{"up to date", 4, 3, 3, nil},
+		{"history not full", 4, 3, 1, []uint64{2, 3}},
+		{"history full", 4, 4, 1, []uint64{2, 3, 4}},
+		{"history wrapped", 4, 6, 2, []uint64{3, 4, 5, 6}},
+		{"wrapped, recent", 3, 7, 5, []uint64{6, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBroker(tt.history)
+			for i := 0; i < tt.published; i++ {
+				b.Publish(domain.Report{GID: "g"})
+			}
+			missed, _, cancel := b.Subscribe(b.EventID(Event{ID: tt.lastID}))
+			defer cancel()
+			var got []uint64
+			for _, e := range missed {
+				if e.Reset {
+					t.Fatalf("reset at %v", e.ID)
+				}
+				got = append(got, e.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribeReset(t *testing.T) {
+	b := NewBroker(4)
+	for i := 0; i < 10; i++ {
+		b.Publish(domain.Report{GID: "g"})
+	}
+	other := NewBroker(4)
+	other.epoch = "earlier"
+	tests := []struct {
+		name   string
+		lastID string
+	}{
+		{"older than history", b.EventID(Event{ID: 5})},
+		{"earlier epoch", other.EventID(Event{ID: 9})},
+		{"ahead of the broker", b.EventID(Event{ID: 11})},
+		{"unversioned id", "9"},
+		{"garbage", "x-y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missed, _, cancel := b.Subscribe(tt.lastID)
+			defer cancel()
+			if len(missed) != 1 || !missed[0].Reset || missed[0].ID != 10 {
+				t.Errorf("missed %+v, want a reset at 10", missed)
+			}
+		})
+	}
+	if missed, _, cancel := b.Subscribe(""); len(missed) != 0 {
+		t.Errorf("new subscriber missed %+v", missed)
+	} else {
+		cancel()
+	}
+}
+
+func TestPublishDropsSlowSubscribers(t *testing.T) {
+	b := NewBroker(DefaultHistory)
+	_, slow, cancelSlow := b.Subscribe("")
+	defer cancelSlow()
+	_, fast, cancelFast := b.Subscribe("")
+	defer cancelFast()
+	for i := 0; i < subscriberBacklog+1; i++ {
+		b.Publish(domain.Report{GID: "g"})
+		if i < subscriberBacklog {
+			if e := <-fast; e.ID != uint64(i+1) {
+				t.Fatalf("fast subscriber got event %v, want %v", e.ID, i+1)
+			}
+		}
+	}
+	for i := 0; i < subscriberBacklog; i++ {
+		<-slow
+	}
+	if _, ok := <-slow; ok {
+		t.Error("slow subscriber was not dropped")
+	}
+	if e, ok := <-fast; !ok || e.ID != subscriberBacklog+1 {
+		t.Errorf("fast subscriber got %v (open %v), want event %v", e.ID, ok, subscriberBacklog+1)
+	}
+}
+
+func TestClose(t *testing.T) {
+	b := NewBroker(DefaultHistory)
+	_, before, cancel := b.Subscribe("")
+	b.Close()
+	if _, ok := <-before; ok {
+		t.Error("subscription was not closed")
+	}
+	cancel() // after Close, does nothing
+	_, after, _ := b.Subscribe("")
+	if _, ok := <-after; ok {
+		t.Error("subscription after Close was not closed")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"go_report/domain"
+	"go_report/failure"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const defaultTopFacets = 10
+
+// GetFacetsHandler returns the most frequent values of each tag in the group in context, ?top=N values per tag
+func GetFacetsHandler(s domain.TagStorer) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gid := r.Context().Value(string(ReportGIDVar)).(string)
+		top := defaultTopFacets
+		if q := r.URL.Query().Get("top"); q != "" {
+			n, err := strconv.Atoi(q)
+			if err != nil || n < 1 {
+				failure.Fail(w, r, failure.New(errors.Errorf("invalid top %v", q), http.StatusBadRequest, "top must be a positive integer"))
+				return
+			}
+			top = n
+		}
+		facets, err := s.SelectFacets(r.Context(), gid)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(domain.TopFacets(facets, top)); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode facets json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// tagFilters reads the ?tag=name=value query params of a listing request
+func tagFilters(r *http.Request) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, pair := range r.URL.Query()["tag"] {
+		t, v, ok := domain.ParseTag(pair)
+		if !ok {
+			return nil, failure.New(errors.Errorf("invalid tag filter %v", pair), http.StatusBadRequest, "tag filters must be of the form tag=name=value")
+		}
+		tags[t] = v
+	}
+	return tags, nil
+}
+
+// selectTagged uses the index of one tag to find candidates, keeping those which carry every tag given
+func selectTagged(ctx context.Context, s domain.TagStorer, tags map[string]string) ([]domain.Report, error) {
+	var candidates []domain.Report
+	var err error
+	for t, v := range tags {
+		if candidates, err = s.SelectTagged(ctx, t, v); err != nil {
+			return nil, err
+		}
+		break
+	}
+	reports := make([]domain.Report, 0, len(candidates))
+	for _, rpt := range candidates {
+		if rpt.HasTags(tags) {
+			reports = append(reports, rpt)
+		}
+	}
+	return reports, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"go_report/logging"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CertReloader serves the certificate & key files, reloading them when either file changes, so renewed
+// certificates are served without a restart
+type CertReloader struct {
+	certFile, keyFile string
+	log               *logging.Logger
+
+	lock     sync.RWMutex
+	cert     *tls.Certificate
+	modified time.Time // latest modification time of the files, as loaded
+}
+
+// NewCertReloader loads the pem encoded certificate (chain) & key files
+func NewCertReloader(certFile, keyFile string, logger *logging.Logger) (*CertReloader, error) {
+	c := &CertReloader{certFile: certFile, keyFile: keyFile, log: logger}
+	modified, err := c.modTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.load(modified); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *CertReloader) modTime() (time.Time, error) {
+	var latest time.Time
+	for _, f := range []string{c.certFile, c.keyFile} {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return latest, errors.Wrap(err, "could not read tls certificate")
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (c *CertReloader) load(modified time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "could not load tls certificate")
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return errors.Wrap(err, "could not parse tls certificate")
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cert, c.modified = &cert, modified
+	return nil
+}
+
+// GetCertificate serves the latest certificate, see tls.Config
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.cert, nil
+}
+
+// Watch checks the files every interval until stop is closed, reloading them once changed. A certificate which
+// fails to load (i.e. the key is written after the certificate) is retried, while the previous one is served.
+func (c *CertReloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+		}
+		modified, err := c.modTime()
+		c.lock.RLock()
+		changed := err == nil && !modified.Equal(c.modified)
+		c.lock.RUnlock()
+		if err != nil {
+			c.log.Warn("could not check tls certificate for changes", "err", err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		if err := c.load(modified); err != nil {
+			c.log.Warn("could not reload changed tls certificate, still serving the previous one", "err", err)
+			continue
+		}
+		c.lock.RLock()
+		leaf := c.cert.Leaf
+		c.lock.RUnlock()
+		c.log.Info("reloaded tls certificate", "subject", leaf.Subject.CommonName, "notAfter", leaf.NotAfter)
+	}
+}
+
+// StartTLS returns the tls config of the cert & key files, reloading them every reloadInterval (a go duration) until
+// stop is called, or a nil config to serve plain http if the cert file is "none"
+func StartTLS(certFile, keyFile, clientCAFile, reloadInterval string, logger *logging.Logger) (cfg *tls.Config, stop func(), err error) {
+	if strings.EqualFold(certFile, "none") {
+		if !strings.EqualFold(clientCAFile, "none") {
+			return nil, nil, errors.New("client certificates need tls, set TLS_CERT_FILE & TLS_KEY_FILE")
+		}
+		return nil, func() {}, nil
+	}
+	interval, err := time.ParseDuration(reloadInterval)
+	if err != nil || interval <= 0 {
+		return nil, nil, errors.Errorf("invalid TLS_RELOAD_INTERVAL %q", reloadInterval)
+	}
+	certs, err := NewCertReloader(certFile, keyFile, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg, err = TLSConfig(certs, clientCAFile); err != nil {
+		return nil, nil, err
+	}
+	done := make(chan struct{})
+	var once sync.Once
+	go certs.Watch(interval, done)
+	return cfg, func() { once.Do(func() { close(done) }) }, nil
+}
+
+// TLSConfig serves the reloader's certificate. With a client CA file (pem), clients may present a certificate
+// issued by one of its CAs, which auth.Service.ClientCertificate accepts in place of an app jwt; the handshake fails
+// for certificates it did not issue.
+func TLSConfig(certs *CertReloader, clientCAFile string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+	if clientCAFile == "" || strings.EqualFold(clientCAFile, "none") {
+		return cfg, nil
+	}
+	pem, err := ioutil.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read client CA file")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("no pem certificates in client CA file %v", clientCAFile)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven // developers & browsers have no client certificate
+	return cfg, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"go_report/logging"
+	"go_report/tracing"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewTracer exports spans to the OTLP/HTTP collector at endpoint, sampling traces without a sampled parent at
+// sampleRatio, or traces nothing (a nil tracer) if endpoint is "none". Its provider, if any, must be shut down to
+// flush the last spans.
+func NewTracer(endpoint, sampleRatio string, logger *logging.Logger) (trace.Tracer, *sdktrace.TracerProvider, error) {
+	if strings.EqualFold(endpoint, "none") || endpoint == "" {
+		return nil, nil, nil
+	}
+	ratio, err := strconv.ParseFloat(sampleRatio, 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return nil, nil, errors.Errorf("invalid TRACE_SAMPLE_RATIO %q, want 0..1", sampleRatio)
+	}
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, nil, errors.Errorf("invalid OTLP_ENDPOINT %q, want http(s)://host:port or none", endpoint)
+	}
+	exp, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/")+"/v1/traces"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not create OTLP exporter")
+	}
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("could not export spans", "err", err) // traces are best effort
+	}))
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("go_report"))),
+	)
+	return tp.Tracer("go_report"), tp, nil
+}
+
+// Trace returns a middleware which makes each request a server span, continuing the client's trace if it sent a W3C
+// traceparent header. The span is named for the chi route pattern once the request is routed. A nil tracer
+// traces nothing.
+func Trace(tracer trace.Tracer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if tracer == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			target := r.URL.Path
+			if r.URL.RawQuery != "" {
+				target += "?" + redactedQuery(r.URL)
+			}
+			attrs := []interface{}{"http.method", r.Method, "http.target", target, "http.request_id", middleware.GetReqID(r.Context())}
+			ctx := tracing.ContextWithTraceParent(r.Context(), r.Header.Get("traceparent"))
+			ctx, span := tracing.StartWith(ctx, tracer, r.Method, tracing.Server, attrs...)
+			defer span.End()
+			logging.AddFields(ctx, "trace_id", span.TraceID())
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+				route := strings.Replace(rctx.RoutePattern(), "//", "/", -1)
+				span.SetName(r.Method + " " + route)
+				span.SetAttributes("http.route", route)
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes("http.status_code", status)
+			if status >= 500 {
+				span.SetError(errors.New(http.StatusText(status)))
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Kind is the span's role in its trace
+type Kind = trace.SpanKind
+
+const (
+	Internal = trace.SpanKindInternal
+	Server   = trace.SpanKindServer
+	Client   = trace.SpanKindClient
+	Consumer = trace.SpanKindConsumer // of a job queued by another span
+)
+
+// instrumentation names the tracer of go_report's spans
+const instrumentation = "go_report"
+
+// Span is an OpenTelemetry span, started with key/value pair attributes. A nil Span does nothing, so callers need
+// not check whether the request is traced.
+type Span struct {
+	span trace.Span
+}
+
+// Start starts a child of the context's span, or returns a nil span if the context is not traced
+func Start(ctx context.Context, name string, kv ...interface{}) (context.Context, *Span) {
+	return StartKind(ctx, name, Internal, kv...)
+}
+
+// StartKind is Start, for spans which are not internal (i.e. client calls to another service)
+func StartKind(ctx context.Context, name string, kind Kind, kv ...interface{}) (context.Context, *Span) {
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return ctx, nil
+	}
+	return StartWith(ctx, parent.TracerProvider().Tracer(instrumentation), name, kind, kv...)
+}
+
+// StartWith starts a span of tracer: a child of the context's span, of its remote parent (see
+// ContextWithTraceParent), or else the root of a new trace
+func StartWith(ctx context.Context, tracer trace.Tracer, name string, kind Kind, kv ...interface{}) (context.Context, *Span) {
+	ctx, s := tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attributes(kv)...))
+	return ctx, &Span{s}
+}
+
+// TraceID is the hex id of the span's trace, or "" for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.span.SpanContext().TraceID().String()
+}
+
+// SetName renames the span, i.e. once the router has matched the request's route
+func (s *Span) SetName(name string) {
+	if s != nil {
+		s.span.SetName(name)
+	}
+}
+
+// SetAttributes adds key/value pairs to the span
+func (s *Span) SetAttributes(kv ...interface{}) {
+	if s != nil {
+		s.span.SetAttributes(attributes(kv)...)
+	}
+}
+
+// SetError marks the span as failed with err, if it is not nil
+func (s *Span) SetError(err error) {
+	if s != nil && err != nil {
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// End records the span's end. Later calls do nothing.
+func (s *Span) End() {
+	if s != nil {
+		s.span.End()
+	}
+}
+
+// EndErr ends the span, failed if err is not nil (i.e. defer func() { span.EndErr(err) }())
+func (s *Span) EndErr(err error) {
+	s.SetError(err)
+	s.End()
+}
+
+// ContextWithTraceParent returns ctx with the remote span of a W3C traceparent header (i.e. a client's), the parent
+// of spans started with StartWith. An invalid header is ignored.
+func ContextWithTraceParent(ctx context.Context, traceparent string) context.Context {
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
+
+// TraceParentFromContext formats the context's span as a traceparent header, or returns "" if it has none
+func TraceParentFromContext(ctx context.Context) string {
+	c := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, c)
+	return c["traceparent"]
+}
+
+func attributes(kv []interface{}) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		k := fmt.Sprint(kv[i])
+		switch v := kv[i+1].(type) {
+		case bool:
+			attrs = append(attrs, attribute.Bool(k, v))
+		case int:
+			attrs = append(attrs, attribute.Int(k, v))
+		case int64:
+			attrs = append(attrs, attribute.Int64(k, v))
+		case float64:
+			attrs = append(attrs, attribute.Float64(k, v))
+		default:
+			attrs = append(attrs, attribute.String(k, fmt.Sprint(v)))
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,33 @@
+package tracing
+
+import (
+	"net/http"
+)
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// Transport makes each call through next a client span of the request context's trace, named for the peer
+// (i.e. "github GET"). The traceparent header is not sent, as peers are outside the trace.
+func Transport(next http.RoundTripper, peer string) http.RoundTripper {
+	return roundTripper(func(r *http.Request) (*http.Response, error) {
+		_, span := StartKind(r.Context(), peer+" "+r.Method, Client,
+			"http.method", r.Method, "http.host", r.URL.Host, "http.target", r.URL.Path)
+		rsp, err := next.RoundTrip(r)
+		if err == nil {
+			span.SetAttributes("http.status_code", rsp.StatusCode)
+			if rsp.StatusCode >= 400 {
+				span.SetError(&statusError{rsp.Status})
+			}
+		}
+		span.EndErr(err)
+		return rsp, err
+	})
+}
+
+type statusError struct{ status string }
+
+func (e *statusError) Error() string { return e.status }
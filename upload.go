@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"go_report/domain"
+	"go_report/failure"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// BodyLimits bound the size & shape of uploaded reports
+type BodyLimits struct {
+	MaxUpload int64 // bytes, as sent (compressed)
+	MaxReport int64 // bytes, once decompressed; at most domain.MaxStoredReport
+	MaxDepth  int   // nesting of json objects & arrays
+}
+
+var DefaultBodyLimits = BodyLimits{
+	MaxUpload: 1 << 20,
+	MaxReport: domain.MaxStoredReport,
+	MaxDepth:  32,
+}
+
+// ParseBodyLimits reads limits from config values, using the default for any which is empty. Each must otherwise be
+// a positive int, and MaxReport at most domain.MaxStoredReport, as larger reports could not be stored.
+func ParseBodyLimits(maxUpload, maxReport, maxDepth string) (BodyLimits, error) {
+	l := DefaultBodyLimits
+	var err error
+	if maxUpload != "" {
+		if l.MaxUpload, err = strconv.ParseInt(maxUpload, 10, 64); err != nil || l.MaxUpload <= 0 {
+			return l, errors.Errorf("invalid MAX_UPLOAD_BYTES %q", maxUpload)
+		}
+	}
+	if maxReport != "" {
+		if l.MaxReport, err = strconv.ParseInt(maxReport, 10, 64); err != nil || l.MaxReport <= 0 {
+			return l, errors.Errorf("invalid MAX_REPORT_BYTES %q", maxReport)
+		}
+		if l.MaxReport > domain.MaxStoredReport {
+			return l, errors.Errorf("MAX_REPORT_BYTES %q is over %d, the largest report which can be stored", maxReport, domain.MaxStoredReport)
+		}
+	}
+	if maxDepth != "" {
+		if l.MaxDepth, err = strconv.Atoi(maxDepth); err != nil || l.MaxDepth <= 0 {
+			return l, errors.Errorf("invalid MAX_JSON_DEPTH %q", maxDepth)
+		}
+	}
+	return l, nil
+}
+
+var errTooLarge = errors.New("request body too large")
+
+// readLimited reads all of rd, failing with errTooLarge if it holds more than max bytes
+func readLimited(rd io.Reader, max int64) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(rd, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > max {
+		return nil, errTooLarge
+	}
+	return b, nil
+}
+
+// ReadBody reads the request body within the limits, decompressing it per its Content-Encoding (gzip or deflate),
+// and checks it is json nested no deeper than allowed. Errors are RequestFailures (400, 413 or 415).
+func (l BodyLimits) ReadBody(r *http.Request) ([]byte, error) {
+	b, err := l.ReadRaw(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.CheckJSON(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ReadRaw is ReadBody, for bodies which are not (only) json
+func (l BodyLimits) ReadRaw(r *http.Request) ([]byte, error) {
+	if r.ContentLength > l.MaxUpload {
+		return nil, failure.New(errTooLarge, http.StatusRequestEntityTooLarge, "Request body is too large")
+	}
+	raw, err := readLimited(r.Body, l.MaxUpload)
+	if err == errTooLarge {
+		return nil, failure.New(err, http.StatusRequestEntityTooLarge, "Request body is too large")
+	} else if err != nil {
+		return nil, failure.New(err, http.StatusBadRequest, "Could not read request body")
+	}
+	var rd io.Reader
+	switch enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); enc {
+	case "", "identity":
+		rd = bytes.NewReader(raw)
+	case "gzip", "x-gzip":
+		if rd, err = gzip.NewReader(bytes.NewReader(raw)); err != nil {
+			return nil, failure.New(err, http.StatusBadRequest, "Could not decompress gzip request body")
+		}
+	case "deflate":
+		// "deflate" should be zlib wrapped, but some clients send a raw deflate stream
+		if rd, err = zlib.NewReader(bytes.NewReader(raw)); err != nil {
+			rd = flate.NewReader(bytes.NewReader(raw))
+		}
+	default:
+		return nil, failure.New(errors.Errorf("unsupported content encoding %q", enc), http.StatusUnsupportedMediaType, "Content-Encoding must be gzip or deflate")
+	}
+	b, err := readLimited(rd, l.MaxReport)
+	if err == errTooLarge {
+		return nil, failure.New(err, http.StatusRequestEntityTooLarge, "Decompressed request body is too large")
+	} else if err != nil {
+		return nil, failure.New(err, http.StatusBadRequest, "Could not decompress request body")
+	}
+	return b, nil
+}
+
+// CheckJSON fails with a 400 RequestFailure if b is not json nested no deeper than allowed
+func (l BodyLimits) CheckJSON(b []byte) error {
+	if err := checkJSONDepth(b, l.MaxDepth); err != nil {
+		return failure.New(err, http.StatusBadRequest, err.Error())
+	}
+	return nil
+}
+
+// checkJSONDepth fails if b is not json, or nests objects & arrays more than max deep
+func checkJSONDepth(b []byte, max int) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err == io.EOF && depth > 0 {
+			return errors.New("Request body is not valid json: unexpected end of input")
+		} else if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "Request body is not valid json")
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			if depth++; depth > max {
+				return errors.Errorf("Request body json is nested more than %d deep", max)
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"go_report/domain"
+	"go_report/failure"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func compress(t *testing.T, enc string, b []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch enc {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		var err error
+		if w, err = flate.NewWriter(&buf, flate.DefaultCompression); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		return b
+	}
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func nested(depth int) string {
+	return strings.Repeat(`{"a":`, depth) + "1" + strings.Repeat("}", depth)
+}
+
+func TestReadBody(t *testing.T) {
+	limits := BodyLimits{MaxUpload: 256, MaxReport: 1024, MaxDepth: 4}
+	big := `{"a":"` + strings.Repeat("x", 1100) + `"}` // compresses below MaxUpload, but decompresses above MaxReport
+	tests := []struct {
+		name     string
+		encoding string // Content-Encoding header
+		compress string // how the body is compressed
+		body     string
+		want     int // status of the failure, 0 for none
+	}{
+		{"plain", "", "", `{"a":1}`, 0},
+		{"identity", "identity", "", `{"a":1}`, 0},
+		{"gzip", "gzip", "gzip", `{"a":1}`, 0},
+		{"x-gzip", "X-Gzip", "gzip", `{"a":1}`, 0},
+		{"zlib deflate", "deflate", "zlib", `{"a":1}`, 0},
+		{"raw deflate", "deflate", "flate", `{"a":1}`, 0},
+		{"unsupported encoding", "br", "", `{"a":1}`, http.StatusUnsupportedMediaType},
+		{"corrupt gzip", "gzip", "", `{"a":1}`, http.StatusBadRequest},
+		{"upload too large", "", "", `{"a":"` + strings.Repeat("x", 300) + `"}`, http.StatusRequestEntityTooLarge},
+		{"decompressed too large", "gzip", "gzip", big, http.StatusRequestEntityTooLarge},
+		{"not json", "", "", `{"a" 1}`, http.StatusBadRequest},
+		{"truncated json", "", "", `{"a":[1`, http.StatusBadRequest},
+		{"as deep as allowed", "", "", nested(4), 0},
+		{"too deep", "", "", nested(5), http.StatusBadRequest},
+		{"too deep in arrays", "", "", `[[[[[1]]]]]`, http.StatusBadRequest},
+		{"too deep once decompressed", "gzip", "gzip", nested(5), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/report/", bytes.NewReader(compress(t, tt.compress, []byte(tt.body))))
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+			b, err := limits.ReadBody(r)
+			if tt.want == 0 {
+				if err != nil {
+					t.Fatalf("failed: %v", err)
+				}
+				if string(b) != tt.body {
+					t.Errorf("read %q, want %q", b, tt.body)
+				}
+				return
+			}
+			rf, ok := errors.Cause(err).(*failure.RequestFailure)
+			if !ok {
+				t.Fatalf("got %v, want a failure with status %v", err, tt.want)
+			}
+			if rf.Code != tt.want {
+				t.Errorf("got status %v, want %v", rf.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBodyLimits(t *testing.T) {
+	tests := []struct {
+		upload, report, depth string
+		want                  BodyLimits
+		wantErr               bool
+	}{
+		{"", "", "", DefaultBodyLimits, false},
+		{"10", "20", "3", BodyLimits{MaxUpload: 10, MaxReport: 20, MaxDepth: 3}, false},
+		{"10", "358400", "3", BodyLimits{MaxUpload: 10, MaxReport: domain.MaxStoredReport, MaxDepth: 3}, false},
+		{"-1", "", "", BodyLimits{}, true},
+		{"0", "", "", BodyLimits{}, true},
+		{"1mb", "", "", BodyLimits{}, true},
+		{"", "x", "", BodyLimits{}, true},
+		{"", "0", "", BodyLimits{}, true},
+		{"", "358401", "", BodyLimits{}, true},
+		{"", "8388608", "", BodyLimits{}, true},
+		{"", "", "0", BodyLimits{}, true},
+		{"", "", "-3", BodyLimits{}, true},
+		{"", "", "deep", BodyLimits{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBodyLimits(tt.upload, tt.report, tt.depth)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBodyLimits(%q, %q, %q) error %v, want error %v", tt.upload, tt.report, tt.depth, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseBodyLimits(%q, %q, %q) = %+v, want %+v", tt.upload, tt.report, tt.depth, got, tt.want)
+		}
+	}
+}
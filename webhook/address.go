@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrForbiddenAddress is the error of a webhook url which resolves to an address deliveries may not be sent to
+var ErrForbiddenAddress = errors.New("webhook address is loopback, link-local or private")
+
+// forbiddenNets are the networks deliveries may not reach, so a webhook cannot be used to probe the server's
+// network (i.e. the cloud metadata endpoint at 169.254.169.254)
+var forbiddenNets = parseCIDRs(
+	"0.0.0.0/8",      // this network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade nat
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local
+	"172.16.0.0/12",  // private
+	"192.168.0.0/16", // private
+	"224.0.0.0/4",    // multicast
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+	"ff00::/8",       // multicast
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// Forbidden is true if deliveries may not be sent to the ip
+func Forbidden(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, n := range forbiddenNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckURL fails with ErrForbiddenAddress if any address the url's host resolves to is Forbidden
+func CheckURL(ctx context.Context, rawurl string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return errors.Wrap(err, "invalid webhook url")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		if Forbidden(ip) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return errors.Wrapf(err, "could not resolve webhook host %v", u.Hostname())
+	}
+	for _, a := range addrs {
+		if Forbidden(a.IP) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// dialer refuses connections to Forbidden addresses. The address is checked once resolved, as it is dialed, so a
+// host which resolved to a public address at registration cannot be rebound to a private one.
+func dialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || Forbidden(ip) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+}
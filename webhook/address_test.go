@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForbidden(t *testing.T) {
+	tests := []struct {
+		ip        string
+		forbidden bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, tt := range tests {
+		if got := Forbidden(net.ParseIP(tt.ip)); got != tt.forbidden {
+			t.Errorf("%v: got %v, want %v", tt.ip, got, tt.forbidden)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url string
+		err error
+	}{
+		{"http://127.0.0.1:8080/hook", ErrForbiddenAddress},
+		{"https://[::1]/hook", ErrForbiddenAddress},
+		{"http://169.254.169.254/latest/meta-data/", ErrForbiddenAddress},
+		{"http://localhost/hook", ErrForbiddenAddress},
+		{"https://93.184.216.34/hook", nil},
+	}
+	for _, tt := range tests {
+		if err := CheckURL(context.Background(), tt.url); err != tt.err {
+			t.Errorf("%v: got %v, want %v", tt.url, err, tt.err)
+		}
+	}
+}
+
+func TestDialerRefusesForbidden(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	client := &http.Client{Transport: &http.Transport{DialContext: dialer(time.Second).DialContext}}
+	_, err := client.Post(srv.URL, "application/json", strings.NewReader("{}"))
+	if err == nil || !strings.Contains(err.Error(), ErrForbiddenAddress.Error()) {
+		t.Errorf("got %v, want %v", err, ErrForbiddenAddress)
+	}
+}
@@ -0,0 +1,448 @@
+// Package webhook delivers events to the webhooks developers register. Each event is POSTed as json, signed with
+// the webhook's secret, and retried with exponential backoff until it is delivered or out of attempts. Every
+// delivery, and the outcome of its last attempt, is logged in the store.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go_report/domain"
+	"go_report/logging"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Headers of each delivery
+const (
+	EventHeader     = "X-GoReport-Event"
+	DeliveryHeader  = "X-GoReport-Delivery"
+	SignatureHeader = "X-GoReport-Signature-256" // see Sign
+)
+
+const (
+	workers          = 4
+	queueSize        = 1000             // attempts waiting for a worker, beyond which they wait a backoff
+	maxBackoff       = time.Hour        // between attempts
+	cacheFor         = 30 * time.Second // webhooks registered on other instances are seen after this long
+	maxResponseError = 256              // bytes of a failed attempt's response kept as its error
+)
+
+var ErrClosed = errors.New("webhook dispatcher is shut down")
+
+// Config of the deliveries
+type Config struct {
+	MaxAttempts int           // of each delivery
+	Backoff     time.Duration // before the first retry, doubled before each next one (up to an hour)
+	Timeout     time.Duration // of each attempt
+}
+
+// Payload is the body POSTed for an event
+type Payload struct {
+	Event      domain.WebhookEvent `json:"event"`
+	OccurredOn time.Time           `json:"occurredOn"`
+	Group      *domain.Group       `json:"group,omitempty"`     // as of the event
+	Report     *Report             `json:"report,omitempty"`    // of report.new, group.new & severity.threshold
+	Threshold  string              `json:"threshold,omitempty"` // the webhook's MinSeverity, of severity.threshold
+}
+
+// Report is a report without its content, which is at GET /v1/report/group/{gid}/key/{key}/
+type Report struct {
+	GID        string            `json:"gid"`
+	Key        string            `json:"key"`
+	Severity   string            `json:"severity"`
+	ReceivedOn time.Time         `json:"receivedOn"`
+	Release    string            `json:"release,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+}
+
+// Sign is the signature header of a payload: "sha256=" and the hex hmac-sha256 of the body, keyed by the secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random secret, for webhooks registered without one
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "could not generate webhook secret")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Dispatcher sends the events of stored reports & resolved groups to the webhooks subscribed to them. Retries wait
+// in memory: those still waiting at shutdown are logged as failed, to be redelivered.
+type Dispatcher struct {
+	cfg    Config
+	store  domain.WebhookStorer
+	client *http.Client
+	log    *logging.Logger
+	// OnAttempt, if set, is called with the outcome of every attempt: delivered, retrying or failed
+	OnAttempt func(outcome string)
+
+	attempts chan domain.Delivery
+	lock     sync.Mutex // guards closed, retries, and sends to attempts
+	closed   bool
+	retries  map[string]retry // by delivery id
+	workers  sync.WaitGroup
+
+	cacheLock sync.Mutex
+	hooks     []domain.Webhook
+	hooksAt   time.Time
+}
+
+type retry struct {
+	timer    *time.Timer
+	delivery domain.Delivery
+}
+
+// New starts the workers which make delivery attempts
+func New(cfg Config, store domain.WebhookStorer, logger *logging.Logger) (*Dispatcher, error) {
+	if cfg.MaxAttempts < 1 || cfg.Backoff <= 0 || cfg.Timeout <= 0 {
+		return nil, errors.Errorf("invalid webhook config %+v", cfg)
+	}
+	d := &Dispatcher{
+		cfg:   cfg,
+		store: store,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// not http.DefaultTransport, which would follow HTTP_PROXY and dial any address
+			Transport: &http.Transport{
+				DialContext:         dialer(cfg.Timeout).DialContext,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			// a redirected POST would be sent on as a GET, without its payload
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		log:      logger,
+		attempts: make(chan domain.Delivery, queueSize),
+		retries:  map[string]retry{},
+	}
+	for i := 0; i < workers; i++ {
+		d.workers.Add(1)
+		go d.work()
+	}
+	return d, nil
+}
+
+// ReportStored sends report.new, group.new (if newGroup, as the store decided when counting the report), and (with the
+// report's group, nil if it could not be read) severity.threshold, to their subscribers
+func (d *Dispatcher) ReportStored(ctx context.Context, rpt domain.Report, grp *domain.Group, newGroup bool) error {
+	hooks, err := d.webhooks(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	ref := &Report{GID: rpt.GID, Key: rpt.Key, Severity: rpt.Severity.String(), ReceivedOn: rpt.ReceivedOn, Release: rpt.Release, Tags: rpt.Tags}
+	var failed error
+	for _, h := range hooks {
+		if h.Subscribes(domain.EventNewReport) {
+			failed = firstErr(failed, d.send(ctx, h, Payload{Event: domain.EventNewReport, OccurredOn: now, Group: grp, Report: ref}))
+		}
+		if h.Subscribes(domain.EventNewGroup) && newGroup {
+			failed = firstErr(failed, d.send(ctx, h, Payload{Event: domain.EventNewGroup, OccurredOn: now, Group: grp, Report: ref}))
+		}
+		if grp == nil {
+			continue
+		}
+		// crossed by the group's first report at or above the threshold
+		if h.Subscribes(domain.EventSeverity) && rpt.Severity >= h.MinSeverity && grp.CountAtLeast(h.MinSeverity) == 1 {
+			failed = firstErr(failed, d.send(ctx, h, Payload{Event: domain.EventSeverity, OccurredOn: now, Group: grp, Report: ref, Threshold: h.MinSeverity.String()}))
+		}
+	}
+	return failed
+}
+
+// GroupResolved sends group.resolved to its subscribers
+func (d *Dispatcher) GroupResolved(ctx context.Context, grp domain.Group) error {
+	hooks, err := d.webhooks(ctx)
+	if err != nil {
+		return err
+	}
+	var failed error
+	for _, h := range hooks {
+		if h.Subscribes(domain.EventGroupResolved) {
+			failed = firstErr(failed, d.send(ctx, h, Payload{Event: domain.EventGroupResolved, OccurredOn: time.Now(), Group: &grp}))
+		}
+	}
+	return failed
+}
+
+// Redeliver sends a logged delivery's payload again, as a new delivery
+func (d *Dispatcher) Redeliver(ctx context.Context, del domain.Delivery) (domain.Delivery, error) {
+	now := time.Now()
+	re := domain.Delivery{
+		ID:           newDeliveryID(now),
+		WebhookID:    del.WebhookID,
+		Event:        del.Event,
+		Payload:      del.Payload,
+		Status:       domain.DeliveryPending,
+		CreatedOn:    now,
+		NextAttempt:  now,
+		RedeliveryOf: del.ID,
+	}
+	return re, d.queue(ctx, re)
+}
+
+// Changed drops the cached webhooks, once one is registered or removed
+func (d *Dispatcher) Changed() {
+	d.cacheLock.Lock()
+	d.hooks = nil
+	d.cacheLock.Unlock()
+}
+
+// Shutdown stops accepting events, and waits for the queued attempts until ctx is done. Deliveries waiting to be
+// retried are logged as failed; those not attempted in time stay pending in the log.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	d.lock.Lock()
+	if d.closed {
+		d.lock.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.attempts)
+	waiting := make([]domain.Delivery, 0, len(d.retries))
+	for id, rt := range d.retries {
+		rt.timer.Stop()
+		waiting = append(waiting, rt.delivery)
+		delete(d.retries, id)
+	}
+	d.lock.Unlock()
+	for _, del := range waiting {
+		d.abandon(del)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "webhook deliveries did not finish")
+	}
+}
+
+// webhooks are the registered webhooks, read at most every cacheFor
+func (d *Dispatcher) webhooks(ctx context.Context) ([]domain.Webhook, error) {
+	d.cacheLock.Lock()
+	defer d.cacheLock.Unlock()
+	if d.hooks != nil && time.Since(d.hooksAt) < cacheFor {
+		return d.hooks, nil
+	}
+	hooks, err := d.store.SelectWebhooks(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read webhooks")
+	}
+	d.hooks, d.hooksAt = hooks, time.Now()
+	return hooks, nil
+}
+
+// webhook is the registered webhook with the id, nil if there is none
+func (d *Dispatcher) webhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	if hooks, err := d.webhooks(ctx); err == nil {
+		for _, h := range hooks {
+			if h.ID == id {
+				return &h, nil
+			}
+		}
+	}
+	return d.store.SelectWebhook(ctx, id) // registered on another instance since the cache was read, or removed
+}
+
+// send logs & queues a new delivery of the payload to the webhook
+func (d *Dispatcher) send(ctx context.Context, h domain.Webhook, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return errors.Wrap(err, "could not encode webhook payload")
+	}
+	now := time.Now()
+	return d.queue(ctx, domain.Delivery{
+		ID:          newDeliveryID(now),
+		WebhookID:   h.ID,
+		Event:       p.Event,
+		Payload:     body,
+		Status:      domain.DeliveryPending,
+		CreatedOn:   now,
+		NextAttempt: now,
+	})
+}
+
+func (d *Dispatcher) queue(ctx context.Context, del domain.Delivery) error {
+	if err := d.store.SaveDelivery(ctx, del); err != nil {
+		return errors.Wrap(err, "could not log webhook delivery")
+	}
+	d.lock.Lock()
+	if d.closed {
+		d.lock.Unlock()
+		d.abandon(del)
+		return ErrClosed
+	}
+	select {
+	case d.attempts <- del:
+	default:
+		d.retryAfter(del, d.cfg.Backoff)
+	}
+	d.lock.Unlock()
+	return nil
+}
+
+// retryAfter queues the delivery's next attempt after the delay, with the lock held
+func (d *Dispatcher) retryAfter(del domain.Delivery, after time.Duration) {
+	d.retries[del.ID] = retry{delivery: del, timer: time.AfterFunc(after, func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		if _, waiting := d.retries[del.ID]; !waiting || d.closed {
+			return // shut down
+		}
+		delete(d.retries, del.ID)
+		select {
+		case d.attempts <- del:
+		default:
+			d.retryAfter(del, d.cfg.Backoff)
+		}
+	})}
+}
+
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+	for del := range d.attempts {
+		d.attempt(del)
+	}
+}
+
+// attempt posts the delivery, logging its outcome, and schedules its retry if it failed
+func (d *Dispatcher) attempt(del domain.Delivery) {
+	logger := d.log.With("webhook", del.WebhookID, "delivery", del.ID, "event", del.Event)
+	h, err := d.webhook(context.Background(), del.WebhookID)
+	if err == nil && h == nil {
+		del.Status, del.Error, del.NextAttempt = domain.DeliveryFailed, "webhook was removed", time.Time{}
+		d.save(del, logger)
+		return
+	}
+	if err == nil {
+		del.StatusCode, err = d.post(*h, del)
+	} else {
+		del.StatusCode, err = 0, errors.Wrap(err, "could not read webhook")
+	}
+	del.Attempts++
+	del.LastAttempt = time.Now()
+	outcome := "delivered"
+	switch {
+	case err == nil:
+		del.Status, del.Error, del.NextAttempt = domain.DeliveryDelivered, "", time.Time{}
+		logger.Debug("delivered webhook", "attempts", del.Attempts)
+	case del.Attempts >= d.cfg.MaxAttempts:
+		outcome = "failed"
+		del.Status, del.Error, del.NextAttempt = domain.DeliveryFailed, err.Error(), time.Time{}
+		logger.Warn("webhook delivery failed", "attempts", del.Attempts, "err", err)
+	default:
+		outcome = "retrying"
+		delay := d.backoff(del.Attempts)
+		del.Error, del.NextAttempt = err.Error(), del.LastAttempt.Add(delay)
+		logger.Info("webhook delivery attempt failed, retrying", "attempts", del.Attempts, "retryIn", delay.String(), "err", err)
+	}
+	if d.OnAttempt != nil {
+		d.OnAttempt(outcome)
+	}
+	d.save(del, logger)
+	if del.Status != domain.DeliveryPending {
+		return
+	}
+	d.lock.Lock()
+	closed := d.closed
+	if !closed {
+		d.retryAfter(del, del.NextAttempt.Sub(time.Now()))
+	}
+	d.lock.Unlock()
+	if closed {
+		d.abandon(del)
+	}
+}
+
+// post sends the delivery, returning the response's status code (0 without a response). Responses other than 2xx fail.
+func (d *Dispatcher) post(h domain.Webhook, del domain.Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid webhook url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go_report-webhook")
+	req.Header.Set(EventHeader, string(del.Event))
+	req.Header.Set(DeliveryHeader, del.ID)
+	req.Header.Set(SignatureHeader, Sign(h.Secret, del.Payload))
+	rsp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= 200 && rsp.StatusCode < 300 {
+		io.Copy(ioutil.Discard, io.LimitReader(rsp.Body, 64<<10)) // so the connection is reused
+		return rsp.StatusCode, nil
+	}
+	b, _ := ioutil.ReadAll(io.LimitReader(rsp.Body, maxResponseError))
+	if msg := strings.TrimSpace(string(b)); msg != "" {
+		return rsp.StatusCode, errors.Errorf("%v: %v", rsp.Status, msg)
+	}
+	return rsp.StatusCode, errors.New(rsp.Status)
+}
+
+// backoff is the delay after the failed attempt
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.Backoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// abandon logs a delivery which will not be retried, as the dispatcher is shut down
+func (d *Dispatcher) abandon(del domain.Delivery) {
+	del.Status, del.NextAttempt = domain.DeliveryFailed, time.Time{}
+	if del.Error != "" {
+		del.Error += "; "
+	}
+	del.Error += "shut down before the next attempt"
+	d.save(del, d.log.With("webhook", del.WebhookID, "delivery", del.ID, "event", del.Event))
+}
+
+func (d *Dispatcher) save(del domain.Delivery, logger *logging.Logger) {
+	if err := d.store.SaveDelivery(context.Background(), del); err != nil {
+		logger.Error("could not log webhook delivery", "status", del.Status, "err", err)
+	}
+}
+
+// newDeliveryID sorts in order of creation (see domain.NewCommentID), with a random suffix as deliveries of one
+// event are created together
+func newDeliveryID(t time.Time) string {
+	b := make([]byte, 2)
+	rand.Read(b)
+	return fmt.Sprintf("%v%x", domain.NewCommentID(t), b)
+}
+
+func firstErr(first, err error) error {
+	if first != nil {
+		return first
+	}
+	return err
+}
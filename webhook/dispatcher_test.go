@@ -0,0 +1,304 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"go_report/domain"
+	"go_report/logging"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore keeps webhooks & deliveries in memory
+type memStore struct {
+	domain.WebhookStorer
+	lock       sync.Mutex
+	hooks      []domain.Webhook
+	deliveries map[string]domain.Delivery
+}
+
+func (s *memStore) SelectWebhooks(ctx context.Context) ([]domain.Webhook, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]domain.Webhook{}, s.hooks...), nil
+}
+
+func (s *memStore) SelectWebhook(ctx context.Context, id string) (*domain.Webhook, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, h := range s.hooks {
+		if h.ID == id {
+			return &h, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memStore) SaveDelivery(ctx context.Context, d domain.Delivery) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.deliveries == nil {
+		s.deliveries = map[string]domain.Delivery{}
+	}
+	s.deliveries[d.ID] = d
+	return nil
+}
+
+func (s *memStore) all() []domain.Delivery {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var ds []domain.Delivery
+	for _, d := range s.deliveries {
+		ds = append(ds, d)
+	}
+	return ds
+}
+
+// settled waits for every delivery to be delivered or failed
+func (s *memStore) settled(t *testing.T, n int) []domain.Delivery {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		ds := s.all()
+		done := len(ds) == n
+		for _, d := range ds {
+			done = done && d.Status != domain.DeliveryPending
+		}
+		if done {
+			return ds
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("deliveries did not settle: %+v", s.all())
+	return nil
+}
+
+// newDispatcher delivers to local test servers, which the dialer would refuse
+func newDispatcher(t *testing.T, cfg Config, store *memStore) *Dispatcher {
+	d, err := New(cfg, store, logging.New(ioutil.Discard, logging.Error))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.client.Transport = &http.Transport{}
+	return d
+}
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret, body, want string
+	}{
+		{"key", "The quick brown fox jumps over the lazy dog", "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+		{"", "", "sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad"},
+	}
+	for _, tt := range tests {
+		if got := Sign(tt.secret, []byte(tt.body)); got != tt.want {
+			t.Errorf("Sign(%q, %q) = %v, want %v", tt.secret, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		backoff  time.Duration
+		attempts int
+		want     time.Duration
+	}{
+		{time.Second, 1, time.Second},
+		{time.Second, 2, 2 * time.Second},
+		{time.Second, 4, 8 * time.Second},
+		{time.Second, 100, maxBackoff},
+		{40 * time.Minute, 2, maxBackoff},
+		{2 * time.Hour, 1, maxBackoff},
+	}
+	for _, tt := range tests {
+		d := &Dispatcher{cfg: Config{Backoff: tt.backoff}}
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff %v after %d attempts = %v, want %v", tt.backoff, tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{MaxAttempts: 0, Backoff: time.Second, Timeout: time.Second},
+		{MaxAttempts: 1, Backoff: 0, Timeout: time.Second},
+		{MaxAttempts: 1, Backoff: time.Second, Timeout: 0},
+	} {
+		if _, err := New(cfg, &memStore{}, nil); err == nil {
+			t.Errorf("%+v: no error", cfg)
+		}
+	}
+}
+
+func TestReportStoredEvents(t *testing.T) {
+	all := []domain.WebhookEvent{domain.EventNewReport, domain.EventNewGroup, domain.EventSeverity, domain.EventGroupResolved}
+	tests := []struct {
+		name     string
+		events   []domain.WebhookEvent
+		min      domain.ReportType
+		sev      domain.ReportType
+		grp      *domain.Group
+		newGroup bool
+		want     []domain.WebhookEvent
+	}{
+		{"first report", all, domain.BugType, domain.BugType, &domain.Group{Count: 1, SeverityCounts: map[string]int{"bug": 1}}, true,
+			[]domain.WebhookEvent{domain.EventNewGroup, domain.EventNewReport, domain.EventSeverity}},
+		{"later report", all, domain.BugType, domain.BugType, &domain.Group{Count: 2, SeverityCounts: map[string]int{"bug": 2}}, false,
+			[]domain.WebhookEvent{domain.EventNewReport}},
+		{"first crash", all, domain.CrashType, domain.CrashType, &domain.Group{Count: 3, SeverityCounts: map[string]int{"bug": 2, "crash": 1}}, false,
+			[]domain.WebhookEvent{domain.EventNewReport, domain.EventSeverity}},
+		{"below threshold", all, domain.CrashType, domain.BugType, &domain.Group{Count: 2, SeverityCounts: map[string]int{"bug": 2}}, false,
+			[]domain.WebhookEvent{domain.EventNewReport}},
+		{"unknown group", all, domain.BugType, domain.BugType, nil, false, []domain.WebhookEvent{domain.EventNewReport}},
+		{"first report of an unreadable group", all, domain.BugType, domain.BugType, nil, true,
+			[]domain.WebhookEvent{domain.EventNewGroup, domain.EventNewReport}},
+		{"not subscribed", []domain.WebhookEvent{domain.EventGroupResolved}, domain.BugType, domain.BugType,
+			&domain.Group{Count: 1, SeverityCounts: map[string]int{"bug": 1}}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			defer srv.Close()
+			store := &memStore{hooks: []domain.Webhook{{ID: "h", URL: srv.URL, Events: tt.events, MinSeverity: tt.min}}}
+			d := newDispatcher(t, Config{MaxAttempts: 1, Backoff: time.Millisecond, Timeout: time.Second}, store)
+			defer d.Shutdown(context.Background())
+
+			if err := d.ReportStored(context.Background(), domain.Report{GID: "g", Key: "k", Severity: tt.sev}, tt.grp, tt.newGroup); err != nil {
+				t.Fatal(err)
+			}
+			var got []domain.WebhookEvent
+			for _, del := range store.settled(t, len(tt.want)) {
+				got = append(got, del.Event)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sent %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelivery(t *testing.T) {
+	tests := []struct {
+		name        string
+		responses   []int // status of each attempt's response, the last repeated
+		maxAttempts int
+		status      domain.DeliveryStatus
+		attempts    int
+		statusCode  int
+		err         string
+	}{
+		{"delivered", []int{http.StatusNoContent}, 3, domain.DeliveryDelivered, 1, http.StatusNoContent, ""},
+		{"delivered on retry", []int{http.StatusBadGateway, http.StatusOK}, 3, domain.DeliveryDelivered, 2, http.StatusOK, ""},
+		{"out of attempts", []int{http.StatusInternalServerError}, 3, domain.DeliveryFailed, 3, http.StatusInternalServerError, "500 Internal Server Error: nope"},
+		{"redirects are not followed", []int{http.StatusFound}, 1, domain.DeliveryFailed, 1, http.StatusFound, "302 Found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lock sync.Mutex
+			var bad []string
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := ioutil.ReadAll(r.Body)
+				lock.Lock()
+				defer lock.Unlock()
+				if got := r.Header.Get(SignatureHeader); got != Sign("secret", b) {
+					bad = append(bad, "signature "+got)
+				}
+				if r.Header.Get(EventHeader) != string(domain.EventGroupResolved) || r.Header.Get(DeliveryHeader) == "" {
+					bad = append(bad, "headers")
+				}
+				var p Payload
+				if err := json.Unmarshal(b, &p); err != nil || p.Group == nil || p.Group.GID != "g" {
+					bad = append(bad, "payload "+string(b))
+				}
+				code := tt.responses[len(tt.responses)-1]
+				if calls < len(tt.responses) {
+					code = tt.responses[calls]
+				}
+				calls++
+				if code == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(code)
+				if code >= 300 && code != http.StatusFound {
+					w.Write([]byte(" nope\n"))
+				}
+			}))
+			defer srv.Close()
+			store := &memStore{hooks: []domain.Webhook{{ID: "h", URL: srv.URL, Secret: "secret", Events: []domain.WebhookEvent{domain.EventGroupResolved}}}}
+			d := newDispatcher(t, Config{MaxAttempts: tt.maxAttempts, Backoff: time.Millisecond, Timeout: time.Second}, store)
+			defer d.Shutdown(context.Background())
+			var outcomes []string
+			d.OnAttempt = func(outcome string) {
+				lock.Lock()
+				outcomes = append(outcomes, outcome)
+				lock.Unlock()
+			}
+
+			if err := d.GroupResolved(context.Background(), domain.Group{GID: "g"}); err != nil {
+				t.Fatal(err)
+			}
+			del := store.settled(t, 1)[0]
+			if del.Status != tt.status || del.Attempts != tt.attempts || del.StatusCode != tt.statusCode || del.Error != tt.err {
+				t.Errorf("got %v after %d attempts (%d, %q), want %v after %d (%d, %q)",
+					del.Status, del.Attempts, del.StatusCode, del.Error, tt.status, tt.attempts, tt.statusCode, tt.err)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if len(bad) > 0 {
+				t.Errorf("invalid requests: %v", bad)
+			}
+			if len(outcomes) != tt.attempts || outcomes[len(outcomes)-1] == "retrying" {
+				t.Errorf("outcomes %v", outcomes)
+			}
+		})
+	}
+}
+
+func TestDeliveryToRemovedWebhook(t *testing.T) {
+	store := &memStore{}
+	d := newDispatcher(t, Config{MaxAttempts: 3, Backoff: time.Millisecond, Timeout: time.Second}, store)
+	defer d.Shutdown(context.Background())
+	if _, err := d.Redeliver(context.Background(), domain.Delivery{ID: "old", WebhookID: "gone", Event: domain.EventNewReport}); err != nil {
+		t.Fatal(err)
+	}
+	del := store.settled(t, 1)[0]
+	if del.Status != domain.DeliveryFailed || del.Attempts != 0 || del.Error != "webhook was removed" || del.RedeliveryOf != "old" {
+		t.Errorf("got %+v", del)
+	}
+}
+
+func TestShutdownAbandonsRetries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	store := &memStore{hooks: []domain.Webhook{{ID: "h", URL: srv.URL, Events: []domain.WebhookEvent{domain.EventGroupResolved}}}}
+	d := newDispatcher(t, Config{MaxAttempts: 3, Backoff: time.Hour, Timeout: time.Second}, store)
+	if err := d.GroupResolved(context.Background(), domain.Group{GID: "g"}); err != nil {
+		t.Fatal(err)
+	}
+	for waiting := 0; waiting == 0; {
+		time.Sleep(5 * time.Millisecond)
+		d.lock.Lock()
+		waiting = len(d.retries)
+		d.lock.Unlock()
+	}
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	del := store.settled(t, 1)[0]
+	if del.Status != domain.DeliveryFailed || del.Attempts != 1 || !strings.HasSuffix(del.Error, "; shut down before the next attempt") {
+		t.Errorf("got %+v", del)
+	}
+	if err := d.GroupResolved(context.Background(), domain.Group{GID: "g"}); err != ErrClosed {
+		t.Errorf("after shutdown got %v, want %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"go_report/auth"
+	"go_report/domain"
+	"go_report/failure"
+	"go_report/webhook"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WebhookRequest is the body of a request to register a webhook
+type WebhookRequest struct {
+	URL         string   `json:"url"`                   // http(s), POSTed each event
+	Events      []string `json:"events"`                // see domain.WebhookEvents
+	MinSeverity string   `json:"minSeverity,omitempty"` // of severity.threshold events: bug or crash (default)
+	Secret      string   `json:"secret,omitempty"`      // signs the payloads, generated if empty
+}
+
+// ParseWebhookConfig reads the webhook delivery config: attempts per delivery, the backoff before the first retry
+// and the timeout of each attempt (go durations, i.e. "30s")
+func ParseWebhookConfig(maxAttempts, backoff, timeout string) (cfg webhook.Config, err error) {
+	if cfg.MaxAttempts, err = strconv.Atoi(maxAttempts); err != nil || cfg.MaxAttempts < 1 {
+		return cfg, errors.Errorf("invalid WEBHOOK_MAX_ATTEMPTS %q", maxAttempts)
+	}
+	if cfg.Backoff, err = time.ParseDuration(backoff); err != nil || cfg.Backoff <= 0 {
+		return cfg, errors.Errorf("invalid WEBHOOK_BACKOFF %q", backoff)
+	}
+	if cfg.Timeout, err = time.ParseDuration(timeout); err != nil || cfg.Timeout <= 0 {
+		return cfg, errors.Errorf("invalid WEBHOOK_TIMEOUT %q", timeout)
+	}
+	return cfg, nil
+}
+
+// GetWebhooksHandler lists every registered webhook, without their secrets
+func GetWebhooksHandler(s domain.WebhookStorer) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hooks, err := s.SelectWebhooks(r.Context())
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		for i := range hooks {
+			hooks[i].Secret = ""
+		}
+		if err := json.NewEncoder(w).Encode(hooks); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode webhooks json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// PostWebhookHandler registers the requesting developer's webhook, responding with its secret (shown only once)
+func PostWebhookHandler(s domain.WebhookStorer, hooks *webhook.Dispatcher) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, err := decodeWebhookRequest(r)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		if h.Secret == "" {
+			if h.Secret, err = webhook.NewSecret(); err != nil {
+				failure.Fail(w, r, failure.New(err, http.StatusInternalServerError, ""))
+				return
+			}
+		}
+		h.CreatedBy, h.CreatedOn = auth.GHUserFromContext(r.Context()), time.Now()
+		if h, err = s.NewWebhook(r.Context(), h); err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		hooks.Changed()
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(h); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode webhook json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// GetWebhookHandler returns the webhook in context, without its secret
+func GetWebhookHandler(s domain.WebhookStorer) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, err := selectWebhook(r.Context(), s, r.Context().Value(string(WebhookIDVar)).(string))
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		h.Secret = ""
+		if err := json.NewEncoder(w).Encode(h); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode webhook json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// DeleteWebhookHandler removes the webhook in context; deliveries waiting to be retried then fail
+func DeleteWebhookHandler(s domain.WebhookStorer, hooks *webhook.Dispatcher) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := s.RemoveWebhook(r.Context(), r.Context().Value(string(WebhookIDVar)).(string)); err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		hooks.Changed()
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// GetDeliveriesHandler lists the logged deliveries of the webhook in context, newest first
+func GetDeliveriesHandler(s domain.WebhookStorer) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Context().Value(string(WebhookIDVar)).(string)
+		if _, err := selectWebhook(r.Context(), s, id); err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		deliveries, err := s.SelectDeliveries(r.Context(), id)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		for i, j := 0, len(deliveries)-1; i < j; i, j = i+1, j-1 {
+			deliveries[i], deliveries[j] = deliveries[j], deliveries[i]
+		}
+		if err := json.NewEncoder(w).Encode(deliveries); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode deliveries json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// RedeliverHandler sends the payload of the delivery in context again, responding with the new delivery
+func RedeliverHandler(s domain.WebhookStorer, hooks *webhook.Dispatcher) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hookID, id := r.Context().Value(string(WebhookIDVar)).(string), r.Context().Value(string(DeliveryIDVar)).(string)
+		if _, err := selectWebhook(r.Context(), s, hookID); err != nil {
+			failure.Fail(w, r, err)
+			return
+		}
+		del, err := s.SelectDelivery(r.Context(), hookID, id)
+		if err != nil {
+			failure.Fail(w, r, err)
+			return
+		} else if del == nil {
+			failure.Fail(w, r, failure.New(errors.Errorf("no delivery %v of webhook %v", id, hookID), http.StatusNotFound, "delivery not found"))
+			return
+		}
+		re, err := hooks.Redeliver(r.Context(), *del)
+		if err == webhook.ErrClosed {
+			failure.Fail(w, r, failure.New(err, http.StatusServiceUnavailable, "The server is shutting down, retry later"))
+			return
+		} else if err != nil {
+			failure.Fail(w, r, failure.New(err, http.StatusInternalServerError, ""))
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(re); err != nil {
+			failure.Fail(w, r, errors.Wrap(err, "failed to encode delivery json to http writer response stream"))
+			return
+		}
+	})
+}
+
+// selectWebhook fails with 404 if there is no webhook with the id
+func selectWebhook(ctx context.Context, s domain.WebhookStorer, id string) (*domain.Webhook, error) {
+	h, err := s.SelectWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	} else if h == nil {
+		return nil, failure.New(errors.Errorf("no webhook %v", id), http.StatusNotFound, "webhook not found")
+	}
+	return h, nil
+}
+
+func decodeWebhookRequest(r *http.Request) (domain.Webhook, error) {
+	wr := WebhookRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&wr); err != nil {
+		return domain.Webhook{}, failure.New(err, http.StatusBadRequest, "Could not decode webhook from request body")
+	}
+	u, err := url.Parse(wr.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return domain.Webhook{}, failure.New(errors.Errorf("invalid webhook url %q", wr.URL), http.StatusBadRequest, "url must be an absolute http or https url")
+	}
+	if err := webhook.CheckURL(r.Context(), wr.URL); err == webhook.ErrForbiddenAddress {
+		return domain.Webhook{}, failure.New(errors.Wrapf(err, "webhook url %q", wr.URL), http.StatusBadRequest, "url must not be a loopback, link-local or private address")
+	} else if err != nil {
+		return domain.Webhook{}, failure.New(err, http.StatusBadRequest, "url host could not be resolved")
+	}
+	h := domain.Webhook{URL: wr.URL, Secret: wr.Secret, MinSeverity: domain.CrashType}
+	if len(wr.Events) == 0 {
+		return domain.Webhook{}, failure.New(errors.New("webhook without events"), http.StatusBadRequest, "events must not be empty")
+	}
+	for _, e := range wr.Events {
+		ev, ok := domain.ConvertWebhookEventString(e)
+		if !ok {
+			return domain.Webhook{}, failure.New(errors.Errorf("unknown webhook event %v", e), http.StatusBadRequest, "events must be of report.new, group.new, severity.threshold, group.resolved")
+		}
+		if !h.Subscribes(ev) {
+			h.Events = append(h.Events, ev)
+		}
+	}
+	if wr.MinSeverity != "" {
+		if h.MinSeverity = domain.ConvertSeverityLevelString(wr.MinSeverity); h.MinSeverity == domain.UnknownType {
+			return domain.Webhook{}, failure.New(errors.Errorf("unknown severity %v", wr.MinSeverity), http.StatusBadRequest, "minSeverity must be bug or crash")
+		}
+	}
+	return h, nil
+}